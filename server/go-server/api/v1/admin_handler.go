@@ -0,0 +1,513 @@
+// ****************************************************************************
+//
+// @file       admin_handler.go
+// @brief      管理端小说、卷、章节的增删改API
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package v1
+
+import (
+	"lightnovel/internal/service"
+	"lightnovel/pkg/errors"
+	"lightnovel/pkg/response"
+	"lightnovel/pkg/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @securityDefinitions.apikey AdminAuth
+// @in header
+// @name X-Admin-Token
+// @description 管理接口令牌
+
+// @tag.name admin
+// @tag.description 管理端内容维护接口
+
+type AdminHandler struct {
+	novelService *service.NovelService
+}
+
+func NewAdminHandler(novelService *service.NovelService) *AdminHandler {
+	return &AdminHandler{novelService: novelService}
+}
+
+// NovelRequest 创建或更新小说请求，未提供的字段在更新时保持不变
+type NovelRequest struct {
+	Title       *string  `json:"title"`
+	Author      *string  `json:"author"`
+	Description *string  `json:"description"`
+	Cover       *string  `json:"cover"`
+	Tags        []string `json:"tags"`
+	Status      *string  `json:"status"`
+}
+
+// CreateVolumeRequest 创建卷请求
+type CreateVolumeRequest struct {
+	VolumeNumber int `json:"volumeNumber" binding:"min=0"` // 为0时自动使用下一个卷号
+}
+
+// CreateChapterRequest 创建章节请求
+type CreateChapterRequest struct {
+	ChapterNumber int    `json:"chapterNumber" binding:"min=0"` // 为0时自动使用下一个章节号
+	Title         string `json:"title"`                         // 为空时从正文提取
+	Content       string `json:"content" binding:"required"`
+}
+
+// PinCommentRequest 置顶评论请求
+type PinCommentRequest struct {
+	Pinned bool `json:"pinned"`
+}
+
+// DeviceRestrictionRequest 设备或账号禁言封禁请求
+type DeviceRestrictionRequest struct {
+	MuteMinutes int    `json:"muteMinutes" binding:"min=0"` // 禁言分钟数，为0时解除禁言
+	Banned      bool   `json:"banned"`
+	Reason      string `json:"reason"`
+}
+
+// UpdateChapterRequest 更新章节请求
+type UpdateChapterRequest struct {
+	Title   *string `json:"title"`
+	Content *string `json:"content"`
+}
+
+func (r *NovelRequest) fields() service.NovelFields {
+	return service.NovelFields{
+		Title:       r.Title,
+		Author:      r.Author,
+		Description: r.Description,
+		Cover:       r.Cover,
+		Tags:        r.Tags,
+		Status:      r.Status,
+	}
+}
+
+// @Summary 创建小说
+// @Description 创建新的小说，标题不能与已有小说重复
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param body body NovelRequest true "小说信息"
+// @Success 200 {object} response.Response{data=models.Novel} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /admin/novels [post]
+func (h *AdminHandler) CreateNovel(c *gin.Context) {
+	var req NovelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrBadRequest))
+		return
+	}
+
+	novel, err := h.novelService.CreateNovel(c.Request.Context(), req.fields())
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, novel)
+}
+
+// @Summary 更新小说
+// @Description 更新小说信息，只修改请求中提供的字段
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path string true "小说ID"
+// @Param body body NovelRequest true "小说信息"
+// @Success 200 {object} response.Response{data=models.Novel} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "小说不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /admin/novels/{id} [put]
+func (h *AdminHandler) UpdateNovel(c *gin.Context) {
+	var req NovelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrBadRequest))
+		return
+	}
+
+	novel, err := h.novelService.UpdateNovel(c.Request.Context(), c.Param("id"), req.fields())
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, novel)
+}
+
+// @Summary 删除小说
+// @Description 删除小说及其卷、章节、评论和用户阅读数据
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path string true "小说ID"
+// @Success 200 {object} response.Response "成功"
+// @Failure 404 {object} response.Response "小说不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /admin/novels/{id} [delete]
+func (h *AdminHandler) DeleteNovel(c *gin.Context) {
+	if err := h.novelService.DeleteNovel(c.Request.Context(), c.Param("id")); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// @Summary 创建卷
+// @Description 为小说新增一卷，并更新小说的卷数
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path string true "小说ID"
+// @Param body body CreateVolumeRequest true "卷信息"
+// @Success 200 {object} response.Response{data=models.Volume} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "小说不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /admin/novels/{id}/volumes [post]
+func (h *AdminHandler) CreateVolume(c *gin.Context) {
+	var req CreateVolumeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrBadRequest))
+		return
+	}
+
+	volume, err := h.novelService.CreateVolume(c.Request.Context(), c.Param("id"), req.VolumeNumber)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, volume)
+}
+
+// @Summary 删除卷
+// @Description 删除指定卷及其全部章节，并更新小说的卷数
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path string true "小说ID"
+// @Param volume path int true "卷号"
+// @Success 200 {object} response.Response "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "卷不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /admin/novels/{id}/volumes/{volume} [delete]
+func (h *AdminHandler) DeleteVolume(c *gin.Context) {
+	volumeNumber, err := strconv.Atoi(c.Param("volume"))
+	if err != nil {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	if err := h.novelService.DeleteVolume(c.Request.Context(), c.Param("id"), volumeNumber); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// @Summary 创建章节
+// @Description 在指定卷中新增章节，并更新卷的章节数
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path string true "小说ID"
+// @Param volume path int true "卷号"
+// @Param body body CreateChapterRequest true "章节信息"
+// @Success 200 {object} response.Response{data=models.Chapter} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "卷不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /admin/novels/{id}/volumes/{volume}/chapters [post]
+func (h *AdminHandler) CreateChapter(c *gin.Context) {
+	volumeNumber, err := strconv.Atoi(c.Param("volume"))
+	if err != nil {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	var req CreateChapterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrBadRequest))
+		return
+	}
+
+	chapter, err := h.novelService.CreateChapter(c.Request.Context(), c.Param("id"), volumeNumber, req.ChapterNumber, req.Title, req.Content)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, chapter)
+}
+
+// @Summary 更新章节
+// @Description 更新章节标题或正文，只修改请求中提供的字段
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path string true "小说ID"
+// @Param volume path int true "卷号"
+// @Param chapter path int true "章节号"
+// @Param body body UpdateChapterRequest true "章节信息"
+// @Success 200 {object} response.Response{data=models.Chapter} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "章节不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /admin/novels/{id}/volumes/{volume}/chapters/{chapter} [put]
+func (h *AdminHandler) UpdateChapter(c *gin.Context) {
+	volumeNumber, chapterNumber, ok := parseChapterParams(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateChapterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrBadRequest))
+		return
+	}
+
+	chapter, err := h.novelService.UpdateChapter(c.Request.Context(), c.Param("id"), volumeNumber, chapterNumber, service.ChapterFields{
+		Title:   req.Title,
+		Content: req.Content,
+	})
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, chapter)
+}
+
+// @Summary 删除章节
+// @Description 删除指定章节，并更新卷的章节数
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path string true "小说ID"
+// @Param volume path int true "卷号"
+// @Param chapter path int true "章节号"
+// @Success 200 {object} response.Response "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "章节不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /admin/novels/{id}/volumes/{volume}/chapters/{chapter} [delete]
+func (h *AdminHandler) DeleteChapter(c *gin.Context) {
+	volumeNumber, chapterNumber, ok := parseChapterParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.novelService.DeleteChapter(c.Request.Context(), c.Param("id"), volumeNumber, chapterNumber); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// parseChapterParams 解析路径中的卷号和章节号，失败时直接写入错误响应
+func parseChapterParams(c *gin.Context) (int, int, bool) {
+	volumeNumber, err := strconv.Atoi(c.Param("volume"))
+	if err != nil {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return 0, 0, false
+	}
+
+	chapterNumber, err := strconv.Atoi(c.Param("chapter"))
+	if err != nil {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return 0, 0, false
+	}
+
+	return volumeNumber, chapterNumber, true
+}
+
+// @Summary 置顶评论
+// @Description 置顶或取消置顶章节的顶层评论
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param comment_id path string true "评论ID"
+// @Param body body PinCommentRequest true "是否置顶"
+// @Success 200 {object} response.Response{data=models.Comment} "成功"
+// @Failure 400 {object} response.Response "参数错误或不是顶层评论"
+// @Failure 404 {object} response.Response "评论不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /admin/comments/{comment_id}/pin [put]
+func (h *AdminHandler) PinComment(c *gin.Context) {
+	var req PinCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrBadRequest))
+		return
+	}
+
+	comment, err := h.novelService.PinComment(c.Request.Context(), c.Param("comment_id"), req.Pinned)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, comment)
+}
+
+// @Summary 获取评论审核队列
+// @Description status为pending(默认)时返回待审核和有未处理举报的评论，为hidden时返回已隐藏的评论，按举报数倒序
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param status query string false "pending 或 hidden"
+// @Param page query int false "页码，默认1"
+// @Param size query int false "每页数量，默认20"
+// @Success 200 {object} response.Response{data=response.PageResponse{data=[]models.ModerationItem}} "成功"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /admin/comments/queue [get]
+func (h *AdminHandler) GetModerationQueue(c *gin.Context) {
+	page := utils.GetIntQuery(c, "page", 1)
+	size := utils.GetIntQuery(c, "size", 20)
+
+	items, total, err := h.novelService.GetModerationQueue(c.Request.Context(), c.DefaultQuery("status", service.CommentStatusPending), page, size)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.SuccessWithPage(c, total, page, size, items)
+}
+
+// @Summary 隐藏评论
+// @Description 隐藏评论并将其举报标记为已处理
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param comment_id path string true "评论ID"
+// @Success 200 {object} response.Response "成功"
+// @Failure 404 {object} response.Response "评论不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /admin/comments/{comment_id}/hide [post]
+func (h *AdminHandler) HideComment(c *gin.Context) {
+	if err := h.novelService.HideComment(c.Request.Context(), c.Param("comment_id")); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// @Summary 恢复评论
+// @Description 恢复评论公开展示并将其举报标记为已处理
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param comment_id path string true "评论ID"
+// @Success 200 {object} response.Response "成功"
+// @Failure 404 {object} response.Response "评论不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /admin/comments/{comment_id}/restore [post]
+func (h *AdminHandler) RestoreComment(c *gin.Context) {
+	if err := h.novelService.RestoreComment(c.Request.Context(), c.Param("comment_id")); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// @Summary 删除评论
+// @Description 删除任意评论及其回复、点赞和举报
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param comment_id path string true "评论ID"
+// @Success 200 {object} response.Response "成功"
+// @Failure 404 {object} response.Response "评论不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /admin/comments/{comment_id} [delete]
+func (h *AdminHandler) RemoveComment(c *gin.Context) {
+	if err := h.novelService.RemoveComment(c.Request.Context(), c.Param("comment_id")); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// @Summary 设置设备或账号禁言和封禁
+// @Description 禁言的设备或账号不能发表评论，封禁的设备或账号不能访问任何接口。
+// @Description 可传入设备ID或账号ID（登录用户评论的作者ID即账号ID），账号的设置同步到其绑定的所有设备；之后在同一IP下新出现的设备会继承限制
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param device_id path string true "设备ID或账号ID"
+// @Param body body DeviceRestrictionRequest true "禁言和封禁设置"
+// @Success 200 {object} response.Response{data=models.Restriction} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "设备或账号不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /admin/devices/{device_id}/restriction [put]
+func (h *AdminHandler) SetDeviceRestriction(c *gin.Context) {
+	var req DeviceRestrictionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrBadRequest))
+		return
+	}
+
+	restriction, err := h.novelService.SetRestriction(c.Request.Context(), c.Param("device_id"), time.Duration(req.MuteMinutes)*time.Minute, req.Banned, req.Reason)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, restriction)
+}
+
+// SystemNoticeRequest 发送系统通知请求
+type SystemNoticeRequest struct {
+	Level   string `json:"level" binding:"required,oneof=info warning error"`
+	Content string `json:"content" binding:"required,max=1000"`
+}
+
+// @Summary 发送系统通知
+// @Description 在后台向所有设备分批发送系统通知，在线的立即推送，离线的在重新连接时补发，同时计入各用户的通知列表
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param body body SystemNoticeRequest true "通知级别和内容"
+// @Success 200 {object} response.Response "已开始发送"
+// @Failure 400 {object} response.Response "参数错误"
+// @Router /admin/notices [post]
+func (h *AdminHandler) SendSystemNotice(c *gin.Context) {
+	var req SystemNoticeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrBadRequest))
+		return
+	}
+
+	h.novelService.SendSystemNotice(req.Level, req.Content)
+	response.Success(c, nil)
+}
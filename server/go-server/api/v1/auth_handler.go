@@ -0,0 +1,167 @@
+// ****************************************************************************
+//
+// @file       auth_handler.go
+// @brief      账号注册、登录与设备管理API
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package v1
+
+import (
+	"lightnovel/internal/models"
+	"lightnovel/internal/service"
+	"lightnovel/pkg/errors"
+	"lightnovel/pkg/middleware"
+	"lightnovel/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description 登录令牌，格式为 Bearer <token>
+
+// @tag.name auth
+// @tag.description 账号相关接口
+
+type AuthHandler struct {
+	novelService *service.NovelService
+}
+
+func NewAuthHandler(novelService *service.NovelService) *AuthHandler {
+	return &AuthHandler{novelService: novelService}
+}
+
+// CredentialsRequest 注册或登录请求
+type CredentialsRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// @Summary 注册账号
+// @Description 注册账号并在当前设备登录，携带X-Device-ID时该设备的收藏、书签、阅读记录和评论会迁移到账号，按IP识别的设备不迁移
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param X-Device-ID header string true "设备ID"
+// @Param body body CredentialsRequest true "用户名和密码"
+// @Success 200 {object} response.Response{data=models.AuthResponse} "成功"
+// @Failure 400 {object} response.Response "参数错误或用户名已存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /auth/register [post]
+func (h *AuthHandler) Register(c *gin.Context) {
+	var req CredentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrBadRequest))
+		return
+	}
+
+	result, err := h.novelService.Register(c.Request.Context(), physicalDeviceID(c), req.Username, req.Password, c.GetBool("explicitDevice"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// @Summary 登录
+// @Description 使用用户名和密码登录，携带X-Device-ID的设备首次登录时其匿名数据会迁移到账号，按IP识别的设备不迁移
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param X-Device-ID header string true "设备ID"
+// @Param body body CredentialsRequest true "用户名和密码"
+// @Success 200 {object} response.Response{data=models.AuthResponse} "成功"
+// @Failure 400 {object} response.Response "用户名或密码错误"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /auth/login [post]
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req CredentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrBadRequest))
+		return
+	}
+
+	result, err := h.novelService.Login(c.Request.Context(), physicalDeviceID(c), req.Username, req.Password, c.GetBool("explicitDevice"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// @Summary 退出登录
+// @Description 注销当前令牌
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response "成功"
+// @Failure 401 {object} response.Response "未登录"
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	if err := h.novelService.Logout(c.Request.Context(), middleware.BearerToken(c)); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// @Summary 获取已绑定设备
+// @Description 获取当前账号绑定的所有设备
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]models.Device} "成功"
+// @Failure 401 {object} response.Response "未登录"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /auth/devices [get]
+func (h *AuthHandler) GetDevices(c *gin.Context) {
+	devices, err := h.novelService.GetUserDevices(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, devices)
+}
+
+// @Summary 解绑设备
+// @Description 解除设备与当前账号的绑定，并注销该设备上的登录
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param device_id path string true "设备ID"
+// @Success 200 {object} response.Response "成功"
+// @Failure 401 {object} response.Response "未登录"
+// @Failure 404 {object} response.Response "设备不存在或未绑定当前账号"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /auth/devices/{device_id} [delete]
+func (h *AuthHandler) UnlinkDevice(c *gin.Context) {
+	err := h.novelService.UnlinkDevice(c.Request.Context(), c.GetString("userID"), c.Param("device_id"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// physicalDeviceID 获取发起请求的物理设备ID，登录后deviceID已替换为账号ID
+func physicalDeviceID(c *gin.Context) string {
+	if device, ok := c.Get("device"); ok {
+		if d, ok := device.(*models.Device); ok {
+			return d.ID
+		}
+	}
+	return c.GetString("deviceID")
+}
@@ -0,0 +1,390 @@
+// ****************************************************************************
+//
+// @file       bookshelf_handler.go
+// @brief      书单相关API
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package v1
+
+import (
+	"lightnovel/pkg/errors"
+	"lightnovel/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @tag.name shelves
+// @tag.description 书单相关接口
+
+// CreateBookshelfRequest 创建书单请求
+type CreateBookshelfRequest struct {
+	Name   string `json:"name" binding:"required,max=50"`
+	Note   string `json:"note" binding:"max=500"`
+	Public bool   `json:"public"`
+}
+
+// UpdateBookshelfRequest 修改书单请求，为空的字段不修改
+type UpdateBookshelfRequest struct {
+	Name   *string `json:"name" binding:"omitempty,min=1,max=50"`
+	Note   *string `json:"note" binding:"omitempty,max=500"`
+	Public *bool   `json:"public"`
+}
+
+// ReorderRequest 排序请求，按新的顺序列出ID
+type ReorderRequest struct {
+	IDs []string `json:"ids" binding:"required"`
+}
+
+// ShelfEntryRequest 加入书单或修改备注请求
+type ShelfEntryRequest struct {
+	NovelID string `json:"novelId"`
+	Note    string `json:"note" binding:"max=500"`
+}
+
+// @Summary 获取书单列表
+// @Description 获取全部书单及其中的小说数，首次访问时创建收藏、在读、想读、弃坑四个默认书单
+// @Tags shelves
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Success 200 {object} response.Response{data=[]models.Bookshelf} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/shelves [get]
+func (h *NovelHandler) GetBookshelves(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	shelves, err := h.novelService.GetBookshelves(c.Request.Context(), deviceID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, shelves)
+}
+
+// @Summary 创建书单
+// @Description 创建自建书单，公开的书单会生成分享编码
+// @Tags shelves
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Param request body CreateBookshelfRequest true "书单信息"
+// @Success 200 {object} response.Response{data=models.Bookshelf} "成功"
+// @Failure 400 {object} response.Response "参数错误或书单数已达上限"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/shelves [post]
+func (h *NovelHandler) CreateBookshelf(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	var req CreateBookshelfRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	shelf, err := h.novelService.CreateBookshelf(c.Request.Context(), deviceID, req.Name, req.Note, req.Public)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, shelf)
+}
+
+// @Summary 书单排序
+// @Description 按给定的书单ID顺序排列书单，未列出的书单保持原位置
+// @Tags shelves
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Param request body ReorderRequest true "书单ID"
+// @Success 200 {object} response.Response "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/shelves/order [put]
+func (h *NovelHandler) ReorderBookshelves(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	var req ReorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	if err := h.novelService.ReorderBookshelves(c.Request.Context(), deviceID, req.IDs); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// @Summary 获取书单
+// @Description 获取自己的书单及其中的小说，按书单内的顺序排列
+// @Tags shelves
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Param shelf_id path string true "书单ID"
+// @Success 200 {object} response.Response{data=models.BookshelfDetail} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "书单不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/shelves/{shelf_id} [get]
+func (h *NovelHandler) GetBookshelf(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	shelf, err := h.novelService.GetBookshelf(c.Request.Context(), deviceID, c.Param("shelf_id"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, shelf)
+}
+
+// @Summary 修改书单
+// @Description 修改书单名称、简介和公开状态。设为公开时生成分享编码，取消公开后原分享链接失效
+// @Tags shelves
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Param shelf_id path string true "书单ID"
+// @Param request body UpdateBookshelfRequest true "要修改的字段"
+// @Success 200 {object} response.Response{data=models.Bookshelf} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "书单不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/shelves/{shelf_id} [put]
+func (h *NovelHandler) UpdateBookshelf(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	var req UpdateBookshelfRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	shelf, err := h.novelService.UpdateBookshelf(c.Request.Context(), deviceID, c.Param("shelf_id"), req.Name, req.Note, req.Public)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, shelf)
+}
+
+// @Summary 删除书单
+// @Description 删除自建书单及其中的条目，默认书单不能删除
+// @Tags shelves
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Param shelf_id path string true "书单ID"
+// @Success 200 {object} response.Response "成功"
+// @Failure 400 {object} response.Response "参数错误或默认书单"
+// @Failure 404 {object} response.Response "书单不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/shelves/{shelf_id} [delete]
+func (h *NovelHandler) DeleteBookshelf(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	if err := h.novelService.DeleteBookshelf(c.Request.Context(), deviceID, c.Param("shelf_id")); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// @Summary 加入书单
+// @Description 将小说加到书单末尾。加入收藏书单等同于收藏，加入在读、想读或弃坑时会从另外两个中移出
+// @Tags shelves
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Param shelf_id path string true "书单ID"
+// @Param request body ShelfEntryRequest true "小说ID和备注"
+// @Success 200 {object} response.Response{data=models.ShelfEntry} "成功"
+// @Failure 400 {object} response.Response "参数错误或已在书单中"
+// @Failure 404 {object} response.Response "书单或小说不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/shelves/{shelf_id}/novels [post]
+func (h *NovelHandler) AddToBookshelf(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	var req ShelfEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.NovelID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	entry, err := h.novelService.AddToBookshelf(c.Request.Context(), deviceID, c.Param("shelf_id"), req.NovelID, req.Note)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, entry)
+}
+
+// @Summary 修改书单备注
+// @Description 修改书单中某部小说的备注
+// @Tags shelves
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Param shelf_id path string true "书单ID"
+// @Param novel_id path string true "小说ID"
+// @Param request body ShelfEntryRequest true "备注"
+// @Success 200 {object} response.Response{data=models.ShelfEntry} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "书单或条目不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/shelves/{shelf_id}/novels/{novel_id} [put]
+func (h *NovelHandler) UpdateShelfEntry(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	var req ShelfEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	entry, err := h.novelService.UpdateShelfEntry(c.Request.Context(), deviceID, c.Param("shelf_id"), c.Param("novel_id"), req.Note)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, entry)
+}
+
+// @Summary 移出书单
+// @Description 将小说移出书单，移出收藏书单等同于取消收藏
+// @Tags shelves
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Param shelf_id path string true "书单ID"
+// @Param novel_id path string true "小说ID"
+// @Success 200 {object} response.Response "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "书单或条目不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/shelves/{shelf_id}/novels/{novel_id} [delete]
+func (h *NovelHandler) RemoveFromBookshelf(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	if err := h.novelService.RemoveFromBookshelf(c.Request.Context(), deviceID, c.Param("shelf_id"), c.Param("novel_id")); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// @Summary 书单内排序
+// @Description 按给定的小说ID顺序排列书单中的小说，未列出的小说保持原位置
+// @Tags shelves
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Param shelf_id path string true "书单ID"
+// @Param request body ReorderRequest true "小说ID"
+// @Success 200 {object} response.Response "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "书单不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/shelves/{shelf_id}/order [put]
+func (h *NovelHandler) ReorderBookshelf(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	var req ReorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	if err := h.novelService.ReorderBookshelf(c.Request.Context(), deviceID, c.Param("shelf_id"), req.IDs); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// @Summary 查看分享的书单
+// @Description 通过分享编码查看公开的书单，书单取消公开后返回404
+// @Tags shelves
+// @Accept json
+// @Produce json
+// @Param code path string true "分享编码"
+// @Success 200 {object} response.Response{data=models.BookshelfDetail} "成功"
+// @Failure 404 {object} response.Response "书单不存在或未公开"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /shelves/shared/{code} [get]
+func (h *NovelHandler) GetSharedBookshelf(c *gin.Context) {
+	shelf, err := h.novelService.GetSharedBookshelf(c.Request.Context(), c.Param("code"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, shelf)
+}
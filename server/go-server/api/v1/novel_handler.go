@@ -12,6 +12,7 @@ package v1
 
 import (
 	"context"
+	"lightnovel/internal/models"
 	"lightnovel/internal/service"
 	"lightnovel/pkg/errors"
 	"lightnovel/pkg/response"
@@ -19,6 +20,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -44,6 +46,9 @@ import (
 // @tag.name favorites
 // @tag.description 用户收藏相关接口
 
+// @tag.name follows
+// @tag.description 关注小说、站内通知与通知偏好相关接口
+
 // @tag.name bookmarks
 // @tag.description 用户书签相关接口
 
@@ -123,6 +128,28 @@ func (h *NovelHandler) GetNovelByID(c *gin.Context) {
 	response.Success(c, novel)
 }
 
+// @Summary 获取相关小说
+// @Description 获取读过这部小说的读者也在读的小说，综合共读、标签重合和同一作者计算，由后台任务定期更新
+// @Tags novels
+// @Accept json
+// @Produce json
+// @Param id path string true "小说ID"
+// @Param limit query int false "限制数量" default(10) minimum(1) maximum(20)
+// @Success 200 {object} response.Response{data=[]models.RelatedNovel} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "小说不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /novels/{id}/related [get]
+func (h *NovelHandler) GetRelatedNovels(c *gin.Context) {
+	novels, err := h.novelService.GetRelatedNovels(c.Request.Context(), c.Param("id"), c.GetInt("limit"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, novels)
+}
+
 // @Summary 获取小说卷列表
 // @Description 获取指定小说的所有卷列表
 // @Tags novels
@@ -210,12 +237,16 @@ func (h *NovelHandler) GetChapterByNumber(c *gin.Context) {
 		return
 	}
 
-	// 异步增加阅读量
+	// 异步记录阅读，去重后缓冲在 Redis 中，由后台任务批量写入数据库
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		deviceID = c.ClientIP()
+	}
 	go func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		if err := h.novelService.IncrementNovelReadCount(ctx, novelID); err != nil {
-			log.Printf("Failed to increment read count for novel %s: %v", novelID, err)
+		if err := h.novelService.RecordRead(ctx, deviceID, novelID, volumeNumber, chapterNumber); err != nil {
+			log.Printf("Failed to record read for novel %s: %v", novelID, err)
 		}
 	}()
 
@@ -223,18 +254,32 @@ func (h *NovelHandler) GetChapterByNumber(c *gin.Context) {
 }
 
 // @Summary 搜索小说
-// @Description 根据关键词搜索小说
+// @Description 根据关键词全文搜索小说，按相关度排序并返回高亮片段，可按标签、状态、作者过滤
 // @Tags novels
 // @Accept json
 // @Produce json
-// @Param keyword query string true "搜索关键词"
+// @Param keyword query string false "搜索关键词，未提供过滤条件时必填，最多64个字符"
+// @Param tags query string false "标签，逗号分隔，需全部命中"
+// @Param status query string false "连载状态"
+// @Param author query string false "作者，完整匹配且不区分大小写"
 // @Param page query int false "页码" default(1)
 // @Param size query int false "每页数量" default(1000)
-// @Success 200 {object} response.Response
+// @Success 200 {object} response.Response{data=[]models.NovelSearchResult} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器内部错误"
 // @Router /novels/search [get]
 func (h *NovelHandler) SearchNovels(c *gin.Context) {
-	keyword := c.Query("keyword")
-	if keyword == "" {
+	opts := service.SearchOptions{
+		Keyword: strings.TrimSpace(c.Query("keyword")),
+		Status:  c.Query("status"),
+		Author:  strings.TrimSpace(c.Query("author")),
+	}
+	for _, tag := range strings.Split(c.Query("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			opts.Tags = append(opts.Tags, tag)
+		}
+	}
+	if opts.Keyword == "" && len(opts.Tags) == 0 && opts.Status == "" && opts.Author == "" {
 		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
 		return
 	}
@@ -248,8 +293,10 @@ func (h *NovelHandler) SearchNovels(c *gin.Context) {
 	if size < 1 || size > 1000 {
 		size = 1000
 	}
+	opts.Page = page
+	opts.Size = size
 
-	novels, total, err := h.novelService.SearchNovels(c.Request.Context(), keyword, page, size)
+	novels, total, err := h.novelService.SearchNovels(c.Request.Context(), opts)
 	if err != nil {
 		response.Error(c, err)
 		return
@@ -263,6 +310,34 @@ func (h *NovelHandler) SearchNovels(c *gin.Context) {
 	})
 }
 
+// @Summary 搜索章节正文
+// @Description 在指定小说的章节正文中搜索，返回命中章节、字符偏移和上下文，支持中文子串
+// @Tags novels
+// @Accept json
+// @Produce json
+// @Param id path string true "小说ID"
+// @Param q query string true "搜索内容，最多64个字符"
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(20)
+// @Success 200 {object} response.PageResponse{data=[]models.ChapterSearchResult} "成功，position为正文中的字符偏移"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "小说不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /novels/{id}/search [get]
+func (h *NovelHandler) SearchChapterContent(c *gin.Context) {
+	novelID := c.Param("id")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+
+	results, total, err := h.novelService.SearchChapterContent(c.Request.Context(), novelID, c.Query("q"), page, size)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.SuccessWithPage(c, total, page, size, results)
+}
+
 // @Summary 获取最新小说
 // @Description 获取最新更新的小说列表
 // @Tags novels
@@ -305,6 +380,152 @@ func (h *NovelHandler) GetPopularNovels(c *gin.Context) {
 	response.Success(c, novels)
 }
 
+// @Summary 获取评分榜
+// @Description 获取评分最高的小说列表，按贝叶斯平均分排序，避免评分人数很少的小说排在前面
+// @Tags novels
+// @Accept json
+// @Produce json
+// @Param limit query int false "限制数量" default(100) minimum(1) maximum(1000)
+// @Success 200 {object} response.Response{data=[]models.RatedNovel} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /novels/top-rated [get]
+func (h *NovelHandler) GetTopRatedNovels(c *gin.Context) {
+	limit := c.GetInt("limit")
+	novels, err := h.novelService.GetTopRatedNovels(c.Request.Context(), limit)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, novels)
+}
+
+// @Summary 获取排行榜
+// @Description 按时间窗口获取排行榜，窗口内的计数按天累计。飙升榜按阅读量相对上一个等长窗口的增长率排序
+// @Tags novels
+// @Accept json
+// @Produce json
+// @Param board query string false "榜单：reads 阅读、favorites 收藏、comments 评论、rising 飙升" default(reads)
+// @Param window query string false "时间窗口：day、week、month" default(week)
+// @Param limit query int false "限制数量" default(50) minimum(1) maximum(100)
+// @Success 200 {object} response.Response{data=[]models.RankedNovel} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /novels/rankings [get]
+func (h *NovelHandler) GetRankings(c *gin.Context) {
+	board := c.DefaultQuery("board", service.RankingReads)
+	window := c.DefaultQuery("window", service.WindowWeek)
+
+	novels, err := h.novelService.GetRankings(c.Request.Context(), board, window, c.GetInt("limit"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, novels)
+}
+
+// RateNovelRequest 评分请求
+type RateNovelRequest struct {
+	Score   int    `json:"score" binding:"required,min=1,max=10"`
+	Content string `json:"content" binding:"max=5000"` // 书评正文，可为空
+}
+
+// @Summary 评分
+// @Description 为小说评分(1-10)并可附带书评，重复提交时覆盖之前的评分
+// @Tags review
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "小说ID"
+// @Param body body RateNovelRequest true "评分和书评"
+// @Success 200 {object} response.Response{data=models.Review} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "小说不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /novels/{id}/rating [put]
+func (h *NovelHandler) RateNovel(c *gin.Context) {
+	var req RateNovelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrBadRequest))
+		return
+	}
+
+	review, err := h.novelService.RateNovel(c.Request.Context(), c.GetString("deviceID"), c.Param("id"), req.Score, req.Content)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, review)
+}
+
+// @Summary 获取我的评分
+// @Description 获取自己对小说的评分和书评，未评分时返回null
+// @Tags review
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "小说ID"
+// @Success 200 {object} response.Response{data=models.Review} "成功"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /novels/{id}/rating [get]
+func (h *NovelHandler) GetMyRating(c *gin.Context) {
+	review, err := h.novelService.GetMyRating(c.Request.Context(), c.GetString("deviceID"), c.Param("id"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, review)
+}
+
+// @Summary 删除评分
+// @Description 删除自己对小说的评分和书评
+// @Tags review
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "小说ID"
+// @Success 200 {object} response.Response "成功"
+// @Failure 404 {object} response.Response "未评分"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /novels/{id}/rating [delete]
+func (h *NovelHandler) DeleteRating(c *gin.Context) {
+	if err := h.novelService.DeleteRating(c.Request.Context(), c.GetString("deviceID"), c.Param("id")); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// @Summary 获取书评列表
+// @Description 分页获取小说的书评，只包含有正文的评分，按更新时间倒序
+// @Tags review
+// @Accept json
+// @Produce json
+// @Param id path string true "小说ID"
+// @Param page query int false "页码，默认1"
+// @Param size query int false "每页数量，默认20"
+// @Success 200 {object} response.Response{data=response.PageResponse{data=[]models.ReviewResponse}} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /novels/{id}/reviews [get]
+func (h *NovelHandler) GetReviews(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+
+	reviews, total, err := h.novelService.GetReviews(c.Request.Context(), c.Param("id"), page, size)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.SuccessWithPage(c, total, page, size, reviews)
+}
+
 // @Summary 获取阅读历史
 // @Description 获取用户的阅读历史列表
 // @Tags reading
@@ -448,9 +669,10 @@ func (h *NovelHandler) DeleteReadProgress(c *gin.Context) {
 
 // UpdateProgressRequest 更新阅读进度请求
 type UpdateProgressRequest struct {
-	VolumeNumber  int `json:"volumeNumber" binding:"required,min=1"`
-	ChapterNumber int `json:"chapterNumber" binding:"required,min=1"`
-	Position      int `json:"position" binding:"min=0"`
+	VolumeNumber  int        `json:"volumeNumber" binding:"required,min=1"`
+	ChapterNumber int        `json:"chapterNumber" binding:"required,min=1"`
+	Position      int        `json:"position" binding:"min=0"`
+	UpdatedAt     *time.Time `json:"updatedAt"` // 客户端修改时间，为空时使用服务端时间
 }
 
 // @Summary 更新阅读进度
@@ -487,6 +709,7 @@ func (h *NovelHandler) UpdateReadProgress(c *gin.Context) {
 		req.VolumeNumber,
 		req.ChapterNumber,
 		req.Position,
+		req.UpdatedAt,
 	)
 	if err != nil {
 		response.Error(c, err)
@@ -496,163 +719,364 @@ func (h *NovelHandler) UpdateReadProgress(c *gin.Context) {
 	response.Success(c, gin.H{"message": "更新成功"})
 }
 
-// @Summary 获取用户书签
-// @Description 获取用户的所有书签
-// @Tags bookmarks
+// StartSessionRequest 开始阅读会话请求
+type StartSessionRequest struct {
+	NovelID       string `json:"novelId" binding:"required"`
+	VolumeNumber  int    `json:"volumeNumber" binding:"required,min=1"`
+	ChapterNumber int    `json:"chapterNumber" binding:"required,min=1"`
+}
+
+// @Summary 开始阅读会话
+// @Description 打开章节时调用，返回的会话ID用于离开章节时结束会话
+// @Tags reading
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
-// @Param X-Device-ID header string false "设备ID，如果未提供则使用客户端IP"
-// @Success 200 {object} response.Response{data=[]models.Bookmark} "成功"
+// @Param X-Device-ID header string true "设备ID"
+// @Param request body StartSessionRequest true "阅读的章节"
+// @Success 200 {object} response.Response{data=models.ReadingSession} "成功"
 // @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "章节不存在"
 // @Failure 500 {object} response.Response "服务器内部错误"
-// @Router /user/bookmarks [get]
-func (h *NovelHandler) GetUserBookmarks(c *gin.Context) {
+// @Router /user/reading/sessions [post]
+func (h *NovelHandler) StartReadingSession(c *gin.Context) {
 	deviceID := c.GetString("deviceID")
-	bookmarks, err := h.novelService.GetUserBookmarks(c.Request.Context(), deviceID)
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	var req StartSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	session, err := h.novelService.StartReadingSession(c.Request.Context(), deviceID, req.NovelID, req.VolumeNumber, req.ChapterNumber)
 	if err != nil {
 		response.Error(c, err)
 		return
 	}
 
-	response.Success(c, bookmarks)
-}
-
-// CreateBookmarkRequest 创建书签请求
-type CreateBookmarkRequest struct {
-	NovelID       string `json:"novelId" binding:"required"`
-	VolumeNumber  int    `json:"volumeNumber" binding:"required"`
-	ChapterNumber int    `json:"chapterNumber" binding:"required"`
-	Position      int    `json:"position"`
-	Note          string `json:"note"`
+	response.Success(c, session)
 }
 
-// UpdateBookmarkRequest 更新书签请求
-type UpdateBookmarkRequest struct {
-	Note string `json:"note"`
+// EndSessionRequest 结束阅读会话请求
+type EndSessionRequest struct {
+	Characters *int `json:"characters" binding:"omitempty,min=0"` // 本次读过的字数，为空时视为读完整章
 }
 
-// @Summary 创建书签
-// @Description 在指定章节创建书签
-// @Tags bookmarks
+// @Summary 结束阅读会话
+// @Description 离开章节时调用，按开始到结束的时间计入阅读时长，单次最多计2小时
+// @Tags reading
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
-// @Param X-Device-ID header string false "设备ID，如果未提供则使用客户端IP"
-// @Param body body CreateBookmarkRequest true "书签信息"
-// @Success 200 {object} response.Response{data=models.Bookmark} "成功"
-// @Failure 400 {object} response.Response "参数错误"
-// @Failure 404 {object} response.Response "小说或章节不存在"
-// @Router /user/bookmarks [post]
-func (h *NovelHandler) CreateBookmark(c *gin.Context) {
-	var req CreateBookmarkRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+// @Param X-Device-ID header string true "设备ID"
+// @Param id path string true "会话ID"
+// @Param request body EndSessionRequest false "读过的字数"
+// @Success 200 {object} response.Response{data=models.ReadingSession} "成功"
+// @Failure 400 {object} response.Response "参数错误或会话已结束"
+// @Failure 404 {object} response.Response "会话不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/reading/sessions/{id} [put]
+func (h *NovelHandler) EndReadingSession(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
 		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
 		return
 	}
 
-	deviceID := c.GetString("deviceID")
-	bookmark, err := h.novelService.CreateBookmark(c.Request.Context(), deviceID, req.NovelID, req.VolumeNumber, req.ChapterNumber, req.Position, req.Note)
+	var req EndSessionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+			return
+		}
+	}
+
+	session, err := h.novelService.EndReadingSession(c.Request.Context(), deviceID, c.Param("id"), req.Characters)
 	if err != nil {
 		response.Error(c, err)
 		return
 	}
 
-	response.Success(c, bookmark)
+	response.Success(c, session)
 }
 
-// @Summary 删除书签
-// @Description 删除指定的书签
-// @Tags bookmarks
+// @Summary 获取阅读统计
+// @Description 获取总阅读时长、读过的章节数和字数、连续阅读天数、最近一年的阅读日历以及各小说的完成度
+// @Tags reading
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
-// @Param X-Device-ID header string false "设备ID，如果未提供则使用客户端IP"
-// @Param id path string true "书签ID"
-// @Success 200 {object} response.Response "删除成功"
+// @Param X-Device-ID header string true "设备ID"
+// @Success 200 {object} response.Response{data=models.ReadingStats} "成功"
 // @Failure 400 {object} response.Response "参数错误"
-// @Failure 404 {object} response.Response "书签不存在"
-// @Router /user/bookmarks/{id} [delete]
-func (h *NovelHandler) DeleteBookmark(c *gin.Context) {
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/stats [get]
+func (h *NovelHandler) GetReadingStats(c *gin.Context) {
 	deviceID := c.GetString("deviceID")
-	bookmarkID := c.Param("id")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
 
-	err := h.novelService.DeleteBookmark(c.Request.Context(), deviceID, bookmarkID)
+	stats, err := h.novelService.GetReadingStats(c.Request.Context(), deviceID)
 	if err != nil {
 		response.Error(c, err)
 		return
 	}
 
-	response.Success(c, gin.H{"message": "书签已删除"})
+	response.Success(c, stats)
 }
 
-// @Summary 更新书签
-// @Description 更新书签信息（如备注）
-// @Tags bookmarks
+// @Summary 获取书架
+// @Description 合并阅读历史、阅读进度和收藏，返回完成度、下一章以及上次阅读后新增的章节数。读到一半或有更新的小说按最后阅读时间排在最前，其次是未读的收藏，已读完的排在最后
+// @Tags reading
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
-// @Param X-Device-ID header string false "设备ID，如果未提供则使用客户端IP"
-// @Param id path string true "书签ID"
-// @Param body body UpdateBookmarkRequest true "更新信息"
-// @Success 200 {object} response.Response{data=models.Bookmark} "更新成功"
+// @Param X-Device-ID header string true "设备ID"
+// @Success 200 {object} response.Response{data=[]models.ShelfItem} "成功"
 // @Failure 400 {object} response.Response "参数错误"
-// @Failure 404 {object} response.Response "书签不存在"
-// @Router /user/bookmarks/{id} [put]
-func (h *NovelHandler) UpdateBookmark(c *gin.Context) {
-	var req UpdateBookmarkRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/shelf [get]
+func (h *NovelHandler) GetShelf(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
 		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
 		return
 	}
 
-	deviceID := c.GetString("deviceID")
-	bookmarkID := c.Param("id")
-
-	bookmark, err := h.novelService.UpdateBookmark(c.Request.Context(), deviceID, bookmarkID, req.Note)
+	shelf, err := h.novelService.GetShelf(c.Request.Context(), deviceID)
 	if err != nil {
 		response.Error(c, err)
 		return
 	}
 
-	response.Success(c, bookmark)
-}
-
-// getDeviceAndNovelID 从上下文和参数中获取设备ID和小说ID
-func (h *NovelHandler) getDeviceAndNovelID(c *gin.Context) (string, string, error) {
-	deviceID := c.GetString("deviceID")
-	if deviceID == "" {
-		return "", "", errors.NewError(errors.ErrInvalidParameter)
-	}
-
-	novelID := c.Param("novel_id")
-	if novelID == "" {
-		return "", "", errors.NewError(errors.ErrInvalidParameter)
-	}
-
-	return deviceID, novelID, nil
+	response.Success(c, shelf)
 }
 
-// @Summary 获取用户收藏列表
-// @Description 获取用户收藏的小说列表
-// @Tags favorites
+// @Summary 同步阅读数据
+// @Description 批量提交离线期间的阅读进度、阅读历史和书签变更，并分页返回游标之后服务端的变更，hasMore为true时应以返回的cursor继续同步。冲突按配置的规则处理，被拒绝的变更计入rejected
+// @Tags reading
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
 // @Param X-Device-ID header string true "设备ID"
-// @Success 200 {object} response.Response{data=[]models.Novel} "成功"
-// @Failure 400 {object} response.Response "请求参数错误"
+// @Param request body models.SyncRequest true "离线变更和上次同步的游标"
+// @Success 200 {object} response.Response{data=models.SyncResponse} "成功"
+// @Failure 400 {object} response.Response "参数错误"
 // @Failure 500 {object} response.Response "服务器内部错误"
-// @Router /user/favorites [get]
-func (h *NovelHandler) GetUserFavorites(c *gin.Context) {
+// @Router /user/sync [post]
+func (h *NovelHandler) SyncUserData(c *gin.Context) {
 	deviceID := c.GetString("deviceID")
 	if deviceID == "" {
 		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
 		return
 	}
 
-	novels, err := h.novelService.GetUserFavorites(c.Request.Context(), deviceID)
-	if err != nil {
+	var req models.SyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	result, err := h.novelService.Sync(c.Request.Context(), deviceID, &req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// @Summary 获取个性化推荐
+// @Description 根据阅读历史、收藏和标签偏好推荐小说，每条推荐附带推荐理由。没有阅读记录时返回热门小说
+// @Tags reading
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Param limit query int false "限制数量" default(20) minimum(1) maximum(100)
+// @Success 200 {object} response.Response{data=[]models.RecommendedNovel} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/recommendations [get]
+func (h *NovelHandler) GetRecommendations(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	novels, err := h.novelService.GetRecommendations(c.Request.Context(), deviceID, c.GetInt("limit"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, novels)
+}
+
+// @Summary 获取用户书签
+// @Description 获取用户的所有书签
+// @Tags bookmarks
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string false "设备ID，如果未提供则使用客户端IP"
+// @Success 200 {object} response.Response{data=[]models.Bookmark} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/bookmarks [get]
+func (h *NovelHandler) GetUserBookmarks(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	bookmarks, err := h.novelService.GetUserBookmarks(c.Request.Context(), deviceID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, bookmarks)
+}
+
+// CreateBookmarkRequest 创建书签请求
+type CreateBookmarkRequest struct {
+	NovelID       string `json:"novelId" binding:"required"`
+	VolumeNumber  int    `json:"volumeNumber" binding:"required"`
+	ChapterNumber int    `json:"chapterNumber" binding:"required"`
+	Position      int    `json:"position"`
+	Note          string `json:"note"`
+}
+
+// UpdateBookmarkRequest 更新书签请求
+type UpdateBookmarkRequest struct {
+	Note string `json:"note"`
+}
+
+// @Summary 创建书签
+// @Description 在指定章节创建书签
+// @Tags bookmarks
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string false "设备ID，如果未提供则使用客户端IP"
+// @Param body body CreateBookmarkRequest true "书签信息"
+// @Success 200 {object} response.Response{data=models.Bookmark} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "小说或章节不存在"
+// @Router /user/bookmarks [post]
+func (h *NovelHandler) CreateBookmark(c *gin.Context) {
+	var req CreateBookmarkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	deviceID := c.GetString("deviceID")
+	bookmark, err := h.novelService.CreateBookmark(c.Request.Context(), deviceID, req.NovelID, req.VolumeNumber, req.ChapterNumber, req.Position, req.Note)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, bookmark)
+}
+
+// @Summary 删除书签
+// @Description 删除指定的书签
+// @Tags bookmarks
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string false "设备ID，如果未提供则使用客户端IP"
+// @Param id path string true "书签ID"
+// @Success 200 {object} response.Response "删除成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "书签不存在"
+// @Router /user/bookmarks/{id} [delete]
+func (h *NovelHandler) DeleteBookmark(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	bookmarkID := c.Param("id")
+
+	err := h.novelService.DeleteBookmark(c.Request.Context(), deviceID, bookmarkID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "书签已删除"})
+}
+
+// @Summary 更新书签
+// @Description 更新书签信息（如备注）
+// @Tags bookmarks
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string false "设备ID，如果未提供则使用客户端IP"
+// @Param id path string true "书签ID"
+// @Param body body UpdateBookmarkRequest true "更新信息"
+// @Success 200 {object} response.Response{data=models.Bookmark} "更新成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "书签不存在"
+// @Router /user/bookmarks/{id} [put]
+func (h *NovelHandler) UpdateBookmark(c *gin.Context) {
+	var req UpdateBookmarkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	deviceID := c.GetString("deviceID")
+	bookmarkID := c.Param("id")
+
+	bookmark, err := h.novelService.UpdateBookmark(c.Request.Context(), deviceID, bookmarkID, req.Note)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, bookmark)
+}
+
+// getDeviceAndNovelID 从上下文和参数中获取设备ID和小说ID
+func (h *NovelHandler) getDeviceAndNovelID(c *gin.Context) (string, string, error) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		return "", "", errors.NewError(errors.ErrInvalidParameter)
+	}
+
+	novelID := c.Param("novel_id")
+	if novelID == "" {
+		return "", "", errors.NewError(errors.ErrInvalidParameter)
+	}
+
+	return deviceID, novelID, nil
+}
+
+// @Summary 获取用户收藏列表
+// @Description 获取用户收藏的小说列表
+// @Tags favorites
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Success 200 {object} response.Response{data=[]models.Novel} "成功"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/favorites [get]
+func (h *NovelHandler) GetUserFavorites(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	novels, err := h.novelService.GetUserFavorites(c.Request.Context(), deviceID)
+	if err != nil {
 		response.Error(c, err)
 		return
 	}
@@ -749,6 +1173,260 @@ func (h *NovelHandler) IsFavorite(c *gin.Context) {
 	})
 }
 
+// @Summary 获取关注列表
+// @Description 获取关注的小说，按关注时间倒序。关注或收藏的小说更新时会通过WebSocket收到通知
+// @Tags follows
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Success 200 {object} response.Response{data=[]models.Follow} "成功"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/follows [get]
+func (h *NovelHandler) GetFollows(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	follows, err := h.novelService.GetFollows(c.Request.Context(), deviceID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, follows)
+}
+
+// @Summary 关注小说
+// @Description 关注小说，小说有新章节或新卷时收到通知
+// @Tags follows
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Param novel_id path string true "小说ID"
+// @Success 200 {object} response.Response "成功"
+// @Failure 400 {object} response.Response "请求参数错误或已关注"
+// @Failure 404 {object} response.Response "小说不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/follows/{novel_id} [post]
+func (h *NovelHandler) FollowNovel(c *gin.Context) {
+	deviceID, novelID, err := h.getDeviceAndNovelID(c)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	if err := h.novelService.FollowNovel(c.Request.Context(), deviceID, novelID); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "关注成功"})
+}
+
+// @Summary 取消关注
+// @Description 取消关注小说，已收藏的小说仍会收到更新通知
+// @Tags follows
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Param novel_id path string true "小说ID"
+// @Success 200 {object} response.Response "成功"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 404 {object} response.Response "未关注"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/follows/{novel_id} [delete]
+func (h *NovelHandler) UnfollowNovel(c *gin.Context) {
+	deviceID, novelID, err := h.getDeviceAndNovelID(c)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	if err := h.novelService.UnfollowNovel(c.Request.Context(), deviceID, novelID); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "取消关注成功"})
+}
+
+// UpdateNotificationPrefsRequest 修改通知偏好请求，为空的项不修改
+type UpdateNotificationPrefsRequest struct {
+	NewChapter    *bool `json:"newChapter"`
+	NewVolume     *bool `json:"newVolume"`
+	ContentUpdate *bool `json:"contentUpdate"`
+	CommentReply  *bool `json:"commentReply"`
+}
+
+// @Summary 获取通知偏好
+// @Description 获取新章节、新卷和评论回复通知的开关，未设置过时全部开启
+// @Tags follows
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Success 200 {object} response.Response{data=models.NotificationPrefs} "成功"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/notifications/preferences [get]
+func (h *NovelHandler) GetNotificationPrefs(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	prefs, err := h.novelService.GetNotificationPrefs(c.Request.Context(), deviceID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, prefs)
+}
+
+// @Summary 修改通知偏好
+// @Description 修改新章节、新卷、内容修订和评论回复通知的开关
+// @Tags follows
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Param request body UpdateNotificationPrefsRequest true "要修改的开关"
+// @Success 200 {object} response.Response{data=models.NotificationPrefs} "成功"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/notifications/preferences [put]
+func (h *NovelHandler) UpdateNotificationPrefs(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	var req UpdateNotificationPrefsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	prefs, err := h.novelService.UpdateNotificationPrefs(c.Request.Context(), deviceID, req.NewChapter, req.NewVolume, req.ContentUpdate, req.CommentReply)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, prefs)
+}
+
+// NotificationPage 通知分页响应，附带未读数
+type NotificationPage struct {
+	response.PageResponse
+	Unread int64 `json:"unread"`
+}
+
+// @Summary 获取通知列表
+// @Description 分页获取小说更新、评论回复和系统通知，按时间倒序，同时返回未读数
+// @Tags follows
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Param page query int false "页码" default(1) minimum(1)
+// @Param size query int false "每页数量" default(20) minimum(1) maximum(1000)
+// @Success 200 {object} response.Response{data=NotificationPage{data=[]models.Notification}} "成功"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/notifications [get]
+func (h *NovelHandler) GetNotifications(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+
+	notifications, total, unread, err := h.novelService.GetNotifications(c.Request.Context(), deviceID, page, size)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, NotificationPage{
+		PageResponse: response.PageResponse{
+			Total:   total,
+			Page:    page,
+			Size:    size,
+			HasNext: page*size < int(total),
+			Data:    notifications,
+		},
+		Unread: unread,
+	})
+}
+
+// @Summary 标记通知已读
+// @Description 将一条通知标记为已读
+// @Tags follows
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Param id path string true "通知ID"
+// @Success 200 {object} response.Response "成功"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 404 {object} response.Response "通知不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/notifications/{id}/read [put]
+func (h *NovelHandler) MarkNotificationRead(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	if err := h.novelService.MarkNotificationRead(c.Request.Context(), deviceID, c.Param("id")); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// @Summary 全部标记已读
+// @Description 将所有未读通知标记为已读，返回标记的数量
+// @Tags follows
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string true "设备ID"
+// @Success 200 {object} response.Response{data=map[string]int64} "成功"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /user/notifications/read [put]
+func (h *NovelHandler) MarkAllNotificationsRead(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		response.Error(c, errors.NewError(errors.ErrInvalidParameter))
+		return
+	}
+
+	count, err := h.novelService.MarkAllNotificationsRead(c.Request.Context(), deviceID)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"marked": count})
+}
+
 // UpsertHistoryRequest 添加或更新阅读历史请求
 type UpsertHistoryRequest struct {
 	LastRead *time.Time `json:"lastRead"` // 可选,不传则使用当前时间
@@ -802,7 +1480,9 @@ type UpdateUserRequest struct {
 
 // CreateCommentRequest 创建评论请求
 type CreateCommentRequest struct {
-	Content string `json:"content" binding:"required,min=1,max=500"`
+	Content  string                `json:"content" binding:"required,min=1,max=500"`
+	ParentID string                `json:"parentId"` // 回复的评论ID，为空时发表顶层评论
+	Anchor   *models.CommentAnchor `json:"anchor"`   // 段评位置，只需提供paragraph、start、end
 }
 
 // GetUserProfile 获取用户资料
@@ -859,13 +1539,15 @@ func (h *NovelHandler) UpdateUserProfile(c *gin.Context) {
 
 // GetComments 获取章节评论
 // @Summary 获取章节评论
-// @Description 获取指定章节的评论列表
+// @Description 获取指定章节的顶层评论列表，置顶评论排在最前，回复通过回复列表接口获取
 // @Tags comment
 // @Accept json
 // @Produce json
 // @Param id path string true "小说ID"
 // @Param volume path int true "卷号"
 // @Param chapter path int true "章节号"
+// @Param paragraph query int false "段落序号，提供时返回该段的段评，否则返回章评"
+// @Param sort query string false "排序方式：hot/new/top，默认new"
 // @Param page query int false "页码，默认1"
 // @Param size query int false "每页数量，默认1000"
 // @Success 200 {object} response.Response{data=response.PageResponse{data=[]models.CommentResponse}} "成功"
@@ -889,7 +1571,19 @@ func (h *NovelHandler) GetComments(c *gin.Context) {
 	page := utils.GetIntQuery(c, "page", 1)
 	size := utils.GetIntQuery(c, "size", 1000)
 
-	comments, total, err := h.novelService.GetComments(c.Request.Context(), novelID, volumeNumber, chapterNumber, page, size)
+	sort := c.DefaultQuery("sort", service.CommentSortNew)
+
+	var paragraph *int
+	if value, ok := c.GetQuery("paragraph"); ok {
+		p, err := strconv.Atoi(value)
+		if err != nil || p < 0 {
+			response.Error(c, errors.NewError(errors.ErrBadRequest))
+			return
+		}
+		paragraph = &p
+	}
+
+	comments, total, err := h.novelService.GetComments(c.Request.Context(), c.GetString("deviceID"), novelID, volumeNumber, chapterNumber, paragraph, sort, page, size)
 	if err != nil {
 		response.Error(c, err)
 		return
@@ -898,9 +1592,44 @@ func (h *NovelHandler) GetComments(c *gin.Context) {
 	response.SuccessWithPage(c, total, page, size, comments)
 }
 
+// GetParagraphCommentCounts 获取段评数量
+// @Summary 获取段评数量
+// @Description 一次获取章节各段落的段评数量(含回复)，没有段评的段落不返回
+// @Tags comment
+// @Accept json
+// @Produce json
+// @Param id path string true "小说ID"
+// @Param volume path int true "卷号"
+// @Param chapter path int true "章节号"
+// @Success 200 {object} response.Response{data=[]models.ParagraphCommentCount} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /novels/{id}/volumes/{volume}/chapters/{chapter}/comments/paragraphs [get]
+func (h *NovelHandler) GetParagraphCommentCounts(c *gin.Context) {
+	volumeNumber, err := strconv.Atoi(c.Param("volume"))
+	if err != nil {
+		response.Error(c, errors.NewError(errors.ErrBadRequest))
+		return
+	}
+
+	chapterNumber, err := strconv.Atoi(c.Param("chapter"))
+	if err != nil {
+		response.Error(c, errors.NewError(errors.ErrBadRequest))
+		return
+	}
+
+	counts, err := h.novelService.GetParagraphCommentCounts(c.Request.Context(), c.Param("id"), volumeNumber, chapterNumber)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, counts)
+}
+
 // CreateComment 发表评论
 // @Summary 发表评论
-// @Description 在指定章节发表评论
+// @Description 在指定章节发表评论，提供parentId时作为回复，回复的回复会归入同一顶层评论下；提供anchor时作为段评
 // @Tags comment
 // @Accept json
 // @Produce json
@@ -935,7 +1664,7 @@ func (h *NovelHandler) CreateComment(c *gin.Context) {
 		return
 	}
 
-	comment, err := h.novelService.CreateComment(c.Request.Context(), deviceID, novelID, volumeNumber, chapterNumber, req.Content)
+	comment, err := h.novelService.CreateComment(c.Request.Context(), deviceID, novelID, volumeNumber, chapterNumber, req.Content, req.ParentID, req.Anchor)
 	if err != nil {
 		response.Error(c, err)
 		return
@@ -946,7 +1675,7 @@ func (h *NovelHandler) CreateComment(c *gin.Context) {
 
 // DeleteComment 删除评论
 // @Summary 删除评论
-// @Description 删除自己发表的评论
+// @Description 删除自己发表的评论，删除顶层评论时一并删除其回复
 // @Tags comment
 // @Accept json
 // @Produce json
@@ -970,6 +1699,114 @@ func (h *NovelHandler) DeleteComment(c *gin.Context) {
 	response.Success(c, nil)
 }
 
+// ReportCommentRequest 举报评论请求
+type ReportCommentRequest struct {
+	Reason string `json:"reason" binding:"required,max=200"`
+}
+
+// ReportComment 举报评论
+// @Summary 举报评论
+// @Description 举报违规评论，每个用户对同一评论只能举报一次，举报数达到阈值时评论自动隐藏待审核
+// @Tags comment
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param comment_id path string true "评论ID"
+// @Param body body ReportCommentRequest true "举报原因"
+// @Success 200 {object} response.Response "成功"
+// @Failure 400 {object} response.Response "参数错误或已举报"
+// @Failure 404 {object} response.Response "评论不存在"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /comments/{comment_id}/report [post]
+func (h *NovelHandler) ReportComment(c *gin.Context) {
+	var req ReportCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errors.NewError(errors.ErrBadRequest))
+		return
+	}
+
+	err := h.novelService.ReportComment(c.Request.Context(), c.GetString("deviceID"), c.Param("comment_id"), req.Reason)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+// GetCommentReplies 获取评论回复
+// @Summary 获取评论回复
+// @Description 分页获取顶层评论下的回复，按发布时间正序
+// @Tags comment
+// @Accept json
+// @Produce json
+// @Param comment_id path string true "评论ID"
+// @Param page query int false "页码，默认1"
+// @Param size query int false "每页数量，默认20"
+// @Success 200 {object} response.Response{data=response.PageResponse{data=[]models.CommentResponse}} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "评论不存在"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /comments/{comment_id}/replies [get]
+func (h *NovelHandler) GetCommentReplies(c *gin.Context) {
+	page := utils.GetIntQuery(c, "page", 1)
+	size := utils.GetIntQuery(c, "size", 20)
+
+	replies, total, err := h.novelService.GetCommentReplies(c.Request.Context(), c.GetString("deviceID"), c.Param("comment_id"), page, size)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.SuccessWithPage(c, total, page, size, replies)
+}
+
+// LikeComment 点赞评论
+// @Summary 点赞评论
+// @Description 点赞评论，每个用户对同一评论只计一次
+// @Tags comment
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param comment_id path string true "评论ID"
+// @Success 200 {object} response.Response{data=models.CommentLikeResponse} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "评论不存在"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /comments/{comment_id}/like [post]
+func (h *NovelHandler) LikeComment(c *gin.Context) {
+	result, err := h.novelService.LikeComment(c.Request.Context(), c.GetString("deviceID"), c.Param("comment_id"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// UnlikeComment 取消点赞评论
+// @Summary 取消点赞评论
+// @Description 取消对评论的点赞
+// @Tags comment
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param comment_id path string true "评论ID"
+// @Success 200 {object} response.Response{data=models.CommentLikeResponse} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "评论不存在"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /comments/{comment_id}/like [delete]
+func (h *NovelHandler) UnlikeComment(c *gin.Context) {
+	result, err := h.novelService.UnlikeComment(c.Request.Context(), c.GetString("deviceID"), c.Param("comment_id"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
 // @Summary 上传用户头像
 // @Description 上传用户头像图片文件
 // @Tags user
@@ -11,10 +11,13 @@
 package v1
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"lightnovel/config"
+	"lightnovel/internal/service"
+	"lightnovel/pkg/errors"
+	"lightnovel/pkg/response"
 	ws "lightnovel/pkg/websocket"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -23,26 +26,8 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-// WSMessage WebSocket消息结构
-type WSMessage struct {
-	Type string      `json:"type"` // "novel_update", "system_notice"
-	Data interface{} `json:"data"`
-	Time time.Time   `json:"time"`
-}
-
-// NovelUpdate 小说更新消息
-type NovelUpdate struct {
-	NovelID     string `json:"novelId"`
-	Title       string `json:"title"`
-	UpdateType  string `json:"updateType"` // "new_chapter", "new_volume", "content_update"
-	Description string `json:"description"`
-}
-
-// SystemNotice 系统通知消息
-type SystemNotice struct {
-	Level   string `json:"level"`   // "info", "warning", "error"
-	Content string `json:"content"` // 通知内容
-}
+// ackTimeout 处理通知确认的超时时间
+const ackTimeout = 5 * time.Second
 
 var (
 	upgrader = websocket.Upgrader{
@@ -56,17 +41,26 @@ var (
 
 // WebSocketHandler 处理WebSocket连接
 type WebSocketHandler struct {
-	hub *ws.Hub
-	cfg *config.Config
+	hub          *ws.Hub
+	novelService *service.NovelService
+	cfg          *config.Config
 }
 
-// NewWebSocketHandler 创建WebSocket处理器
-func NewWebSocketHandler(cfg *config.Config) *WebSocketHandler {
-	hub := ws.NewHub()
-	go hub.Run()
+// NewWebSocketHandler 创建WebSocket处理器，需在建立连接前调用
+func NewWebSocketHandler(hub *ws.Hub, novelService *service.NovelService, cfg *config.Config) *WebSocketHandler {
+	// 客户端确认收到的通知标记为已读
+	hub.OnAck = func(deviceID string, ids []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), ackTimeout)
+		defer cancel()
+		if _, err := novelService.AckNotifications(ctx, deviceID, ids); err != nil {
+			log.Printf("Failed to ack notifications of %s: %v", deviceID, err)
+		}
+	}
+
 	return &WebSocketHandler{
-		hub: hub,
-		cfg: cfg,
+		hub:          hub,
+		novelService: novelService,
+		cfg:          cfg,
 	}
 }
 
@@ -88,7 +82,11 @@ func NewWebSocketHandler(cfg *config.Config) *WebSocketHandler {
 // @tag.description WebSocket相关接口
 
 // @Summary WebSocket连接
-// @Description 建立WebSocket连接以接收实时更新通知
+// @Description 建立WebSocket连接以接收实时更新通知，连接后会补发离线期间未推送的通知。
+// @Description 客户端可发送JSON消息：{"type":"subscribe","topic":"novel:<小说ID>"}或"chapter:<小说ID>:<卷号>:<章节号>"订阅主题，
+// @Description {"type":"unsubscribe","topic":...}取消订阅，{"type":"ack","ids":[通知ID]}确认通知并标记已读，{"type":"ping"}心跳，
+// @Description {"type":"presence","topic":"chapter:..."}告知正在阅读的章节（topic为空表示停止阅读），订阅了小说或章节主题的连接会定期收到presence在读人数消息，订阅小说主题的连接会实时收到不带id的novel_update更新消息，订阅章节主题的连接会实时收到comment_created、comment_deleted、comment_updated评论消息。
+// @Description 服务端分别回复subscribed、unsubscribed、acked、pong、presence_updated，不合法或未知类型的消息回复error，单条消息不超过512字节
 // @Tags websocket
 // @Accept json
 // @Produce json
@@ -116,70 +114,101 @@ func (h *WebSocketHandler) HandleConnection(c *gin.Context) {
 
 	go client.WritePump()
 	go client.ReadPump()
+
+	// 补发离线期间的通知
+	go h.novelService.DeliverPendingNotifications(context.Background(), deviceID)
 }
 
-// @Summary 获取WebSocket状态
-// @Description 获取当前WebSocket连接的状态信息
+// @Summary SSE事件流
+// @Description 以text/event-stream推送与WebSocket相同的消息，供无法建立WebSocket连接的客户端使用，连接后会补发离线期间未推送的通知。
+// @Description 每条消息为一行data JSON，带id的广播和主题消息可在重连时通过Last-Event-ID头（或lastEventId参数）从短期事件日志续传，点赞数等只反映最新状态的消息不续传，通知在每次连接时从通知列表补发。
+// @Description 服务端每15秒发送一次注释行作为心跳
 // @Tags websocket
-// @Accept json
-// @Produce json
-// @Success 200 {object} response.Response{data=map[string]interface{}} "成功"
-// @Failure 500 {object} response.Response "服务器内部错误"
-// @Router /api/v1/ws/status [get]
-func (h *WebSocketHandler) GetStatus(c *gin.Context) {
-	status := map[string]interface{}{
-		"connections":  h.hub.GetActiveConnections(),
-		"messagesSent": h.hub.GetMessagesSent(),
-		"uptime":       time.Since(h.hub.GetStartTime()).String(),
+// @Produce text/event-stream
+// @Security ApiKeyAuth
+// @Param X-Device-ID header string false "设备ID，如果未提供则使用客户端IP"
+// @Param Last-Event-ID header string false "最后收到的事件ID"
+// @Param lastEventId query string false "最后收到的事件ID，未提供Last-Event-ID头时使用"
+// @Param topics query string false "订阅的主题，多个用逗号分隔，格式同WebSocket的subscribe消息"
+// @Success 200 {string} string "事件流"
+// @Failure 400 {object} response.Response "无效的参数"
+// @Router /api/v1/events [get]
+func (h *WebSocketHandler) HandleEvents(c *gin.Context) {
+	deviceID := c.GetString("deviceID")
+	if deviceID == "" {
+		deviceID = c.ClientIP()
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"code": 0,
-		"data": status,
-	})
-}
 
-// BroadcastNovelUpdate 广播小说更新消息
-func (h *WebSocketHandler) BroadcastNovelUpdate(novelID string, title string, updateType string) {
-	update := NovelUpdate{
-		NovelID:     novelID,
-		Title:       title,
-		UpdateType:  updateType,
-		Description: getUpdateDescription(updateType, title),
+	topics, err := ws.ParseTopics(c.Query("topics"))
+	if err != nil {
+		response.Error(c, errors.NewErrorWithMessage(errors.ErrInvalidParameter, err.Error()))
+		return
 	}
 
-	msg := WSMessage{
-		Type: "novel_update",
-		Data: update,
-		Time: time.Now(),
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("lastEventId")
 	}
-
-	msgBytes, err := json.Marshal(msg)
-	if err != nil {
+	if lastEventID != "" && !ws.ValidEventID(lastEventID) {
+		response.Error(c, errors.NewErrorWithMessage(errors.ErrInvalidParameter, "无效的事件ID"))
 		return
 	}
 
-	h.hub.Broadcast <- msgBytes
-}
+	// 补发离线期间的通知
+	go h.novelService.DeliverPendingNotifications(context.Background(), deviceID)
 
-// BroadcastSystemNotice 广播系统通知
-func (h *WebSocketHandler) BroadcastSystemNotice(level string, content string) {
-	notice := SystemNotice{
-		Level:   level,
-		Content: content,
-	}
+	h.hub.ServeEvents(c.Writer, c.Request, ws.StreamOptions{
+		DeviceID:    deviceID,
+		Topics:      topics,
+		LastEventID: lastEventID,
+	})
+}
 
-	msg := WSMessage{
-		Type: "system_notice",
-		Data: notice,
-		Time: time.Now(),
+// @Summary 获取在读人数
+// @Description 获取小说当前的在读人数及各章节的在读人数，阅读状态由客户端通过WebSocket的presence消息上报，同一设备只计一次
+// @Tags websocket
+// @Accept json
+// @Produce json
+// @Param id path string true "小说ID"
+// @Success 200 {object} response.Response{data=ws.NovelPresence} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 404 {object} response.Response "小说不存在"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /novels/{id}/presence [get]
+func (h *WebSocketHandler) GetPresence(c *gin.Context) {
+	novelID := c.Param("id")
+	if _, err := h.novelService.GetNovelByID(c.Request.Context(), novelID); err != nil {
+		response.Error(c, err)
+		return
 	}
 
-	msgBytes, err := json.Marshal(msg)
+	presence, err := h.hub.Presence(c.Request.Context(), novelID)
 	if err != nil {
+		response.Error(c, errors.NewError(errors.ErrInternalServer))
 		return
 	}
 
-	h.hub.Broadcast <- msgBytes
+	response.Success(c, presence)
+}
+
+// @Summary 获取WebSocket状态
+// @Description 获取当前WebSocket连接的状态信息，包括连接数、在线设备数、主题数、待投递消息数以及因队列已满丢弃的消息数
+// @Tags websocket
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=map[string]interface{}} "成功"
+// @Failure 500 {object} response.Response "服务器内部错误"
+// @Router /api/v1/ws/status [get]
+func (h *WebSocketHandler) GetStatus(c *gin.Context) {
+	status := map[string]interface{}{
+		"hub":    h.hub.Stats(),
+		"node":   h.hub.NodeID(),
+		"uptime": time.Since(h.hub.GetStartTime()).String(),
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": status,
+	})
 }
 
 // 辅助函数：检查origin是否在允许列表中
@@ -194,17 +223,3 @@ func isAllowedOrigin(origin string, allowedOrigins []string) bool {
 	}
 	return false
 }
-
-// 辅助函数：根据更新类型生成描述
-func getUpdateDescription(updateType string, title string) string {
-	switch updateType {
-	case "new_chapter":
-		return fmt.Sprintf("《%s》有新章节更新啦！", title)
-	case "new_volume":
-		return fmt.Sprintf("《%s》新卷发布！", title)
-	case "content_update":
-		return fmt.Sprintf("《%s》内容已更新", title)
-	default:
-		return fmt.Sprintf("《%s》有新的更新", title)
-	}
-}
@@ -0,0 +1,143 @@
+// ****************************************************************************
+//
+// @file       main.go
+// @brief      EPUB导入命令，替代 scripts/novel_processor 的Python流程
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"lightnovel/config"
+	"lightnovel/internal/ingest"
+	"lightnovel/pkg/cache"
+	"lightnovel/pkg/database"
+	"lightnovel/pkg/epub"
+)
+
+func main() {
+	novelsDir := flag.String("dir", "../novels", "图片输出根目录，与服务端静态目录一致")
+	tags := flag.String("tags", "", "新建小说的标签，逗号分隔")
+	status := flag.String("status", "", "新建小说的状态")
+	dryRun := flag.Bool("dry-run", false, "只输出差异，不写入数据库和文件")
+	prune := flag.Bool("prune", false, "删除EPUB中已不存在的卷和章节")
+	verbose := flag.Bool("v", false, "同时列出未变化的章节")
+	noCache := flag.Bool("no-cache", false, "导入后不清理Redis缓存")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: %s [选项] <文件.epub|目录>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	files, err := collectFiles(flag.Args())
+	if err != nil {
+		log.Fatalf("Failed to collect epub files: %v", err)
+	}
+	if len(files) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// 加载配置
+	cfg := config.LoadConfig()
+
+	// 连接数据库
+	db, err := database.NewMongoDB(cfg.Database.URI, cfg.Database.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if err := db.CreateIndexes(ctx); err != nil {
+		log.Printf("Warning: Failed to create indexes: %v", err)
+	}
+
+	// 连接缓存，用于导入后清理旧数据
+	var c cache.Cache
+	if !*noCache && !*dryRun {
+		redisAddr := fmt.Sprintf("%s:%s", cfg.Redis.Host, cfg.Redis.Port)
+		multiLevelCache, err := cache.NewMultiLevelCache(redisAddr, cfg.Redis.Password, cfg.Redis.DB, "lightnovel:")
+		if err != nil {
+			log.Fatalf("Failed to create cache: %v", err)
+		}
+		defer multiLevelCache.Close()
+		c = multiLevelCache
+	}
+
+	opts := ingest.Options{
+		NovelsDir: *novelsDir,
+		Status:    *status,
+		DryRun:    *dryRun,
+		Prune:     *prune,
+	}
+	if *tags != "" {
+		for _, tag := range strings.Split(*tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				opts.Tags = append(opts.Tags, tag)
+			}
+		}
+	}
+	importer := ingest.NewImporter(db, c, opts)
+
+	failed := 0
+	for _, file := range files {
+		book, err := epub.Open(file)
+		if err != nil {
+			log.Printf("Failed to parse %s: %v", file, err)
+			failed++
+			continue
+		}
+
+		report, err := importer.Import(ctx, book)
+		if err != nil {
+			log.Printf("Failed to import %s: %v", file, err)
+			failed++
+			continue
+		}
+		report.Print(os.Stdout, *verbose)
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// collectFiles 展开参数中的目录，收集所有.epub文件
+func collectFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.EqualFold(filepath.Ext(p), ".epub") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
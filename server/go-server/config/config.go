@@ -11,6 +11,7 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"time"
 
@@ -23,6 +24,13 @@ type Config struct {
 	Redis    RedisConfig    `mapstructure:"redis"`
 	Cache    CacheConfig    `mapstructure:"cache"`
 	Rate     RateConfig     `mapstructure:"rate"`
+	Admin    AdminConfig    `mapstructure:"admin"`
+	Auth     AuthConfig     `mapstructure:"auth"`
+	Sync     SyncConfig     `mapstructure:"sync"`
+	Moderate ModerateConfig `mapstructure:"moderation"`
+	Jobs     JobsConfig     `mapstructure:"jobs"`
+	Reads    ReadsConfig    `mapstructure:"reads"`
+	Socket   SocketConfig   `mapstructure:"websocket"`
 }
 
 type ServerConfig struct {
@@ -72,6 +80,51 @@ type RateConfig struct {
 	Window time.Duration `mapstructure:"window"` // 时间窗口
 }
 
+type AdminConfig struct {
+	Token string `mapstructure:"token"` // 管理接口令牌，为空时禁用管理接口
+}
+
+type AuthConfig struct {
+	TokenTTL time.Duration `mapstructure:"tokenTTL"` // 登录令牌有效期
+}
+
+// SyncConfig 多设备同步时各字段的冲突规则，取值为 lww(以客户端修改时间晚者为准) 或 furthest
+type SyncConfig struct {
+	Position string `mapstructure:"position"` // 阅读进度位置，furthest 表示保留读得最远的进度
+	LastRead string `mapstructure:"lastRead"` // 阅读历史时间，furthest 表示保留最晚的阅读时间
+	Bookmark string `mapstructure:"bookmark"` // 书签内容，仅支持 lww
+}
+
+// ModerateConfig 评论审核配置
+type ModerateConfig struct {
+	Words           []string `mapstructure:"words"`           // 敏感词
+	WordsFile       string   `mapstructure:"wordsFile"`       // 敏感词文件，每行一个词
+	Action          string   `mapstructure:"action"`          // 命中敏感词时的处理：mask 替换为*，reject 拒绝发表，review 进入审核队列
+	ReportThreshold int      `mapstructure:"reportThreshold"` // 举报数达到该值时自动隐藏并进入审核队列
+
+	// 新设备继承同一IP和UA下近期受限设备的限制，继承为禁言且不超过该时长，0为不继承
+	InheritWindow time.Duration `mapstructure:"inheritWindow"`
+}
+
+// JobsConfig 后台任务的执行间隔
+type JobsConfig struct {
+	Recommend time.Duration `mapstructure:"recommend"` // 重新计算个性化推荐
+	Ranking   time.Duration `mapstructure:"ranking"`   // 将排行榜计数持久化到数据库
+	ReadFlush time.Duration `mapstructure:"readFlush"` // 将缓冲的阅读量写入数据库
+}
+
+// ReadsConfig 阅读量统计配置
+type ReadsConfig struct {
+	Window time.Duration `mapstructure:"window"` // 同一设备在窗口内重复阅读同一章节只计一次
+}
+
+// SocketConfig WebSocket配置
+type SocketConfig struct {
+	Relay    bool   `mapstructure:"relay"`    // 多实例部署时通过Redis发布订阅转发消息
+	NodeID   string `mapstructure:"nodeId"`   // 实例ID，为空时启动时随机生成
+	EventLog int64  `mapstructure:"eventLog"` // 事件日志保留的广播和主题消息数，供SSE断线续传，0为不记录
+}
+
 func LoadConfig() *Config {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
@@ -88,9 +141,25 @@ func LoadConfig() *Config {
 	}
 
 	setDefaultConfig(&config)
+	if err := validateConfig(&config); err != nil {
+		log.Fatalf("Invalid config: %s", err)
+	}
 	return &config
 }
 
+// validateConfig 校验取值受限的配置项，拼写错误在启动时报错而不是静默回落到默认行为
+func validateConfig(config *Config) error {
+	for name, rule := range map[string]string{"sync.position": config.Sync.Position, "sync.lastRead": config.Sync.LastRead} {
+		if rule != "lww" && rule != "furthest" {
+			return fmt.Errorf("%s must be lww or furthest, got %q", name, rule)
+		}
+	}
+	if config.Sync.Bookmark != "lww" {
+		return fmt.Errorf("sync.bookmark must be lww, got %q", config.Sync.Bookmark)
+	}
+	return nil
+}
+
 func setDefaultConfig(config *Config) {
 	if config.Server.Port == "" {
 		config.Server.Port = "8080"
@@ -151,6 +220,46 @@ func setDefaultConfig(config *Config) {
 		config.Cache.Comment = 30 * time.Minute
 	}
 
+	// 设置默认登录令牌有效期
+	if config.Auth.TokenTTL == 0 {
+		config.Auth.TokenTTL = 30 * 24 * time.Hour
+	}
+
+	// 设置默认同步冲突规则
+	if config.Sync.Position == "" {
+		config.Sync.Position = "lww"
+	}
+	if config.Sync.LastRead == "" {
+		config.Sync.LastRead = "furthest"
+	}
+	if config.Sync.Bookmark == "" {
+		config.Sync.Bookmark = "lww"
+	}
+
+	// 设置默认审核配置
+	if config.Moderate.Action == "" {
+		config.Moderate.Action = "mask"
+	}
+	if config.Moderate.ReportThreshold == 0 {
+		config.Moderate.ReportThreshold = 5
+	}
+
+	// 设置默认后台任务间隔
+	if config.Jobs.Recommend == 0 {
+		config.Jobs.Recommend = time.Hour
+	}
+	if config.Jobs.Ranking == 0 {
+		config.Jobs.Ranking = 10 * time.Minute
+	}
+	if config.Jobs.ReadFlush == 0 {
+		config.Jobs.ReadFlush = time.Minute
+	}
+
+	// 设置默认阅读量统计配置
+	if config.Reads.Window == 0 {
+		config.Reads.Window = 30 * time.Minute
+	}
+
 	// 设置默认限流配置
 	if config.Rate.Limit == 0 {
 		config.Rate.Limit = 100
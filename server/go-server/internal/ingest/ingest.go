@@ -0,0 +1,427 @@
+// ****************************************************************************
+//
+// @file       ingest.go
+// @brief      将解析后的EPUB导入MongoDB和静态图片目录
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif" // 注册GIF解码器
+	"image/jpeg"
+	_ "image/png" // 注册PNG解码器
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"lightnovel/internal/models"
+	"lightnovel/pkg/anchor"
+	"lightnovel/pkg/cache"
+	"lightnovel/pkg/database"
+	"lightnovel/pkg/epub"
+	"lightnovel/pkg/textindex"
+)
+
+// Options 导入选项
+type Options struct {
+	NovelsDir string   // 图片根目录，与服务端 r.Static("/novels", ...) 一致
+	Tags      []string // 新建小说时的标签
+	Status    string   // 新建小说时的状态
+	DryRun    bool     // 只输出差异，不写入
+	Prune     bool     // 删除EPUB中已不存在的卷和章节
+}
+
+// Importer EPUB导入器
+type Importer struct {
+	db    *database.MongoDB
+	cache cache.Cache
+	opts  Options
+}
+
+// NewImporter 创建导入器，cache为nil时不清理缓存
+func NewImporter(db *database.MongoDB, c cache.Cache, opts Options) *Importer {
+	if opts.NovelsDir == "" {
+		opts.NovelsDir = "../novels"
+	}
+	return &Importer{db: db, cache: c, opts: opts}
+}
+
+// Import 导入一本书，按唯一键幂等写入，返回逐章差异
+func (im *Importer) Import(ctx context.Context, book *epub.Book) (*Report, error) {
+	if book.Title == "" || strings.ContainsAny(book.Title, `/\`) || book.Title == "." || book.Title == ".." {
+		return nil, fmt.Errorf("invalid novel title %q", book.Title)
+	}
+
+	report := &Report{Title: book.Title, DryRun: im.opts.DryRun}
+	now := time.Now()
+
+	// 小说按标题匹配
+	novels := im.db.GetCollection("novels")
+	var novel models.Novel
+	err := novels.FindOne(ctx, bson.M{"title": book.Title}).Decode(&novel)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	if err == mongo.ErrNoDocuments {
+		report.NovelCreated = true
+		tags := im.opts.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		novel = models.Novel{
+			ID:          primitive.NewObjectID(),
+			Title:       book.Title,
+			Author:      book.Author,
+			Description: book.Description,
+			VolumeCount: len(book.Volumes),
+			Tags:        tags,
+			Status:      im.opts.Status,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+	}
+	novelID := novel.ID.Hex()
+	report.NovelID = novelID
+
+	// 读取已有章节用于比较
+	existing := make(map[chapterKey]models.Chapter)
+	if !report.NovelCreated {
+		cursor, err := im.db.GetCollection("chapters").Find(ctx, bson.M{"novelId": novelID})
+		if err != nil {
+			return nil, err
+		}
+		var chapters []models.Chapter
+		if err := cursor.All(ctx, &chapters); err != nil {
+			return nil, err
+		}
+		for _, ch := range chapters {
+			existing[chapterKey{ch.VolumeNumber, ch.ChapterNumber}] = ch
+		}
+	}
+
+	// 封面
+	if book.Cover != nil {
+		cover := "/" + filepath.ToSlash(filepath.Join("novels", book.Title, "cover.jpg"))
+		if !im.opts.DryRun {
+			if err := writeImage(filepath.Join(im.opts.NovelsDir, book.Title, "cover.jpg"), *book.Cover); err != nil {
+				return nil, err
+			}
+		}
+		if novel.Cover != cover {
+			report.NovelChanges = append(report.NovelChanges, "封面")
+			novel.Cover = cover
+		}
+	}
+
+	// 新小说先写入，保证章节写入中途失败时不会指向不存在的小说，重新导入时按标题找回
+	if report.NovelCreated && !im.opts.DryRun {
+		if _, err := novels.InsertOne(ctx, novel); err != nil {
+			return nil, err
+		}
+	}
+
+	// 逐章比较并写入
+	seen := make(map[chapterKey]bool)
+	for _, vol := range book.Volumes {
+		for _, ch := range vol.Chapters {
+			key := chapterKey{vol.Number, ch.Number}
+			seen[key] = true
+
+			diff := ChapterDiff{
+				VolumeNumber:  vol.Number,
+				ChapterNumber: ch.Number,
+				Title:         ch.Title,
+				Action:        ActionUnchanged,
+			}
+			old, ok := existing[key]
+			if !ok {
+				diff.Action = ActionAdded
+				diff.Changes = append(diff.Changes, fmt.Sprintf("%d字", utf8.RuneCountInString(ch.Content)))
+				if len(ch.Images) > 0 {
+					diff.Changes = append(diff.Changes, fmt.Sprintf("%d图", len(ch.Images)))
+				}
+			} else {
+				diff.Changes = compareChapter(old, ch)
+				if len(diff.Changes) > 0 {
+					diff.Action = ActionUpdated
+				}
+			}
+
+			if !im.opts.DryRun {
+				// 图片总是校验一遍，保证磁盘文件与数据库一致
+				dir := filepath.Join(im.opts.NovelsDir, book.Title, fmt.Sprintf("volume_%d", vol.Number), fmt.Sprintf("chapter_%d", ch.Number))
+				if err := syncImages(dir, ch.Images); err != nil {
+					return nil, err
+				}
+				if diff.Action != ActionUnchanged {
+					if err := im.upsertChapter(ctx, novelID, vol.Number, ch, now); err != nil {
+						return nil, err
+					}
+					if err := textindex.Update(ctx, im.db.GetCollection("chapter_index"), novelID, vol.Number, ch.Number, ch.Content, now); err != nil {
+						return nil, err
+					}
+				}
+				// 正文变化后重新定位段评，与管理接口修改章节时一致；曾被删除又重新导入的章节也能找回段评
+				if diff.Action != ActionUnchanged && old.Content != ch.Content {
+					if _, err := anchor.Reanchor(ctx, im.db.GetCollection("comments"), novelID, vol.Number, ch.Number, ch.Content); err != nil {
+						return nil, err
+					}
+				}
+			}
+			report.Chapters = append(report.Chapters, diff)
+		}
+	}
+
+	// EPUB中已不存在的章节
+	for key, old := range existing {
+		if seen[key] {
+			continue
+		}
+		report.Chapters = append(report.Chapters, ChapterDiff{
+			VolumeNumber:  key.volume,
+			ChapterNumber: key.chapter,
+			Title:         old.Title,
+			Action:        ActionRemoved,
+		})
+		if im.opts.Prune && !im.opts.DryRun {
+			if _, err := im.db.GetCollection("chapters").DeleteOne(ctx, bson.M{"_id": old.ID}); err != nil {
+				return nil, err
+			}
+			if err := textindex.Remove(ctx, im.db.GetCollection("chapter_index"), novelID, key.volume, key.chapter); err != nil {
+				return nil, err
+			}
+			if _, err := anchor.Orphan(ctx, im.db.GetCollection("comments"), novelID, key.volume, key.chapter); err != nil {
+				return nil, err
+			}
+			if err := os.RemoveAll(filepath.Join(im.opts.NovelsDir, book.Title, fmt.Sprintf("volume_%d", key.volume), fmt.Sprintf("chapter_%d", key.chapter))); err != nil {
+				return nil, err
+			}
+		}
+	}
+	report.sort()
+
+	// 卷
+	if !im.opts.DryRun {
+		volumeNumbers := make([]int, 0, len(book.Volumes))
+		for _, vol := range book.Volumes {
+			if err := im.upsertVolume(ctx, novelID, vol, now); err != nil {
+				return nil, err
+			}
+			volumeNumbers = append(volumeNumbers, vol.Number)
+		}
+		// 卷号不一定连续，删除EPUB中未出现的卷
+		if im.opts.Prune {
+			if _, err := im.db.GetCollection("volumes").DeleteMany(ctx, bson.M{
+				"novelId":      novelID,
+				"volumeNumber": bson.M{"$nin": volumeNumbers},
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 小说元数据
+	if !report.NovelCreated {
+		if novel.Author != book.Author {
+			report.NovelChanges = append(report.NovelChanges, "作者")
+		}
+		if novel.Description != book.Description {
+			report.NovelChanges = append(report.NovelChanges, "简介")
+		}
+		if novel.VolumeCount != len(book.Volumes) {
+			report.NovelChanges = append(report.NovelChanges, fmt.Sprintf("卷数 %d -> %d", novel.VolumeCount, len(book.Volumes)))
+		}
+	}
+	if im.opts.DryRun || !report.Changed() {
+		return report, nil
+	}
+
+	if !report.NovelCreated {
+		_, err := novels.UpdateOne(ctx, bson.M{"_id": novel.ID}, bson.M{
+			"$set": bson.M{
+				"author":      book.Author,
+				"description": book.Description,
+				"cover":       novel.Cover,
+				"volumeCount": len(book.Volumes),
+				"updatedAt":   now,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	im.invalidateCache(ctx, novelID)
+	return report, nil
+}
+
+// upsertChapter 按 novel_chapter 唯一键写入章节
+func (im *Importer) upsertChapter(ctx context.Context, novelID string, volumeNumber int, ch epub.Chapter, now time.Time) error {
+	set := bson.M{
+		"title":      ch.Title,
+		"content":    ch.Content,
+		"hasImages":  len(ch.Images) > 0,
+		"imageCount": len(ch.Images),
+		"updatedAt":  now,
+	}
+	if len(ch.Images) > 0 {
+		set["imagePath"] = imagePath(volumeNumber, ch.Number)
+	} else {
+		set["imagePath"] = nil
+	}
+
+	_, err := im.db.GetCollection("chapters").UpdateOne(ctx,
+		bson.M{
+			"novelId":       novelID,
+			"volumeNumber":  volumeNumber,
+			"chapterNumber": ch.Number,
+		},
+		bson.M{
+			"$set": set,
+			"$setOnInsert": bson.M{
+				"_id":       primitive.NewObjectID(),
+				"createdAt": now,
+			},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// upsertVolume 按 novel_volume 唯一键写入卷，章节数不变时不更新
+func (im *Importer) upsertVolume(ctx context.Context, novelID string, vol epub.Volume, now time.Time) error {
+	collection := im.db.GetCollection("volumes")
+	filter := bson.M{
+		"novelId":      novelID,
+		"volumeNumber": vol.Number,
+	}
+
+	var existing models.Volume
+	err := collection.FindOne(ctx, filter).Decode(&existing)
+	if err == nil && existing.ChapterCount == len(vol.Chapters) {
+		return nil
+	}
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	_, err = collection.UpdateOne(ctx, filter,
+		bson.M{
+			"$set": bson.M{
+				"chapterCount": len(vol.Chapters),
+				"updatedAt":    now,
+			},
+			"$setOnInsert": bson.M{
+				"_id":       primitive.NewObjectID(),
+				"createdAt": now,
+			},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// invalidateCache 清除与该小说相关的缓存
+func (im *Importer) invalidateCache(ctx context.Context, novelID string) {
+	if im.cache == nil {
+		return
+	}
+	im.cache.DeleteByPattern(ctx, cache.NovelListKey+"*")
+	im.cache.Delete(ctx, cache.NovelDetailKey+novelID)
+	im.cache.Delete(ctx, cache.VolumeListKey+novelID)
+	im.cache.DeleteByPattern(ctx, cache.ChapterListKey+novelID+"*")
+	im.cache.DeleteByPattern(ctx, cache.ChapterKey+novelID+"*")
+	im.cache.Delete(ctx, cache.LatestNovelsKey)
+	im.cache.DeleteByPattern(ctx, cache.SearchKey+"*")
+	im.cache.DeleteByPattern(ctx, cache.ContentSearchKey+novelID+"*")
+	im.cache.DeleteByPattern(ctx, cache.CommentListKey+novelID+"*")
+}
+
+// compareChapter 比较数据库中的章节和EPUB中的章节，返回变更描述
+func compareChapter(old models.Chapter, ch epub.Chapter) []string {
+	var changes []string
+	if old.Title != ch.Title {
+		changes = append(changes, fmt.Sprintf("标题 %q -> %q", old.Title, ch.Title))
+	}
+	if old.Content != ch.Content {
+		before := utf8.RuneCountInString(old.Content)
+		after := utf8.RuneCountInString(ch.Content)
+		changes = append(changes, fmt.Sprintf("内容 %d -> %d字 (%+d)", before, after, after-before))
+	}
+	if old.ImageCount != len(ch.Images) {
+		changes = append(changes, fmt.Sprintf("图片 %d -> %d", old.ImageCount, len(ch.Images)))
+	}
+	return changes
+}
+
+// imagePath 章节图片路径，保留小说标题为空的格式，客户端会用标题替换"//"
+func imagePath(volumeNumber, chapterNumber int) string {
+	return fmt.Sprintf("novels//volume_%d/chapter_%d", volumeNumber, chapterNumber)
+}
+
+// syncImages 将章节图片按001.jpg、002.jpg…写入目录，内容相同的文件跳过，多余的旧文件删除
+func syncImages(dir string, images []epub.Image) error {
+	if len(images) == 0 {
+		return os.RemoveAll(dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for i, img := range images {
+		if err := writeImage(filepath.Join(dir, fmt.Sprintf("%03d.jpg", i+1)), img); err != nil {
+			return err
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		var n int
+		if _, err := fmt.Sscanf(e.Name(), "%03d.jpg", &n); err == nil && n > len(images) {
+			if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeImage 写入图片，客户端固定按.jpg加载，因此PNG和GIF会转码为JPEG
+func writeImage(filename string, img epub.Image) error {
+	data := img.Data
+	if img.MediaType == "image/png" || img.MediaType == "image/gif" {
+		decoded, _, err := image.Decode(bytes.NewReader(img.Data))
+		if err == nil {
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, decoded, &jpeg.Options{Quality: 90}); err == nil {
+				data = buf.Bytes()
+			}
+		}
+	}
+
+	if old, err := os.ReadFile(filename); err == nil && bytes.Equal(old, data) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
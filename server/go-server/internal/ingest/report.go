@@ -0,0 +1,128 @@
+// ****************************************************************************
+//
+// @file       report.go
+// @brief      导入结果与逐章差异报告
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package ingest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Action 章节变更类型
+type Action string
+
+const (
+	ActionAdded     Action = "added"
+	ActionUpdated   Action = "updated"
+	ActionUnchanged Action = "unchanged"
+	ActionRemoved   Action = "removed"
+)
+
+// 报告中各类型的标记
+var actionMarks = map[Action]string{
+	ActionAdded:     "+",
+	ActionUpdated:   "~",
+	ActionUnchanged: "=",
+	ActionRemoved:   "-",
+}
+
+// chapterKey 与 novel_chapter 索引对应的章节键（小说ID之外的部分）
+type chapterKey struct {
+	volume  int
+	chapter int
+}
+
+// ChapterDiff 单个章节的差异
+type ChapterDiff struct {
+	VolumeNumber  int
+	ChapterNumber int
+	Title         string
+	Action        Action
+	Changes       []string
+}
+
+// Report 一本书的导入报告
+type Report struct {
+	NovelID      string
+	Title        string
+	NovelCreated bool
+	NovelChanges []string
+	Chapters     []ChapterDiff
+	DryRun       bool
+}
+
+// Changed 是否有任何变更
+func (r *Report) Changed() bool {
+	if r.NovelCreated || len(r.NovelChanges) > 0 {
+		return true
+	}
+	for _, ch := range r.Chapters {
+		if ch.Action != ActionUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// Count 统计指定类型的章节数
+func (r *Report) Count(action Action) int {
+	n := 0
+	for _, ch := range r.Chapters {
+		if ch.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// Print 输出报告，verbose为false时不列出未变更的章节
+func (r *Report) Print(w io.Writer, verbose bool) {
+	state := "已更新"
+	switch {
+	case r.NovelCreated:
+		state = "新建"
+	case !r.Changed():
+		state = "无变化"
+	}
+	if r.DryRun {
+		state += " (dry-run)"
+	}
+	fmt.Fprintf(w, "《%s》 %s [%s]\n", r.Title, r.NovelID, state)
+
+	if len(r.NovelChanges) > 0 {
+		fmt.Fprintf(w, "  元数据: %s\n", strings.Join(r.NovelChanges, ", "))
+	}
+
+	for _, ch := range r.Chapters {
+		if ch.Action == ActionUnchanged && !verbose {
+			continue
+		}
+		line := fmt.Sprintf("  %s 第%d卷 第%d章 %s", actionMarks[ch.Action], ch.VolumeNumber, ch.ChapterNumber, ch.Title)
+		if len(ch.Changes) > 0 {
+			line += " (" + strings.Join(ch.Changes, "; ") + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	fmt.Fprintf(w, "  合计: 新增%d 更新%d 未变%d 移除%d\n",
+		r.Count(ActionAdded), r.Count(ActionUpdated), r.Count(ActionUnchanged), r.Count(ActionRemoved))
+}
+
+func (r *Report) sort() {
+	sort.Slice(r.Chapters, func(i, j int) bool {
+		a, b := r.Chapters[i], r.Chapters[j]
+		if a.VolumeNumber != b.VolumeNumber {
+			return a.VolumeNumber < b.VolumeNumber
+		}
+		return a.ChapterNumber < b.ChapterNumber
+	})
+}
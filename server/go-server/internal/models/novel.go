@@ -18,17 +18,104 @@ import (
 
 // Novel 小说模型
 type Novel struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Title       string             `bson:"title" json:"title"`
-	Author      string             `bson:"author" json:"author"`
-	Description string             `bson:"description" json:"description"`
-	Cover       string             `bson:"cover" json:"cover"`
-	VolumeCount int                `bson:"volumeCount" json:"volumeCount"`
-	Tags        []string           `bson:"tags" json:"tags"`
-	Status      string             `bson:"status" json:"status"`
-	ReadCount   int64              `bson:"readCount" json:"readCount"`
-	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
-	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Title         string             `bson:"title" json:"title"`
+	Author        string             `bson:"author" json:"author"`
+	Description   string             `bson:"description" json:"description"`
+	Cover         string             `bson:"cover" json:"cover"`
+	VolumeCount   int                `bson:"volumeCount" json:"volumeCount"`
+	Tags          []string           `bson:"tags" json:"tags"`
+	Status        string             `bson:"status" json:"status"`
+	ReadCount     int64              `bson:"readCount" json:"readCount"`                     // 与totalReads一致，保留给已有的排序
+	TotalReads    int64              `bson:"totalReads" json:"totalReads"`                   // 去重后的阅读次数
+	UniqueReaders int64              `bson:"uniqueReaders" json:"uniqueReaders"`             // 独立读者数，为估计值
+	Rating        float64            `bson:"rating" json:"rating"`                           // 平均评分
+	RatingCount   int64              `bson:"ratingCount" json:"ratingCount"`                 // 评分人数
+	RatingSum     int64              `bson:"ratingSum" json:"-"`                             // 评分总和
+	Histogram     map[string]int64   `bson:"histogram,omitempty" json:"histogram,omitempty"` // 各分数的评分人数
+	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt     time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Review 小说评分与书评，每个用户对每部小说只有一条
+type Review struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	NovelID   string             `bson:"novelId" json:"novelId"`
+	DeviceID  string             `bson:"deviceId" json:"deviceId"`
+	Score     int                `bson:"score" json:"score"`     // 1-10分
+	Content   string             `bson:"content" json:"content"` // 书评正文，可为空
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// ReviewResponse 书评响应类型(包含用户信息)
+type ReviewResponse struct {
+	Review
+	UserName   string `json:"userName"`
+	UserAvatar string `json:"userAvatar"`
+}
+
+// NovelSimilarity 小说的相似小说，共读相似度和相关小说均由后台任务计算
+type NovelSimilarity struct {
+	NovelID   string         `bson:"_id" json:"novelId"`
+	Neighbors []SimilarNovel `bson:"neighbors" json:"neighbors"`
+	UpdatedAt time.Time      `bson:"updatedAt" json:"updatedAt"`
+}
+
+// SimilarNovel 相似小说及相似度
+type SimilarNovel struct {
+	NovelID string  `bson:"novelId" json:"novelId"`
+	Score   float64 `bson:"score" json:"score"`
+}
+
+// RelatedNovel 相关小说
+type RelatedNovel struct {
+	Novel *Novel  `json:"novel"`
+	Score float64 `json:"score"`
+}
+
+// RankingCount 排行榜中小说某一天的计数，由后台任务从 Redis 持久化
+type RankingCount struct {
+	Board   string  `bson:"board" json:"board"`
+	Day     string  `bson:"day" json:"day"` // 日期，格式为20060102
+	NovelID string  `bson:"novelId" json:"novelId"`
+	Score   float64 `bson:"score" json:"score"`
+}
+
+// RankedNovel 排行榜中的小说
+type RankedNovel struct {
+	Rank  int     `json:"rank"`
+	Novel *Novel  `json:"novel"`
+	Score float64 `json:"score"` // 窗口内的计数，飙升榜为增长率
+}
+
+// UserRecommendations 用户的推荐结果，由后台任务计算
+type UserRecommendations struct {
+	DeviceID  string               `bson:"_id" json:"deviceId"`
+	Items     []RecommendationItem `bson:"items" json:"items"`
+	UpdatedAt time.Time            `bson:"updatedAt" json:"updatedAt"`
+}
+
+// RecommendationItem 一条推荐及推荐理由
+type RecommendationItem struct {
+	NovelID   string  `bson:"novelId" json:"novelId"`
+	Score     float64 `bson:"score" json:"score"`
+	Reason    string  `bson:"reason" json:"reason"`                           // 推荐理由，如"因为你读过《X》"
+	BecauseOf string  `bson:"becauseOf,omitempty" json:"becauseOf,omitempty"` // 理由关联的小说ID
+}
+
+// RecommendedNovel 推荐的小说
+type RecommendedNovel struct {
+	Novel     *Novel  `json:"novel"`
+	Score     float64 `json:"score"`
+	Reason    string  `json:"reason"`
+	BecauseOf string  `json:"becauseOf,omitempty"`
+}
+
+// RatedNovel 评分榜中的小说，Score为贝叶斯平均分
+type RatedNovel struct {
+	Novel `bson:",inline"`
+	Score float64 `bson:"score" json:"score"`
 }
 
 // Volume 卷模型
@@ -69,25 +156,32 @@ type ChapterInfo struct {
 
 // Device 设备信息模型
 type Device struct {
-	ID         string    `bson:"_id" json:"id"` // UUID作为设备唯一标识
-	IP         string    `bson:"ip" json:"ip"`  // 最后使用的IP
-	UserAgent  string    `bson:"userAgent" json:"userAgent"`
-	DeviceType string    `bson:"deviceType" json:"deviceType"` // mobile/pc/tablet
-	FirstSeen  time.Time `bson:"firstSeen" json:"firstSeen"`
-	LastSeen   time.Time `bson:"lastSeen" json:"lastSeen"`
+	ID         string     `bson:"_id" json:"id"` // UUID作为设备唯一标识
+	IP         string     `bson:"ip" json:"ip"`  // 最后使用的IP
+	UserAgent  string     `bson:"userAgent" json:"userAgent"`
+	DeviceType string     `bson:"deviceType" json:"deviceType"` // mobile/pc/tablet
+	FirstSeen  time.Time  `bson:"firstSeen" json:"firstSeen"`
+	LastSeen   time.Time  `bson:"lastSeen" json:"lastSeen"`
+	UserID     string     `bson:"userId,omitempty" json:"userId,omitempty"`         // 绑定的账号，未登录过为空
+	MutedUntil *time.Time `bson:"mutedUntil,omitempty" json:"mutedUntil,omitempty"` // 禁言截止时间
+	Banned     bool       `bson:"banned,omitempty" json:"banned,omitempty"`         // 是否封禁
+	BanReason  string     `bson:"banReason,omitempty" json:"banReason,omitempty"`
 }
 
 // Bookmark 书签模型
 type Bookmark struct {
-	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	DeviceID      string             `bson:"deviceId" json:"deviceId"`
-	NovelID       string             `bson:"novelId" json:"novelId"`
-	VolumeNumber  int                `bson:"volumeNumber" json:"volumeNumber"`
-	ChapterNumber int                `bson:"chapterNumber" json:"chapterNumber"`
-	Position      int                `bson:"position" json:"position"`
-	Note          string             `bson:"note" json:"note"`
-	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
-	UpdatedAt     time.Time          `bson:"updatedAt" json:"updatedAt"`
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	DeviceID        string             `bson:"deviceId" json:"deviceId"`
+	NovelID         string             `bson:"novelId" json:"novelId"`
+	VolumeNumber    int                `bson:"volumeNumber" json:"volumeNumber"`
+	ChapterNumber   int                `bson:"chapterNumber" json:"chapterNumber"`
+	Position        int                `bson:"position" json:"position"`
+	Note            string             `bson:"note" json:"note"`
+	ClientID        string             `bson:"clientId,omitempty" json:"clientId,omitempty"` // 客户端离线创建时生成的ID
+	CreatedAt       time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt       time.Time          `bson:"updatedAt" json:"updatedAt"`
+	ClientUpdatedAt time.Time          `bson:"clientUpdatedAt" json:"clientUpdatedAt"` // 客户端修改时间，用于冲突处理
+	Version         int64              `bson:"version" json:"version"`                 // 同步版本号
 }
 
 // Favorite 收藏模型
@@ -99,47 +193,261 @@ type Favorite struct {
 	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
 }
 
-// ReadHistory 阅读历史
-type ReadHistory struct {
+// Follow 关注的小说，关注或收藏的小说更新时会收到通知
+type Follow struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	DeviceID  string             `bson:"deviceId" json:"deviceId"`
+	NovelID   string             `bson:"novelId" json:"novelId"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	Novel     *Novel             `bson:"-" json:"novel,omitempty"`
+}
+
+// NotificationPrefs 通知偏好，未设置过时除内容修订外全部开启
+type NotificationPrefs struct {
+	DeviceID      string    `bson:"_id" json:"-"`
+	NewChapter    bool      `bson:"newChapter" json:"newChapter"`       // 关注的小说有新章节
+	NewVolume     bool      `bson:"newVolume" json:"newVolume"`         // 关注的小说有新卷
+	ContentUpdate bool      `bson:"contentUpdate" json:"contentUpdate"` // 关注的小说修订了已有内容，默认关闭
+	CommentReply  bool      `bson:"commentReply" json:"commentReply"`   // 评论被回复
+	UpdatedAt     time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Notification 站内通知，保存后立即推送，客户端确认前在每次重新连接时补发
+type Notification struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	DeviceID  string                 `bson:"deviceId" json:"-"`
+	Type      string                 `bson:"type" json:"type"` // novel_update, comment_reply, system_notice
+	Data      map[string]interface{} `bson:"data" json:"data"`
+	Read      bool                   `bson:"read" json:"read"`
+	Delivered bool                   `bson:"delivered" json:"-"`        // 客户端是否已确认收到
+	PushID    primitive.ObjectID     `bson:"pushId,omitempty" json:"-"` // 同一批通知共用的推送消息ID，客户端可用它确认
+	CreatedAt time.Time              `bson:"createdAt" json:"createdAt"`
+}
+
+// Bookshelf 书单，每个用户有收藏、在读、想读、弃坑四个默认书单，并可自建书单
+type Bookshelf struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	DeviceID  string             `bson:"deviceId" json:"-"` // 分享的书单不暴露所有者
+	Name      string             `bson:"name" json:"name"`
+	Kind      string             `bson:"kind,omitempty" json:"kind,omitempty"`           // 默认书单的类型，自建书单为空
+	Note      string             `bson:"note" json:"note"`                               // 书单简介
+	Public    bool               `bson:"public" json:"public"`                           // 是否公开分享
+	ShareCode string             `bson:"shareCode,omitempty" json:"shareCode,omitempty"` // 分享链接中的编码，公开时生成
+	Position  int                `bson:"position" json:"position"`                       // 书单顺序
+	ItemCount int64              `bson:"-" json:"itemCount"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// ShelfEntry 书单中的一部小说
+type ShelfEntry struct {
 	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	DeviceID string             `bson:"deviceId" json:"deviceId"`
+	ShelfID  primitive.ObjectID `bson:"shelfId" json:"shelfId"`
+	DeviceID string             `bson:"deviceId" json:"-"`
 	NovelID  string             `bson:"novelId" json:"novelId"`
-	LastRead time.Time          `bson:"lastRead" json:"lastRead"` // 最后阅读时间,用于排序
+	Note     string             `bson:"note" json:"note"`
+	Position int                `bson:"position" json:"position"`
+	AddedAt  time.Time          `bson:"addedAt" json:"addedAt"`
+	Novel    *Novel             `bson:"-" json:"novel,omitempty"`
+}
+
+// BookshelfDetail 书单及其中的小说
+type BookshelfDetail struct {
+	Bookshelf
+	Entries []ShelfEntry `json:"entries"`
+}
+
+// ReadHistory 阅读历史
+type ReadHistory struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	DeviceID        string             `bson:"deviceId" json:"deviceId"`
+	NovelID         string             `bson:"novelId" json:"novelId"`
+	LastRead        time.Time          `bson:"lastRead" json:"lastRead"` // 最后阅读时间,用于排序
+	ClientUpdatedAt time.Time          `bson:"clientUpdatedAt" json:"clientUpdatedAt"`
+	Version         int64              `bson:"version" json:"version"`
 }
 
 // ReadProgress 阅读进度
 type ReadProgress struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	DeviceID        string             `bson:"deviceId" json:"deviceId"`
+	NovelID         string             `bson:"novelId" json:"novelId"`
+	VolumeNumber    int                `bson:"volumeNumber" json:"volumeNumber"`
+	ChapterNumber   int                `bson:"chapterNumber" json:"chapterNumber"`
+	Position        int                `bson:"position" json:"position"`
+	UpdatedAt       time.Time          `bson:"updatedAt" json:"updatedAt"`
+	ClientUpdatedAt time.Time          `bson:"clientUpdatedAt" json:"clientUpdatedAt"`
+	Version         int64              `bson:"version" json:"version"`
+}
+
+// ReadingSession 一次章节阅读会话，打开章节时开始，离开章节时结束
+type ReadingSession struct {
 	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	DeviceID      string             `bson:"deviceId" json:"deviceId"`
 	NovelID       string             `bson:"novelId" json:"novelId"`
 	VolumeNumber  int                `bson:"volumeNumber" json:"volumeNumber"`
 	ChapterNumber int                `bson:"chapterNumber" json:"chapterNumber"`
-	Position      int                `bson:"position" json:"position"`
-	UpdatedAt     time.Time          `bson:"updatedAt" json:"updatedAt"`
+	Day           string             `bson:"day" json:"day"`                             // 开始阅读的日期，格式为2006-01-02
+	Duration      int64              `bson:"duration" json:"duration"`                   // 阅读时长，单位秒
+	Characters    int                `bson:"characters" json:"characters"`               // 读过的字数
+	StartedAt     time.Time          `bson:"startedAt" json:"startedAt"`                 // 开始时间
+	EndedAt       *time.Time         `bson:"endedAt,omitempty" json:"endedAt,omitempty"` // 结束时间，未结束的会话不计入统计
+}
+
+// ReadingStats 用户的阅读统计
+type ReadingStats struct {
+	TotalSeconds  int64             `json:"totalSeconds"`  // 总阅读时长，单位秒
+	Sessions      int64             `json:"sessions"`      // 阅读次数
+	ChaptersRead  int64             `json:"chaptersRead"`  // 读过的章节数
+	Characters    int64             `json:"characters"`    // 读过的字数
+	CurrentStreak int               `json:"currentStreak"` // 当前连续阅读天数
+	LongestStreak int               `json:"longestStreak"` // 最长连续阅读天数
+	Heatmap       []ReadingDay      `json:"heatmap"`       // 最近一年每天的阅读情况，只包含有阅读的日期
+	Novels        []NovelCompletion `json:"novels"`        // 各小说的阅读完成度
+}
+
+// ReadingDay 一天的阅读情况
+type ReadingDay struct {
+	Day        string `bson:"_id" json:"day"`
+	Seconds    int64  `bson:"seconds" json:"seconds"`
+	Characters int64  `bson:"characters" json:"characters"`
+	Sessions   int64  `bson:"sessions" json:"sessions"`
+}
+
+// NovelCompletion 小说的阅读完成度，按阅读进度所在章节在全书中的位置计算
+type NovelCompletion struct {
+	NovelID       string  `json:"novelId"`
+	Title         string  `json:"title"`
+	VolumeNumber  int     `json:"volumeNumber"`
+	ChapterNumber int     `json:"chapterNumber"`
+	ChaptersDone  int64   `json:"chaptersDone"`
+	TotalChapters int64   `json:"totalChapters"`
+	Percent       float64 `json:"percent"`
+}
+
+// ShelfItem 书架上的一部小说
+type ShelfItem struct {
+	Novel         *Novel        `json:"novel"`
+	Favorite      bool          `json:"favorite"`
+	LastRead      *time.Time    `json:"lastRead,omitempty"`
+	Progress      *ReadProgress `json:"progress,omitempty"`
+	NextChapter   *ShelfChapter `json:"nextChapter,omitempty"` // 下一章，已读到最后一章时为空
+	ChaptersDone  int64         `json:"chaptersDone"`
+	TotalChapters int64         `json:"totalChapters"`
+	Percent       float64       `json:"percent"`
+	NewChapters   int64         `json:"newChapters"` // 最后一次阅读之后新增的章节数
+}
+
+// ShelfChapter 书架中引用的章节
+type ShelfChapter struct {
+	VolumeNumber  int    `json:"volumeNumber"`
+	ChapterNumber int    `json:"chapterNumber"`
+	Title         string `json:"title"`
 }
 
-// User 用户模型
+// User 用户模型，匿名用户的ID即设备ID，注册账号的ID为独立的UUID
 type User struct {
-	ID           string    `bson:"_id" json:"id"`
-	Name         string    `bson:"name" json:"name"`
-	Avatar       string    `bson:"avatar" json:"avatar"`
-	CreatedAt    time.Time `bson:"createdAt" json:"createdAt"`
-	UpdatedAt    time.Time `bson:"updatedAt" json:"updatedAt"`
-	LastActiveAt time.Time `bson:"lastActiveAt" json:"lastActiveAt"`
+	ID           string     `bson:"_id" json:"id"`
+	Name         string     `bson:"name" json:"name"`
+	Avatar       string     `bson:"avatar" json:"avatar"`
+	Username     string     `bson:"username,omitempty" json:"username,omitempty"` // 登录名，仅注册账号有
+	PasswordHash string     `bson:"passwordHash,omitempty" json:"-"`
+	DeviceIDs    []string   `bson:"deviceIds,omitempty" json:"deviceIds,omitempty"` // 已绑定的设备
+	CreatedAt    time.Time  `bson:"createdAt" json:"createdAt"`
+	UpdatedAt    time.Time  `bson:"updatedAt" json:"updatedAt"`
+	LastActiveAt time.Time  `bson:"lastActiveAt" json:"lastActiveAt"`
+	MutedUntil   *time.Time `bson:"mutedUntil,omitempty" json:"mutedUntil,omitempty"` // 账号禁言截止时间
+	Banned       bool       `bson:"banned,omitempty" json:"banned,omitempty"`         // 账号是否封禁
+	BanReason    string     `bson:"banReason,omitempty" json:"banReason,omitempty"`
+}
+
+// Restriction 禁言封禁设置的结果，ID为设备ID或账号ID
+type Restriction struct {
+	ID         string     `json:"id"`
+	Account    bool       `json:"account"` // 是否为账号，账号的设置同步到其绑定的所有设备
+	Devices    int64      `json:"devices"` // 同步修改的设备数
+	MutedUntil *time.Time `json:"mutedUntil,omitempty"`
+	Banned     bool       `json:"banned"`
+	BanReason  string     `json:"banReason,omitempty"`
 }
 
-// Comment 评论模型
+// Session 登录会话，ID为令牌的SHA-256摘要，不保存令牌原文
+type Session struct {
+	ID        string    `bson:"_id" json:"-"`
+	UserID    string    `bson:"userId" json:"userId"`
+	DeviceID  string    `bson:"deviceId" json:"deviceId"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+	ExpiresAt time.Time `bson:"expiresAt" json:"expiresAt"`
+}
+
+// AuthResponse 注册或登录成功的响应
+type AuthResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	User      *User     `json:"user"`
+}
+
+// Comment 评论模型，回复的ParentID为所属顶层评论的ID
 type Comment struct {
 	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	DeviceID      string             `bson:"deviceId" json:"deviceId"`
 	NovelID       string             `bson:"novelId" json:"novelId"`
 	VolumeNumber  int                `bson:"volumeNumber" json:"volumeNumber"`
 	ChapterNumber int                `bson:"chapterNumber" json:"chapterNumber"`
+	ParentID      string             `bson:"parentId,omitempty" json:"parentId,omitempty"`
+	ReplyTo       string             `bson:"replyTo,omitempty" json:"replyTo,omitempty"` // 被回复者的用户ID
+	Anchor        *CommentAnchor     `bson:"anchor,omitempty" json:"anchor,omitempty"`   // 段评锚点，章评为空
 	Content       string             `bson:"content" json:"content"`
+	ReplyCount    int                `bson:"replyCount" json:"replyCount"`
+	LikeCount     int                `bson:"likeCount" json:"likeCount"`
+	Pinned        bool               `bson:"pinned" json:"pinned"`
+	Status        string             `bson:"status,omitempty" json:"status,omitempty"` // 为空时公开，hidden 已隐藏，pending 待审核
+	ReportCount   int                `bson:"reportCount" json:"reportCount"`           // 未处理的举报数
 	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
 	UpdatedAt     time.Time          `bson:"updatedAt" json:"updatedAt"`
 }
 
+// CommentReport 评论举报
+type CommentReport struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CommentID primitive.ObjectID `bson:"commentId" json:"commentId"`
+	NovelID   string             `bson:"novelId" json:"novelId"`
+	DeviceID  string             `bson:"deviceId" json:"deviceId"`
+	Reason    string             `bson:"reason" json:"reason"`
+	Resolved  bool               `bson:"resolved" json:"resolved"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// ModerationItem 审核队列中的评论及其未处理的举报
+type ModerationItem struct {
+	Comment Comment         `json:"comment"`
+	Reports []CommentReport `json:"reports"`
+}
+
+// CommentAnchor 段评锚点，段落为正文按换行拆分并去除首尾空白后的非空行，偏移按字符计
+type CommentAnchor struct {
+	Paragraph int    `bson:"paragraph" json:"paragraph"`                   // 段落序号，从0开始
+	Start     int    `bson:"start" json:"start"`                           // 选中文字在段内的起始偏移
+	End       int    `bson:"end" json:"end"`                               // 选中文字在段内的结束偏移(不含)，与Start相等时表示整段
+	Quote     string `bson:"quote" json:"quote"`                           // 被评论的原文，正文修改后据此重新定位
+	Orphaned  bool   `bson:"orphaned,omitempty" json:"orphaned,omitempty"` // 正文修改后无法定位，按章评展示
+}
+
+// ParagraphCommentCount 段落的段评数量(含回复)
+type ParagraphCommentCount struct {
+	Paragraph int `bson:"_id" json:"paragraph"`
+	Count     int `bson:"count" json:"count"`
+}
+
+// CommentLike 评论点赞记录
+type CommentLike struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CommentID primitive.ObjectID `bson:"commentId" json:"commentId"`
+	NovelID   string             `bson:"novelId" json:"novelId"`
+	DeviceID  string             `bson:"deviceId" json:"deviceId"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
 // CommentResponse 评论响应类型(包含用户信息)
 type CommentResponse struct {
 	ID            primitive.ObjectID `json:"id"`
@@ -149,6 +457,119 @@ type CommentResponse struct {
 	NovelID       string             `json:"novelId"`
 	VolumeNumber  int                `json:"volumeNumber"`
 	ChapterNumber int                `json:"chapterNumber"`
+	ParentID      string             `json:"parentId,omitempty"`
+	ReplyTo       string             `json:"replyTo,omitempty"`
+	Anchor        *CommentAnchor     `json:"anchor,omitempty"`
 	Content       string             `json:"content"`
+	ReplyCount    int                `json:"replyCount"`
+	LikeCount     int                `json:"likeCount"`
+	Liked         bool               `json:"liked"` // 当前用户是否已点赞
+	Pinned        bool               `json:"pinned"`
 	CreatedAt     time.Time          `json:"createdAt"`
 }
+
+// CommentLikeResponse 点赞操作的响应
+type CommentLikeResponse struct {
+	Liked     bool `json:"liked"`
+	LikeCount int  `json:"likeCount"`
+}
+
+// NovelSearchResult 小说搜索结果(包含相关度和高亮片段)
+type NovelSearchResult struct {
+	Novel      `bson:",inline"`
+	Score      float64     `bson:"score" json:"score"`
+	Highlights []Highlight `bson:"-" json:"highlights"`
+}
+
+// Highlight 搜索命中的高亮片段，原文已做HTML转义，命中部分以<em></em>包裹
+type Highlight struct {
+	Field   string `json:"field"`
+	Snippet string `json:"snippet"`
+}
+
+// ChapterIndex 章节正文的n-gram索引，用于小说内全文搜索
+type ChapterIndex struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	NovelID         string             `bson:"novelId" json:"novelId"`
+	VolumeNumber    int                `bson:"volumeNumber" json:"volumeNumber"`
+	ChapterNumber   int                `bson:"chapterNumber" json:"chapterNumber"`
+	Grams           []string           `bson:"grams" json:"-"`
+	SourceUpdatedAt time.Time          `bson:"sourceUpdatedAt" json:"sourceUpdatedAt"` // 建索引时章节的updatedAt
+	UpdatedAt       time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// ChapterSearchResult 小说内搜索命中的章节
+type ChapterSearchResult struct {
+	VolumeNumber  int            `json:"volumeNumber"`
+	ChapterNumber int            `json:"chapterNumber"`
+	Title         string         `json:"title"`
+	MatchCount    int            `json:"matchCount"`
+	Matches       []ContentMatch `json:"matches"`
+}
+
+// ContentMatch 正文中的一处命中，Position为正文中的字符偏移，与书签、阅读进度的position一致
+type ContentMatch struct {
+	Position int    `json:"position"`
+	Length   int    `json:"length"`
+	Context  string `json:"context"`
+}
+
+// SyncDeletion 同步用的删除记录，客户端据此删除本地数据
+type SyncDeletion struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	DeviceID  string             `bson:"deviceId" json:"-"`
+	Kind      string             `bson:"kind" json:"kind"` // progress/history/bookmark
+	Key       string             `bson:"key" json:"key"`   // 进度和历史为小说ID，书签为书签ID
+	Version   int64              `bson:"version" json:"version"`
+	DeletedAt time.Time          `bson:"deletedAt" json:"deletedAt"`
+}
+
+// ProgressChange 客户端离线产生的阅读进度变更
+type ProgressChange struct {
+	NovelID       string    `json:"novelId" binding:"required"`
+	VolumeNumber  int       `json:"volumeNumber"`
+	ChapterNumber int       `json:"chapterNumber"`
+	Position      int       `json:"position"`
+	UpdatedAt     time.Time `json:"updatedAt" binding:"required"` // 客户端修改时间
+	Deleted       bool      `json:"deleted"`
+}
+
+// HistoryChange 客户端离线产生的阅读历史变更
+type HistoryChange struct {
+	NovelID   string    `json:"novelId" binding:"required"`
+	LastRead  time.Time `json:"lastRead"`
+	UpdatedAt time.Time `json:"updatedAt" binding:"required"`
+	Deleted   bool      `json:"deleted"`
+}
+
+// BookmarkChange 客户端离线产生的书签变更，新建的书签用ClientID标识
+type BookmarkChange struct {
+	ID            string    `json:"id"`
+	ClientID      string    `json:"clientId"`
+	NovelID       string    `json:"novelId"`
+	VolumeNumber  int       `json:"volumeNumber"`
+	ChapterNumber int       `json:"chapterNumber"`
+	Position      int       `json:"position"`
+	Note          string    `json:"note"`
+	UpdatedAt     time.Time `json:"updatedAt" binding:"required"`
+	Deleted       bool      `json:"deleted"`
+}
+
+// SyncRequest 批量同步请求
+type SyncRequest struct {
+	Cursor    int64            `json:"cursor"` // 上次同步返回的游标，首次同步为0
+	Progress  []ProgressChange `json:"progress" binding:"dive"`
+	History   []HistoryChange  `json:"history" binding:"dive"`
+	Bookmarks []BookmarkChange `json:"bookmarks" binding:"dive"`
+}
+
+// SyncResponse 批量同步响应，包含游标之后服务端的一页变更
+type SyncResponse struct {
+	Cursor    int64          `json:"cursor"`
+	HasMore   bool           `json:"hasMore"` // 还有未返回的变更，应以 Cursor 继续同步
+	Progress  []ReadProgress `json:"progress"`
+	History   []ReadHistory  `json:"history"`
+	Bookmarks []Bookmark     `json:"bookmarks"`
+	Deleted   []SyncDeletion `json:"deleted"`
+	Rejected  int            `json:"rejected"` // 按冲突规则未被采用的客户端变更数
+}
@@ -0,0 +1,437 @@
+// ****************************************************************************
+//
+// @file       account_service.go
+// @brief      账号注册登录、会话令牌以及设备数据迁移
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+
+	"lightnovel/internal/models"
+	"lightnovel/pkg/cache"
+	"lightnovel/pkg/errors"
+)
+
+const (
+	minUsernameLen = 3
+	maxUsernameLen = 32
+	minPasswordLen = 6
+	maxPasswordLen = 72 // bcrypt只使用前72字节
+)
+
+// Register 注册账号并在当前设备登录，claimDevice为true时设备数据归入账号
+func (s *NovelService) Register(ctx context.Context, deviceID, username, password string, claimDevice bool) (*models.AuthResponse, error) {
+	if err := validateCredentials(username, password); err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	// 沿用设备匿名资料中的头像
+	avatar := "/static/avatars/default.png"
+	var profile models.User
+	if claimDevice {
+		if err := s.db.GetCollection("users").FindOne(ctx, bson.M{"_id": deviceID}).Decode(&profile); err == nil && profile.Avatar != "" {
+			avatar = profile.Avatar
+		}
+	}
+
+	now := time.Now()
+	user := models.User{
+		ID:           uuid.New().String(),
+		Name:         username,
+		Avatar:       avatar,
+		Username:     username,
+		PasswordHash: string(hash),
+		DeviceIDs:    []string{},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		LastActiveAt: now,
+	}
+	if _, err := s.db.GetCollection("users").InsertOne(ctx, user); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.NewErrorWithMessage(errors.ErrAlreadyExists, "用户名已被注册")
+		}
+		return nil, err
+	}
+
+	return s.signIn(ctx, &user, deviceID, claimDevice)
+}
+
+// Login 使用用户名和密码登录，claimDevice为true且首次在设备上登录时迁移设备数据
+func (s *NovelService) Login(ctx context.Context, deviceID, username, password string, claimDevice bool) (*models.AuthResponse, error) {
+	var user models.User
+	err := s.db.GetCollection("users").FindOne(ctx, bson.M{"username": username}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.NewError(errors.ErrInvalidCredentials)
+		}
+		return nil, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, errors.NewError(errors.ErrInvalidCredentials)
+	}
+
+	return s.signIn(ctx, &user, deviceID, claimDevice)
+}
+
+// Logout 注销令牌对应的会话
+func (s *NovelService) Logout(ctx context.Context, token string) error {
+	id := hashToken(token)
+	if _, err := s.db.GetCollection("sessions").DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return err
+	}
+	s.cache.Delete(ctx, cache.SessionKey+id)
+	return nil
+}
+
+// ValidateToken 校验登录令牌，返回对应的会话
+func (s *NovelService) ValidateToken(ctx context.Context, token string) (*models.Session, error) {
+	id := hashToken(token)
+	cacheKey := cache.SessionKey + id
+
+	// 会话只缓存在Redis中，注销后其他实例立即失效
+	var session models.Session
+	if err := s.cache.GetShared(ctx, cacheKey, &session); err == nil && session.UserID != "" {
+		if time.Now().Before(session.ExpiresAt) {
+			return &session, nil
+		}
+		s.cache.Delete(ctx, cacheKey)
+		return nil, errors.NewError(errors.ErrUnauthorized)
+	}
+
+	err := s.db.GetCollection("sessions").FindOne(ctx, bson.M{
+		"_id":       id,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&session)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.NewError(errors.ErrUnauthorized)
+		}
+		return nil, err
+	}
+
+	// 缓存时间不超过会话剩余有效期
+	s.cache.SetShared(ctx, cacheKey, session, min(s.cfg.Cache.User, time.Until(session.ExpiresAt)))
+	return &session, nil
+}
+
+// GetUserDevices 获取账号绑定的设备
+func (s *NovelService) GetUserDevices(ctx context.Context, userID string) ([]models.Device, error) {
+	opts := options.Find().SetSort(bson.M{"lastSeen": -1})
+	cursor, err := s.db.GetCollection("devices").Find(ctx, bson.M{"userId": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	devices := []models.Device{}
+	if err = cursor.All(ctx, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// UnlinkDevice 解除设备与账号的绑定并注销该设备上的会话，已迁移的数据仍归账号所有
+func (s *NovelService) UnlinkDevice(ctx context.Context, userID, deviceID string) error {
+	result, err := s.db.GetCollection("devices").UpdateOne(ctx,
+		bson.M{"_id": deviceID, "userId": userID},
+		bson.M{"$unset": bson.M{"userId": ""}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.NewError(errors.ErrNotFound)
+	}
+
+	_, err = s.db.GetCollection("users").UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$pull": bson.M{"deviceIds": deviceID}},
+	)
+	if err != nil {
+		return err
+	}
+	s.cache.Delete(ctx, cache.UserKey+userID)
+
+	return s.deleteSessions(ctx, bson.M{"userId": userID, "deviceId": deviceID})
+}
+
+// signIn 绑定设备、迁移设备数据并签发令牌
+//
+// 只有客户端明确携带了自己的设备ID（claimDevice）时才绑定设备并迁移数据，
+// 按IP匹配到的设备可能是共用出口IP的其他人的，只签发令牌
+func (s *NovelService) signIn(ctx context.Context, user *models.User, deviceID string, claimDevice bool) (*models.AuthResponse, error) {
+	var device models.Device
+	err := s.db.GetCollection("devices").FindOne(ctx, bson.M{"_id": deviceID}).Decode(&device)
+	if err != nil {
+		return nil, err
+	}
+
+	// 设备从未绑定过账号时，其匿名数据归入该账号
+	if claimDevice && device.UserID == "" {
+		if err := s.migrateDeviceData(ctx, deviceID, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if claimDevice && device.UserID != user.ID {
+		_, err = s.db.GetCollection("devices").UpdateOne(ctx,
+			bson.M{"_id": deviceID},
+			bson.M{"$set": bson.M{"userId": user.ID}},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if device.UserID != "" {
+			s.db.GetCollection("users").UpdateOne(ctx,
+				bson.M{"_id": device.UserID},
+				bson.M{"$pull": bson.M{"deviceIds": deviceID}},
+			)
+			s.cache.Delete(ctx, cache.UserKey+device.UserID)
+		}
+	}
+
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"lastActiveAt": now}}
+	if claimDevice {
+		update["$addToSet"] = bson.M{"deviceIds": deviceID}
+	}
+	err = s.db.GetCollection("users").FindOneAndUpdate(ctx,
+		bson.M{"_id": user.ID},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(user)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Delete(ctx, cache.UserKey+user.ID)
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	session := models.Session{
+		ID:        hashToken(token),
+		UserID:    user.ID,
+		DeviceID:  deviceID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.cfg.Auth.TokenTTL),
+	}
+	if _, err := s.db.GetCollection("sessions").InsertOne(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponse{
+		Token:     token,
+		ExpiresAt: session.ExpiresAt,
+		User:      user,
+	}, nil
+}
+
+// migrateDeviceData 将设备名下的收藏、书签、阅读历史、阅读进度和评论迁移到账号
+func (s *NovelService) migrateDeviceData(ctx context.Context, deviceID, userID string) error {
+	from := bson.M{"deviceId": deviceID}
+
+	// 收藏：账号已收藏的保留原记录
+	var favorites []models.Favorite
+	if err := s.findAll(ctx, "favorites", from, &favorites); err != nil {
+		return err
+	}
+	for _, f := range favorites {
+		_, err := s.db.GetCollection("favorites").UpdateOne(ctx,
+			bson.M{"deviceId": userID, "novelId": f.NovelID},
+			bson.M{"$setOnInsert": bson.M{"createdAt": f.CreatedAt, "updatedAt": f.UpdatedAt}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+		if err := s.syncFavoriteShelf(ctx, userID, f.NovelID, true); err != nil {
+			return err
+		}
+	}
+
+	// 阅读历史和进度：按同步冲突规则合并，并生成新的同步版本号
+	var histories []models.ReadHistory
+	if err := s.findAll(ctx, "read_history", from, &histories); err != nil {
+		return err
+	}
+	for _, h := range histories {
+		_, err := s.applyHistory(ctx, userID, models.HistoryChange{
+			NovelID:   h.NovelID,
+			LastRead:  h.LastRead,
+			UpdatedAt: latest(h.ClientUpdatedAt, h.LastRead),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var progresses []models.ReadProgress
+	if err := s.findAll(ctx, "read_progress", from, &progresses); err != nil {
+		return err
+	}
+	for _, p := range progresses {
+		_, err := s.applyProgress(ctx, userID, models.ProgressChange{
+			NovelID:       p.NovelID,
+			VolumeNumber:  p.VolumeNumber,
+			ChapterNumber: p.ChapterNumber,
+			Position:      p.Position,
+			UpdatedAt:     latest(p.ClientUpdatedAt, p.UpdatedAt),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range []string{"favorites", "read_history", "read_progress"} {
+		if _, err := s.db.GetCollection(name).DeleteMany(ctx, from); err != nil {
+			return err
+		}
+	}
+
+	// 书签、评论和阅读会话直接改归属，书签同时更新同步版本号
+	version, err := s.nextSyncVersion(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.GetCollection("bookmarks").UpdateMany(ctx, from, bson.M{"$set": bson.M{"deviceId": userID, "version": version}})
+	if err != nil {
+		return err
+	}
+	_, err = s.db.GetCollection("comments").UpdateMany(ctx, from, bson.M{"$set": bson.M{"deviceId": userID}})
+	if err != nil {
+		return err
+	}
+	_, err = s.db.GetCollection("read_sessions").UpdateMany(ctx, from, bson.M{"$set": bson.M{"deviceId": userID}})
+	if err != nil {
+		return err
+	}
+
+	if err := s.migrateReviews(ctx, deviceID, userID); err != nil {
+		return err
+	}
+	if err := s.migrateBookshelves(ctx, deviceID, userID); err != nil {
+		return err
+	}
+	if err := s.migrateNotifications(ctx, deviceID, userID); err != nil {
+		return err
+	}
+
+	// 评论点赞：账号已点过赞的评论去掉重复的一次计数
+	var likes []models.CommentLike
+	if err := s.findAll(ctx, "comment_likes", from, &likes); err != nil {
+		return err
+	}
+	for _, like := range likes {
+		_, err := s.db.GetCollection("comment_likes").UpdateOne(ctx,
+			bson.M{"_id": like.ID},
+			bson.M{"$set": bson.M{"deviceId": userID}},
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			if _, err := s.db.GetCollection("comment_likes").DeleteOne(ctx, bson.M{"_id": like.ID}); err != nil {
+				return err
+			}
+			_, err = s.db.GetCollection("comments").UpdateOne(ctx,
+				bson.M{"_id": like.CommentID},
+				bson.M{"$inc": bson.M{"likeCount": -1}},
+			)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, id := range []string{deviceID, userID} {
+		s.cache.Delete(ctx, cache.FavoriteKey+id)
+		s.cache.Delete(ctx, cache.ReadHistoryKey+id)
+		s.cache.DeleteByPattern(ctx, cache.ReadProgressKey+id+":*")
+		s.cache.Delete(ctx, cache.ReadingStatKey+id)
+		s.cache.Delete(ctx, fmt.Sprintf("%s:%s", cache.BookmarkKey, id))
+	}
+	s.cache.DeleteByPattern(ctx, cache.CommentListKey+"*")
+
+	log.Printf("Migrated data of device %s to user %s", deviceID, userID)
+	return nil
+}
+
+// findAll 查询集合中的全部匹配文档
+func (s *NovelService) findAll(ctx context.Context, collection string, filter bson.M, results interface{}) error {
+	cursor, err := s.db.GetCollection(collection).Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, results)
+}
+
+// deleteSessions 删除会话并清除对应缓存
+func (s *NovelService) deleteSessions(ctx context.Context, filter bson.M) error {
+	var sessions []models.Session
+	if err := s.findAll(ctx, "sessions", filter, &sessions); err != nil {
+		return err
+	}
+	if _, err := s.db.GetCollection("sessions").DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		s.cache.Delete(ctx, cache.SessionKey+session.ID)
+	}
+	return nil
+}
+
+// validateCredentials 校验用户名和密码格式
+func validateCredentials(username, password string) error {
+	n := utf8.RuneCountInString(username)
+	if n < minUsernameLen || n > maxUsernameLen {
+		return errors.NewErrorWithMessage(errors.ErrInvalidParameter, fmt.Sprintf("用户名长度需为%d-%d个字符", minUsernameLen, maxUsernameLen))
+	}
+	for _, r := range username {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return errors.NewErrorWithMessage(errors.ErrInvalidParameter, "用户名只能包含字母、数字和下划线")
+		}
+	}
+	if len(password) < minPasswordLen || len(password) > maxPasswordLen {
+		return errors.NewErrorWithMessage(errors.ErrInvalidParameter, fmt.Sprintf("密码长度需为%d-%d个字符", minPasswordLen, maxPasswordLen))
+	}
+	return nil
+}
+
+// newToken 生成随机的不透明令牌
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken 计算令牌摘要，数据库和缓存中只保存摘要
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
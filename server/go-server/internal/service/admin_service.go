@@ -0,0 +1,513 @@
+// ****************************************************************************
+//
+// @file       admin_service.go
+// @brief      管理端对小说、卷、章节的增删改
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"lightnovel/internal/models"
+	"lightnovel/pkg/cache"
+	"lightnovel/pkg/errors"
+	"lightnovel/pkg/textindex"
+)
+
+// NovelFields 可编辑的小说字段，nil表示不修改
+type NovelFields struct {
+	Title       *string
+	Author      *string
+	Description *string
+	Cover       *string
+	Tags        []string
+	Status      *string
+}
+
+// ChapterFields 可编辑的章节字段，nil表示不修改
+type ChapterFields struct {
+	Title   *string
+	Content *string
+}
+
+// CreateNovel 创建小说
+func (s *NovelService) CreateNovel(ctx context.Context, fields NovelFields) (*models.Novel, error) {
+	if fields.Title == nil || *fields.Title == "" {
+		return nil, errors.NewError(errors.ErrInvalidParameter)
+	}
+
+	collection := s.db.GetCollection("novels")
+	count, err := collection.CountDocuments(ctx, bson.M{"title": *fields.Title})
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errors.NewError(errors.ErrAlreadyExists)
+	}
+
+	now := time.Now()
+	novel := models.Novel{
+		ID:        primitive.NewObjectID(),
+		Title:     *fields.Title,
+		Tags:      []string{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if fields.Author != nil {
+		novel.Author = *fields.Author
+	}
+	if fields.Description != nil {
+		novel.Description = *fields.Description
+	}
+	if fields.Cover != nil {
+		novel.Cover = *fields.Cover
+	}
+	if fields.Tags != nil {
+		novel.Tags = fields.Tags
+	}
+	if fields.Status != nil {
+		novel.Status = *fields.Status
+	}
+
+	if _, err := collection.InsertOne(ctx, novel); err != nil {
+		return nil, err
+	}
+
+	s.invalidateNovelCache(ctx, novel.ID.Hex())
+	return &novel, nil
+}
+
+// UpdateNovel 更新小说信息
+func (s *NovelService) UpdateNovel(ctx context.Context, novelID string, fields NovelFields) (*models.Novel, error) {
+	objectID, err := primitive.ObjectIDFromHex(novelID)
+	if err != nil {
+		return nil, errors.NewError(errors.ErrInvalidParameter)
+	}
+
+	set := bson.M{"updatedAt": time.Now()}
+	if fields.Title != nil {
+		if *fields.Title == "" {
+			return nil, errors.NewError(errors.ErrInvalidParameter)
+		}
+		count, err := s.db.GetCollection("novels").CountDocuments(ctx, bson.M{
+			"title": *fields.Title,
+			"_id":   bson.M{"$ne": objectID},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, errors.NewError(errors.ErrAlreadyExists)
+		}
+		set["title"] = *fields.Title
+	}
+	if fields.Author != nil {
+		set["author"] = *fields.Author
+	}
+	if fields.Description != nil {
+		set["description"] = *fields.Description
+	}
+	if fields.Cover != nil {
+		set["cover"] = *fields.Cover
+	}
+	if fields.Tags != nil {
+		set["tags"] = fields.Tags
+	}
+	if fields.Status != nil {
+		set["status"] = *fields.Status
+	}
+
+	var novel models.Novel
+	err = s.db.GetCollection("novels").FindOneAndUpdate(ctx,
+		bson.M{"_id": objectID},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&novel)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.NewError(errors.ErrNovelNotFound)
+		}
+		return nil, err
+	}
+
+	s.NotifyNovelUpdate(novelID, novel.Title, UpdateContentChange)
+	return &novel, nil
+}
+
+// DeleteNovel 删除小说及其卷、章节和用户数据
+func (s *NovelService) DeleteNovel(ctx context.Context, novelID string) error {
+	objectID, err := primitive.ObjectIDFromHex(novelID)
+	if err != nil {
+		return errors.NewError(errors.ErrInvalidParameter)
+	}
+
+	result, err := s.db.GetCollection("novels").DeleteOne(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.NewError(errors.ErrNovelNotFound)
+	}
+
+	// 关联数据中的novelId均以字符串保存
+	filter := bson.M{"novelId": novelID}
+	for _, name := range []string{"chapters", "chapter_index", "volumes", "comments", "comment_likes", "reports", "reviews", "bookmarks", "favorites", "read_history", "read_progress", "read_sessions", "rank_daily", "shelf_items", "follows"} {
+		if _, err := s.db.GetCollection(name).DeleteMany(ctx, filter); err != nil {
+			return err
+		}
+	}
+	for _, name := range []string{"similarities", "related"} {
+		if _, err := s.db.GetCollection(name).DeleteOne(ctx, bson.M{"_id": novelID}); err != nil {
+			return err
+		}
+	}
+
+	// Redis中的排行榜计数、待写入的阅读量、独立读者数和在读记录
+	if err := s.removeFromRankings(ctx, novelID); err != nil {
+		return err
+	}
+	if err := s.cache.HDel(ctx, cache.ReadPendingKey, novelID); err != nil {
+		return err
+	}
+	s.cache.Delete(ctx, cache.ReadersKey+novelID)
+	s.cache.Delete(ctx, cache.PresenceKey+novelID)
+
+	s.invalidateNovelCache(ctx, novelID)
+	s.cache.DeleteByPattern(ctx, cache.CommentListKey+novelID+"*")
+	return nil
+}
+
+// CreateVolume 创建卷，volumeNumber为0时使用下一个卷号
+func (s *NovelService) CreateVolume(ctx context.Context, novelID string, volumeNumber int) (*models.Volume, error) {
+	novel, err := s.getNovelForWrite(ctx, novelID)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := s.db.GetCollection("volumes")
+	if volumeNumber == 0 {
+		volumeNumber, err = s.nextNumber(ctx, "volumes", bson.M{"novelId": novelID}, "volumeNumber")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	volume := models.Volume{
+		ID:           primitive.NewObjectID(),
+		NovelID:      novel.ID,
+		VolumeNumber: volumeNumber,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	_, err = collection.InsertOne(ctx, bson.M{
+		"_id":          volume.ID,
+		"novelId":      novelID,
+		"volumeNumber": volume.VolumeNumber,
+		"chapterCount": 0,
+		"createdAt":    now,
+		"updatedAt":    now,
+	})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.NewError(errors.ErrAlreadyExists)
+		}
+		return nil, err
+	}
+
+	if err := s.syncVolumeCount(ctx, novel.ID); err != nil {
+		return nil, err
+	}
+
+	s.NotifyNovelUpdate(novelID, novel.Title, UpdateNewVolume)
+	return &volume, nil
+}
+
+// DeleteVolume 删除卷及其章节
+func (s *NovelService) DeleteVolume(ctx context.Context, novelID string, volumeNumber int) error {
+	novel, err := s.getNovelForWrite(ctx, novelID)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.GetCollection("volumes").DeleteOne(ctx, bson.M{
+		"novelId":      novelID,
+		"volumeNumber": volumeNumber,
+	})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.NewError(errors.ErrVolumeNotFound)
+	}
+
+	filter := bson.M{"novelId": novelID, "volumeNumber": volumeNumber}
+	cursor, err := s.db.GetCollection("chapters").Find(ctx, filter, options.Find().SetProjection(bson.M{"chapterNumber": 1}))
+	if err != nil {
+		return err
+	}
+	var chapters []models.Chapter
+	if err := cursor.All(ctx, &chapters); err != nil {
+		return err
+	}
+	if _, err = s.db.GetCollection("chapters").DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+	for _, chapter := range chapters {
+		if err := s.cleanupChapter(ctx, novelID, volumeNumber, chapter.ChapterNumber); err != nil {
+			return err
+		}
+	}
+
+	if err := s.syncVolumeCount(ctx, novel.ID); err != nil {
+		return err
+	}
+	s.cache.DeleteByPattern(ctx, cache.CommentListKey+novelID+"*")
+
+	s.NotifyNovelUpdate(novelID, novel.Title, UpdateContentChange)
+	return nil
+}
+
+// CreateChapter 创建章节，chapterNumber为0时使用卷内下一个章节号
+func (s *NovelService) CreateChapter(ctx context.Context, novelID string, volumeNumber, chapterNumber int, title, content string) (*models.Chapter, error) {
+	novel, err := s.getNovelForWrite(ctx, novelID)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.db.GetCollection("volumes").CountDocuments(ctx, bson.M{
+		"novelId":      novelID,
+		"volumeNumber": volumeNumber,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, errors.NewError(errors.ErrVolumeNotFound)
+	}
+
+	if chapterNumber == 0 {
+		chapterNumber, err = s.nextNumber(ctx, "chapters", bson.M{"novelId": novelID, "volumeNumber": volumeNumber}, "chapterNumber")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if title == "" {
+		title = ExtractChapterTitle(content)
+	}
+
+	now := time.Now()
+	chapter := models.Chapter{
+		ID:            primitive.NewObjectID(),
+		NovelID:       novel.ID,
+		VolumeNumber:  volumeNumber,
+		ChapterNumber: chapterNumber,
+		Title:         title,
+		Content:       content,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	_, err = s.db.GetCollection("chapters").InsertOne(ctx, bson.M{
+		"_id":           chapter.ID,
+		"novelId":       novelID,
+		"volumeNumber":  volumeNumber,
+		"chapterNumber": chapterNumber,
+		"title":         title,
+		"content":       content,
+		"hasImages":     false,
+		"imageCount":    0,
+		"createdAt":     now,
+		"updatedAt":     now,
+	})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.NewError(errors.ErrAlreadyExists)
+		}
+		return nil, err
+	}
+
+	if err := s.syncChapterCount(ctx, novelID, volumeNumber); err != nil {
+		return nil, err
+	}
+	if err := textindex.Update(ctx, s.db.GetCollection("chapter_index"), novelID, volumeNumber, chapterNumber, content, now); err != nil {
+		return nil, err
+	}
+
+	s.NotifyNovelUpdate(novelID, novel.Title, UpdateNewChapter)
+	return &chapter, nil
+}
+
+// UpdateChapter 更新章节标题或内容
+func (s *NovelService) UpdateChapter(ctx context.Context, novelID string, volumeNumber, chapterNumber int, fields ChapterFields) (*models.Chapter, error) {
+	novel, err := s.getNovelForWrite(ctx, novelID)
+	if err != nil {
+		return nil, err
+	}
+
+	set := bson.M{"updatedAt": time.Now()}
+	if fields.Title != nil {
+		set["title"] = *fields.Title
+	}
+	if fields.Content != nil {
+		set["content"] = *fields.Content
+	}
+
+	var chapter models.Chapter
+	err = s.db.GetCollection("chapters").FindOneAndUpdate(ctx,
+		bson.M{
+			"novelId":       novelID,
+			"volumeNumber":  volumeNumber,
+			"chapterNumber": chapterNumber,
+		},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&chapter)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.NewError(errors.ErrChapterNotFound)
+		}
+		return nil, err
+	}
+
+	// 正文修改后更新索引并重新定位段评
+	if err := textindex.Update(ctx, s.db.GetCollection("chapter_index"), novelID, volumeNumber, chapterNumber, chapter.Content, chapter.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if fields.Content != nil {
+		if err := s.reanchorComments(ctx, novelID, volumeNumber, chapterNumber, chapter.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	s.NotifyNovelUpdate(novelID, novel.Title, UpdateContentChange)
+	return &chapter, nil
+}
+
+// DeleteChapter 删除章节
+func (s *NovelService) DeleteChapter(ctx context.Context, novelID string, volumeNumber, chapterNumber int) error {
+	novel, err := s.getNovelForWrite(ctx, novelID)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.GetCollection("chapters").DeleteOne(ctx, bson.M{
+		"novelId":       novelID,
+		"volumeNumber":  volumeNumber,
+		"chapterNumber": chapterNumber,
+	})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.NewError(errors.ErrChapterNotFound)
+	}
+
+	if err := s.syncChapterCount(ctx, novelID, volumeNumber); err != nil {
+		return err
+	}
+	if err := s.cleanupChapter(ctx, novelID, volumeNumber, chapterNumber); err != nil {
+		return err
+	}
+
+	s.NotifyNovelUpdate(novelID, novel.Title, UpdateContentChange)
+	return nil
+}
+
+// cleanupChapter 章节删除后删除其正文索引，并将段评标记为失效
+func (s *NovelService) cleanupChapter(ctx context.Context, novelID string, volumeNumber, chapterNumber int) error {
+	if err := textindex.Remove(ctx, s.db.GetCollection("chapter_index"), novelID, volumeNumber, chapterNumber); err != nil {
+		return err
+	}
+	return s.orphanComments(ctx, novelID, volumeNumber, chapterNumber)
+}
+
+// getNovelForWrite 写操作前直接从数据库读取小说，避免使用缓存中的旧数据
+func (s *NovelService) getNovelForWrite(ctx context.Context, novelID string) (*models.Novel, error) {
+	objectID, err := primitive.ObjectIDFromHex(novelID)
+	if err != nil {
+		return nil, errors.NewError(errors.ErrInvalidParameter)
+	}
+
+	var novel models.Novel
+	err = s.db.GetCollection("novels").FindOne(ctx, bson.M{"_id": objectID}).Decode(&novel)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.NewError(errors.ErrNovelNotFound)
+		}
+		return nil, err
+	}
+	return &novel, nil
+}
+
+// nextNumber 获取集合中指定字段的下一个序号
+func (s *NovelService) nextNumber(ctx context.Context, collection string, filter bson.M, field string) (int, error) {
+	var doc bson.M
+	opts := options.FindOne().SetSort(bson.D{{Key: field, Value: -1}}).SetProjection(bson.M{field: 1})
+	err := s.db.GetCollection(collection).FindOne(ctx, filter, opts).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	switch n := doc[field].(type) {
+	case int32:
+		return int(n) + 1, nil
+	case int64:
+		return int(n) + 1, nil
+	default:
+		return 0, fmt.Errorf("unexpected %s type %T", field, n)
+	}
+}
+
+// syncVolumeCount 按实际卷数更新小说的volumeCount
+func (s *NovelService) syncVolumeCount(ctx context.Context, novelID primitive.ObjectID) error {
+	count, err := s.db.GetCollection("volumes").CountDocuments(ctx, bson.M{"novelId": novelID.Hex()})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.GetCollection("novels").UpdateOne(ctx,
+		bson.M{"_id": novelID},
+		bson.M{"$set": bson.M{"volumeCount": count, "updatedAt": time.Now()}},
+	)
+	return err
+}
+
+// syncChapterCount 按实际章节数更新卷的chapterCount
+func (s *NovelService) syncChapterCount(ctx context.Context, novelID string, volumeNumber int) error {
+	filter := bson.M{"novelId": novelID, "volumeNumber": volumeNumber}
+	count, err := s.db.GetCollection("chapters").CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = s.db.GetCollection("volumes").UpdateOne(ctx, filter,
+		bson.M{"$set": bson.M{"chapterCount": count, "updatedAt": now}},
+	)
+	if err != nil {
+		return err
+	}
+
+	// 章节变化同时刷新小说的更新时间，使其出现在最新列表中
+	objectID, _ := primitive.ObjectIDFromHex(novelID)
+	_, err = s.db.GetCollection("novels").UpdateOne(ctx,
+		bson.M{"_id": objectID},
+		bson.M{"$set": bson.M{"updatedAt": now}},
+	)
+	return err
+}
@@ -0,0 +1,566 @@
+// ****************************************************************************
+//
+// @file       bookshelf_service.go
+// @brief      书单：默认书单、自建书单、排序、备注与公开分享
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"lightnovel/internal/models"
+	"lightnovel/pkg/cache"
+	"lightnovel/pkg/errors"
+)
+
+// 默认书单类型
+const (
+	ShelfFavorites = "favorites" // 收藏，与 /user/favorites 保持一致
+	ShelfReading   = "reading"   // 在读
+	ShelfPlan      = "plan"      // 想读
+	ShelfDropped   = "dropped"   // 弃坑
+)
+
+// maxBookshelves 每个用户最多的书单数，包含默认书单
+const maxBookshelves = 50
+
+// defaultShelves 默认书单及其名称，按顺序排列
+var defaultShelves = []struct {
+	Kind string
+	Name string
+}{
+	{ShelfFavorites, "收藏"},
+	{ShelfReading, "在读"},
+	{ShelfPlan, "想读"},
+	{ShelfDropped, "弃坑"},
+}
+
+// statusShelves 阅读状态书单，一部小说同时只会在其中一个
+var statusShelves = []string{ShelfReading, ShelfPlan, ShelfDropped}
+
+// GetBookshelves 获取用户的全部书单，首次访问时创建默认书单
+func (s *NovelService) GetBookshelves(ctx context.Context, deviceID string) ([]models.Bookshelf, error) {
+	if err := s.ensureBookshelves(ctx, deviceID); err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "position", Value: 1}, {Key: "createdAt", Value: 1}})
+	cursor, err := s.db.GetCollection("bookshelves").Find(ctx, bson.M{"deviceId": deviceID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	shelves := []models.Bookshelf{}
+	if err = cursor.All(ctx, &shelves); err != nil {
+		return nil, err
+	}
+
+	// 统计每个书单中的小说数
+	cursor, err = s.db.GetCollection("shelf_items").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"deviceId": deviceID}}},
+		{{Key: "$group", Value: bson.M{"_id": "$shelfId", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var counts []struct {
+		ShelfID primitive.ObjectID `bson:"_id"`
+		Count   int64              `bson:"count"`
+	}
+	if err = cursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+	byShelf := make(map[primitive.ObjectID]int64, len(counts))
+	for _, c := range counts {
+		byShelf[c.ShelfID] = c.Count
+	}
+	for i := range shelves {
+		shelves[i].ItemCount = byShelf[shelves[i].ID]
+	}
+
+	return shelves, nil
+}
+
+// CreateBookshelf 创建自建书单
+func (s *NovelService) CreateBookshelf(ctx context.Context, deviceID, name, note string, public bool) (*models.Bookshelf, error) {
+	if err := s.ensureBookshelves(ctx, deviceID); err != nil {
+		return nil, err
+	}
+
+	collection := s.db.GetCollection("bookshelves")
+	count, err := collection.CountDocuments(ctx, bson.M{"deviceId": deviceID})
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxBookshelves {
+		return nil, errors.NewErrorWithMessage(errors.ErrInvalidParameter, fmt.Sprintf("最多创建%d个书单", maxBookshelves))
+	}
+
+	position, err := s.nextNumber(ctx, "bookshelves", bson.M{"deviceId": deviceID}, "position")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	shelf := models.Bookshelf{
+		ID:        primitive.NewObjectID(),
+		DeviceID:  deviceID,
+		Name:      name,
+		Note:      note,
+		Public:    public,
+		Position:  position,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if public {
+		if shelf.ShareCode, err = newShareCode(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := collection.InsertOne(ctx, shelf); err != nil {
+		return nil, err
+	}
+	return &shelf, nil
+}
+
+// UpdateBookshelf 修改书单名称、简介和公开状态，为nil的字段不修改。公开时生成分享编码，取消公开后原链接失效
+func (s *NovelService) UpdateBookshelf(ctx context.Context, deviceID, shelfID string, name, note *string, public *bool) (*models.Bookshelf, error) {
+	shelf, err := s.getBookshelf(ctx, deviceID, shelfID)
+	if err != nil {
+		return nil, err
+	}
+
+	set := bson.M{"updatedAt": time.Now()}
+	update := bson.M{}
+	if name != nil {
+		set["name"] = *name
+	}
+	if note != nil {
+		set["note"] = *note
+	}
+	if public != nil {
+		set["public"] = *public
+		if *public && shelf.ShareCode == "" {
+			code, err := newShareCode()
+			if err != nil {
+				return nil, err
+			}
+			set["shareCode"] = code
+		} else if !*public {
+			update["$unset"] = bson.M{"shareCode": ""}
+		}
+	}
+	update["$set"] = set
+
+	var updated models.Bookshelf
+	err = s.db.GetCollection("bookshelves").FindOneAndUpdate(ctx,
+		bson.M{"_id": shelf.ID},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteBookshelf 删除自建书单及其中的条目，默认书单不能删除
+func (s *NovelService) DeleteBookshelf(ctx context.Context, deviceID, shelfID string) error {
+	shelf, err := s.getBookshelf(ctx, deviceID, shelfID)
+	if err != nil {
+		return err
+	}
+	if shelf.Kind != "" {
+		return errors.NewErrorWithMessage(errors.ErrInvalidParameter, "默认书单不能删除")
+	}
+
+	if _, err := s.db.GetCollection("bookshelves").DeleteOne(ctx, bson.M{"_id": shelf.ID}); err != nil {
+		return err
+	}
+	_, err = s.db.GetCollection("shelf_items").DeleteMany(ctx, bson.M{"shelfId": shelf.ID})
+	return err
+}
+
+// ReorderBookshelves 按给定顺序排列书单，未列出的书单保持原位置
+func (s *NovelService) ReorderBookshelves(ctx context.Context, deviceID string, shelfIDs []string) error {
+	writes := make([]mongo.WriteModel, 0, len(shelfIDs))
+	for i, shelfID := range shelfIDs {
+		id, err := primitive.ObjectIDFromHex(shelfID)
+		if err != nil {
+			return errors.NewError(errors.ErrInvalidParameter)
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": id, "deviceId": deviceID}).
+			SetUpdate(bson.M{"$set": bson.M{"position": i + 1}}))
+	}
+	return s.bulkWrite(ctx, "bookshelves", writes)
+}
+
+// GetBookshelf 获取自己的书单及其中的小说
+func (s *NovelService) GetBookshelf(ctx context.Context, deviceID, shelfID string) (*models.BookshelfDetail, error) {
+	shelf, err := s.getBookshelf(ctx, deviceID, shelfID)
+	if err != nil {
+		return nil, err
+	}
+	return s.bookshelfDetail(ctx, shelf)
+}
+
+// GetSharedBookshelf 通过分享编码获取公开的书单
+func (s *NovelService) GetSharedBookshelf(ctx context.Context, code string) (*models.BookshelfDetail, error) {
+	var shelf models.Bookshelf
+	err := s.db.GetCollection("bookshelves").FindOne(ctx, bson.M{"shareCode": code, "public": true}).Decode(&shelf)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.NewError(errors.ErrNotFound)
+		}
+		return nil, err
+	}
+	return s.bookshelfDetail(ctx, &shelf)
+}
+
+// AddToBookshelf 将小说加入书单。加入收藏书单等同于收藏，加入阅读状态书单时会从其他状态书单中移出
+func (s *NovelService) AddToBookshelf(ctx context.Context, deviceID, shelfID, novelID, note string) (*models.ShelfEntry, error) {
+	shelf, err := s.getBookshelf(ctx, deviceID, shelfID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.GetNovelByID(ctx, novelID); err != nil {
+		return nil, err
+	}
+
+	switch shelf.Kind {
+	case ShelfFavorites:
+		now := time.Now()
+		_, err := s.db.GetCollection("favorites").InsertOne(ctx, models.Favorite{
+			ID:        primitive.NewObjectID(),
+			DeviceID:  deviceID,
+			NovelID:   novelID,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+		if err == nil {
+			s.recordRanking(ctx, RankingFavorites, novelID)
+		}
+		s.cache.Delete(ctx, cache.FavoriteKey+deviceID)
+	case ShelfReading, ShelfPlan, ShelfDropped:
+		if err := s.leaveStatusShelves(ctx, deviceID, novelID, shelf.Kind); err != nil {
+			return nil, err
+		}
+	}
+
+	entry, err := s.insertShelfEntry(ctx, shelf, novelID, note)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, errors.NewError(errors.ErrAlreadyExists)
+	}
+	return entry, err
+}
+
+// UpdateShelfEntry 修改书单中小说的备注
+func (s *NovelService) UpdateShelfEntry(ctx context.Context, deviceID, shelfID, novelID, note string) (*models.ShelfEntry, error) {
+	shelf, err := s.getBookshelf(ctx, deviceID, shelfID)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry models.ShelfEntry
+	err = s.db.GetCollection("shelf_items").FindOneAndUpdate(ctx,
+		bson.M{"shelfId": shelf.ID, "novelId": novelID},
+		bson.M{"$set": bson.M{"note": note}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&entry)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.NewError(errors.ErrNotFound)
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// RemoveFromBookshelf 将小说移出书单，移出收藏书单等同于取消收藏
+func (s *NovelService) RemoveFromBookshelf(ctx context.Context, deviceID, shelfID, novelID string) error {
+	shelf, err := s.getBookshelf(ctx, deviceID, shelfID)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.GetCollection("shelf_items").DeleteOne(ctx, bson.M{"shelfId": shelf.ID, "novelId": novelID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.NewError(errors.ErrNotFound)
+	}
+
+	if shelf.Kind == ShelfFavorites {
+		_, err = s.db.GetCollection("favorites").DeleteOne(ctx, bson.M{"deviceId": deviceID, "novelId": novelID})
+		if err != nil {
+			return err
+		}
+		s.cache.Delete(ctx, cache.FavoriteKey+deviceID)
+	}
+	return nil
+}
+
+// ReorderBookshelf 按给定顺序排列书单中的小说，未列出的小说保持原位置
+func (s *NovelService) ReorderBookshelf(ctx context.Context, deviceID, shelfID string, novelIDs []string) error {
+	shelf, err := s.getBookshelf(ctx, deviceID, shelfID)
+	if err != nil {
+		return err
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(novelIDs))
+	for i, novelID := range novelIDs {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"shelfId": shelf.ID, "novelId": novelID}).
+			SetUpdate(bson.M{"$set": bson.M{"position": i + 1}}))
+	}
+	return s.bulkWrite(ctx, "shelf_items", writes)
+}
+
+// syncFavoriteShelf 收藏或取消收藏后同步收藏书单，还没有收藏书单时不处理，创建时会迁移已有的收藏
+func (s *NovelService) syncFavoriteShelf(ctx context.Context, deviceID, novelID string, favorite bool) error {
+	var shelf models.Bookshelf
+	err := s.db.GetCollection("bookshelves").FindOne(ctx, bson.M{"deviceId": deviceID, "kind": ShelfFavorites}).Decode(&shelf)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !favorite {
+		_, err = s.db.GetCollection("shelf_items").DeleteOne(ctx, bson.M{"shelfId": shelf.ID, "novelId": novelID})
+		return err
+	}
+	if _, err := s.insertShelfEntry(ctx, &shelf, novelID, ""); err != nil && !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	return nil
+}
+
+// ensureBookshelves 创建缺少的默认书单，新建收藏书单时把已有的收藏迁移进去
+func (s *NovelService) ensureBookshelves(ctx context.Context, deviceID string) error {
+	collection := s.db.GetCollection("bookshelves")
+	count, err := collection.CountDocuments(ctx, bson.M{"deviceId": deviceID, "kind": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	if count >= int64(len(defaultShelves)) {
+		return nil
+	}
+
+	now := time.Now()
+	for i, d := range defaultShelves {
+		shelfID := primitive.NewObjectID()
+		result, err := collection.UpdateOne(ctx,
+			bson.M{"deviceId": deviceID, "kind": d.Kind},
+			bson.M{"$setOnInsert": bson.M{
+				"_id":       shelfID,
+				"name":      d.Name,
+				"note":      "",
+				"public":    false,
+				"position":  i + 1,
+				"createdAt": now,
+				"updatedAt": now,
+			}},
+			options.Update().SetUpsert(true),
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if result.UpsertedCount == 0 || d.Kind != ShelfFavorites {
+			continue
+		}
+
+		// 迁移已有的收藏
+		var favorites []models.Favorite
+		if err := s.findAll(ctx, "favorites", bson.M{"deviceId": deviceID}, &favorites); err != nil {
+			return err
+		}
+		shelf := &models.Bookshelf{ID: shelfID, DeviceID: deviceID}
+		for _, favorite := range favorites {
+			if _, err := s.insertShelfEntry(ctx, shelf, favorite.NovelID, ""); err != nil && !mongo.IsDuplicateKeyError(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// migrateBookshelves 将设备的书单迁移到账号，默认书单中的小说合并进账号的同类书单
+func (s *NovelService) migrateBookshelves(ctx context.Context, deviceID, userID string) error {
+	var shelves []models.Bookshelf
+	if err := s.findAll(ctx, "bookshelves", bson.M{"deviceId": deviceID}, &shelves); err != nil {
+		return err
+	}
+	if len(shelves) == 0 {
+		return nil
+	}
+	if err := s.ensureBookshelves(ctx, userID); err != nil {
+		return err
+	}
+
+	items := s.db.GetCollection("shelf_items")
+	for _, shelf := range shelves {
+		if shelf.Kind == "" {
+			_, err := s.db.GetCollection("bookshelves").UpdateOne(ctx, bson.M{"_id": shelf.ID}, bson.M{"$set": bson.M{"deviceId": userID}})
+			if err != nil {
+				return err
+			}
+			if _, err := items.UpdateMany(ctx, bson.M{"shelfId": shelf.ID}, bson.M{"$set": bson.M{"deviceId": userID}}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var target models.Bookshelf
+		err := s.db.GetCollection("bookshelves").FindOne(ctx, bson.M{"deviceId": userID, "kind": shelf.Kind}).Decode(&target)
+		if err != nil {
+			return err
+		}
+		var entries []models.ShelfEntry
+		if err := s.findAll(ctx, "shelf_items", bson.M{"shelfId": shelf.ID}, &entries); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if _, err := s.insertShelfEntry(ctx, &target, entry.NovelID, entry.Note); err != nil && !mongo.IsDuplicateKeyError(err) {
+				return err
+			}
+		}
+		if _, err := items.DeleteMany(ctx, bson.M{"shelfId": shelf.ID}); err != nil {
+			return err
+		}
+		if _, err := s.db.GetCollection("bookshelves").DeleteOne(ctx, bson.M{"_id": shelf.ID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getBookshelf 获取自己的书单
+func (s *NovelService) getBookshelf(ctx context.Context, deviceID, shelfID string) (*models.Bookshelf, error) {
+	id, err := primitive.ObjectIDFromHex(shelfID)
+	if err != nil {
+		return nil, errors.NewError(errors.ErrInvalidParameter)
+	}
+
+	var shelf models.Bookshelf
+	err = s.db.GetCollection("bookshelves").FindOne(ctx, bson.M{"_id": id, "deviceId": deviceID}).Decode(&shelf)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.NewError(errors.ErrNotFound)
+		}
+		return nil, err
+	}
+	return &shelf, nil
+}
+
+// bookshelfDetail 加载书单中的小说，已删除的小说不返回
+func (s *NovelService) bookshelfDetail(ctx context.Context, shelf *models.Bookshelf) (*models.BookshelfDetail, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "position", Value: 1}, {Key: "addedAt", Value: 1}})
+	cursor, err := s.db.GetCollection("shelf_items").Find(ctx, bson.M{"shelfId": shelf.ID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []models.ShelfEntry
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.NovelID)
+	}
+	novels, err := s.GetNovelsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &models.BookshelfDetail{Bookshelf: *shelf, Entries: make([]models.ShelfEntry, 0, len(entries))}
+	for _, entry := range entries {
+		if novel, ok := novels[entry.NovelID]; ok {
+			entry.Novel = novel
+			detail.Entries = append(detail.Entries, entry)
+		}
+	}
+	detail.ItemCount = int64(len(detail.Entries))
+	return detail, nil
+}
+
+// insertShelfEntry 将小说加到书单末尾
+func (s *NovelService) insertShelfEntry(ctx context.Context, shelf *models.Bookshelf, novelID, note string) (*models.ShelfEntry, error) {
+	position, err := s.nextNumber(ctx, "shelf_items", bson.M{"shelfId": shelf.ID}, "position")
+	if err != nil {
+		return nil, err
+	}
+
+	entry := models.ShelfEntry{
+		ID:       primitive.NewObjectID(),
+		ShelfID:  shelf.ID,
+		DeviceID: shelf.DeviceID,
+		NovelID:  novelID,
+		Note:     note,
+		Position: position,
+		AddedAt:  time.Now(),
+	}
+	if _, err := s.db.GetCollection("shelf_items").InsertOne(ctx, entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// leaveStatusShelves 将小说移出除keep以外的阅读状态书单
+func (s *NovelService) leaveStatusShelves(ctx context.Context, deviceID, novelID, keep string) error {
+	kinds := make([]string, 0, len(statusShelves))
+	for _, kind := range statusShelves {
+		if kind != keep {
+			kinds = append(kinds, kind)
+		}
+	}
+
+	var shelves []models.Bookshelf
+	if err := s.findAll(ctx, "bookshelves", bson.M{"deviceId": deviceID, "kind": bson.M{"$in": kinds}}, &shelves); err != nil {
+		return err
+	}
+	ids := make([]primitive.ObjectID, 0, len(shelves))
+	for _, shelf := range shelves {
+		ids = append(ids, shelf.ID)
+	}
+
+	_, err := s.db.GetCollection("shelf_items").DeleteMany(ctx, bson.M{"shelfId": bson.M{"$in": ids}, "novelId": novelID})
+	return err
+}
+
+// newShareCode 生成书单的分享编码
+func newShareCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,242 @@
+// ****************************************************************************
+//
+// @file       chapter_search_service.go
+// @brief      小说内章节正文搜索，使用二元组(bigram)索引预筛选后逐章精确匹配
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"lightnovel/internal/models"
+	"lightnovel/pkg/cache"
+	"lightnovel/pkg/errors"
+	"lightnovel/pkg/textindex"
+)
+
+const (
+	maxContentMatches = 20 // 每章最多返回的命中数
+	chapterBatchSize  = 50 // 精确匹配时每批读取的章节数
+)
+
+// chapterRef 小说内章节的定位
+type chapterRef struct {
+	volume  int
+	chapter int
+}
+
+// contentSearchPage 正文搜索结果缓存
+type contentSearchPage struct {
+	Items []models.ChapterSearchResult `json:"items"`
+	Total int64                        `json:"total"`
+}
+
+// SearchChapterContent 在小说的章节正文中搜索，返回命中章节、字符偏移和上下文
+func (s *NovelService) SearchChapterContent(ctx context.Context, novelID, query string, page, size int) ([]models.ChapterSearchResult, int64, error) {
+	query = strings.TrimSpace(query)
+	if query == "" || utf8.RuneCountInString(query) > maxSearchKeywordLen {
+		return nil, 0, errors.NewError(errors.ErrInvalidParameter)
+	}
+	if _, err := s.GetNovelByID(ctx, novelID); err != nil {
+		return nil, 0, err
+	}
+
+	// 尝试从缓存获取
+	cacheKey := fmt.Sprintf("%s%s:%s:%d:%d", cache.ContentSearchKey, novelID, strings.ToLower(query), page, size)
+	var cached contentSearchPage
+	if err := s.cache.Get(ctx, cacheKey, &cached); err == nil && cached.Items != nil {
+		return cached.Items, cached.Total, nil
+	}
+
+	// 用n-gram索引筛选候选章节，查询过短时退化为全部章节
+	filter := bson.M{"novelId": novelID}
+	if grams := textindex.Grams(query); len(grams) > 0 {
+		filter["grams"] = bson.M{"$all": grams}
+	}
+	opts := options.Find().
+		SetProjection(bson.M{"volumeNumber": 1, "chapterNumber": 1}).
+		SetSort(bson.D{{Key: "volumeNumber", Value: 1}, {Key: "chapterNumber", Value: 1}})
+	cursor, err := s.db.GetCollection("chapter_index").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	var candidates []models.ChapterIndex
+	if err = cursor.All(ctx, &candidates); err != nil {
+		return nil, 0, err
+	}
+
+	// 分批读取候选章节正文并精确匹配
+	results := []models.ChapterSearchResult{}
+	for start := 0; start < len(candidates); start += chapterBatchSize {
+		batch := candidates[start:min(start+chapterBatchSize, len(candidates))]
+		or := make([]bson.M, len(batch))
+		for i, c := range batch {
+			or[i] = bson.M{"volumeNumber": c.VolumeNumber, "chapterNumber": c.ChapterNumber}
+		}
+
+		cursor, err := s.db.GetCollection("chapters").Find(ctx,
+			bson.M{"novelId": novelID, "$or": or},
+			options.Find().SetProjection(bson.M{"volumeNumber": 1, "chapterNumber": 1, "title": 1, "content": 1}),
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		var chapters []models.Chapter
+		if err = cursor.All(ctx, &chapters); err != nil {
+			return nil, 0, err
+		}
+
+		for _, chapter := range chapters {
+			if result, ok := matchChapter(&chapter, query); ok {
+				results = append(results, result)
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].VolumeNumber != results[j].VolumeNumber {
+			return results[i].VolumeNumber < results[j].VolumeNumber
+		}
+		return results[i].ChapterNumber < results[j].ChapterNumber
+	})
+
+	// 分页
+	total := int64(len(results))
+	from := min((page-1)*size, len(results))
+	to := min(from+size, len(results))
+	items := results[from:to]
+
+	// 设置缓存
+	s.cache.Set(ctx, cacheKey, contentSearchPage{Items: items, Total: total}, s.cfg.Cache.SearchResult)
+
+	return items, total, nil
+}
+
+// BackfillChapterIndex 为所有小说补建缺失或过期的章节索引，索引在章节写入时更新，这里只处理写入时未建索引的旧数据
+func (s *NovelService) BackfillChapterIndex(ctx context.Context) {
+	ids, err := s.db.GetCollection("chapters").Distinct(ctx, "novelId", bson.M{})
+	if err != nil {
+		log.Printf("Failed to list novels for chapter index: %v", err)
+		return
+	}
+	for _, id := range ids {
+		novelID, ok := id.(string)
+		if !ok {
+			continue
+		}
+		if err := s.syncChapterIndex(ctx, novelID); err != nil {
+			log.Printf("Failed to backfill chapter index of novel %s: %v", novelID, err)
+		}
+	}
+}
+
+// syncChapterIndex 为新增或更新过的章节重建索引，并删除已不存在章节的索引
+func (s *NovelService) syncChapterIndex(ctx context.Context, novelID string) error {
+	projection := bson.M{"volumeNumber": 1, "chapterNumber": 1, "updatedAt": 1}
+	cursor, err := s.db.GetCollection("chapters").Find(ctx, bson.M{"novelId": novelID}, options.Find().SetProjection(projection))
+	if err != nil {
+		return err
+	}
+	var chapters []models.Chapter
+	if err = cursor.All(ctx, &chapters); err != nil {
+		return err
+	}
+
+	indexCollection := s.db.GetCollection("chapter_index")
+	cursor, err = indexCollection.Find(ctx, bson.M{"novelId": novelID},
+		options.Find().SetProjection(bson.M{"volumeNumber": 1, "chapterNumber": 1, "sourceUpdatedAt": 1}))
+	if err != nil {
+		return err
+	}
+	var indexes []models.ChapterIndex
+	if err = cursor.All(ctx, &indexes); err != nil {
+		return err
+	}
+
+	indexed := make(map[chapterRef]time.Time, len(indexes))
+	for _, idx := range indexes {
+		indexed[chapterRef{idx.VolumeNumber, idx.ChapterNumber}] = idx.SourceUpdatedAt
+	}
+
+	for _, chapter := range chapters {
+		ref := chapterRef{chapter.VolumeNumber, chapter.ChapterNumber}
+		updatedAt, ok := indexed[ref]
+		delete(indexed, ref)
+		if ok && updatedAt.Equal(chapter.UpdatedAt) {
+			continue
+		}
+		if err := s.indexChapter(ctx, novelID, ref); err != nil {
+			return err
+		}
+	}
+
+	// 剩余的索引对应的章节已被删除
+	for ref := range indexed {
+		_, err := indexCollection.DeleteOne(ctx, bson.M{
+			"novelId":       novelID,
+			"volumeNumber":  ref.volume,
+			"chapterNumber": ref.chapter,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexChapter 读取章节正文并写入n-gram索引
+func (s *NovelService) indexChapter(ctx context.Context, novelID string, ref chapterRef) error {
+	key := bson.M{"novelId": novelID, "volumeNumber": ref.volume, "chapterNumber": ref.chapter}
+
+	var chapter models.Chapter
+	err := s.db.GetCollection("chapters").FindOne(ctx, key,
+		options.FindOne().SetProjection(bson.M{"content": 1, "updatedAt": 1})).Decode(&chapter)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		return err
+	}
+	return textindex.Update(ctx, s.db.GetCollection("chapter_index"), novelID, ref.volume, ref.chapter, chapter.Content, chapter.UpdatedAt)
+}
+
+// matchChapter 在章节正文中查找所有命中并生成上下文
+func matchChapter(chapter *models.Chapter, query string) (models.ChapterSearchResult, bool) {
+	src := []rune(chapter.Content)
+	spans := matchSpans(src, []string{query})
+	if len(spans) == 0 {
+		return models.ChapterSearchResult{}, false
+	}
+
+	result := models.ChapterSearchResult{
+		VolumeNumber:  chapter.VolumeNumber,
+		ChapterNumber: chapter.ChapterNumber,
+		Title:         chapter.Title,
+		MatchCount:    len(spans),
+	}
+	for _, sp := range spans[:min(len(spans), maxContentMatches)] {
+		start := max(0, sp[0]-snippetRadius)
+		end := min(len(src), sp[1]+snippetRadius)
+		result.Matches = append(result.Matches, models.ContentMatch{
+			Position: sp[0],
+			Length:   sp[1] - sp[0],
+			Context:  renderSnippet(src, spans, start, end),
+		})
+	}
+	return result, true
+}
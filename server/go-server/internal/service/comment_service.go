@@ -0,0 +1,349 @@
+// ****************************************************************************
+//
+// @file       comment_service.go
+// @brief      评论回复、点赞、排序与置顶
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"lightnovel/internal/models"
+	"lightnovel/pkg/cache"
+	"lightnovel/pkg/errors"
+	"lightnovel/pkg/websocket"
+)
+
+// 评论排序方式
+const (
+	CommentSortHot = "hot" // 按点赞数、回复数和发布时间综合排序
+	CommentSortNew = "new" // 按发布时间倒序
+	CommentSortTop = "top" // 按点赞数倒序
+)
+
+// commentSorts 各排序方式对应的聚合阶段，置顶评论始终排在最前
+var commentSorts = map[string][]bson.D{
+	CommentSortNew: {
+		{{Key: "$sort", Value: bson.D{{Key: "pinned", Value: -1}, {Key: "createdAt", Value: -1}}}},
+	},
+	CommentSortTop: {
+		{{Key: "$sort", Value: bson.D{{Key: "pinned", Value: -1}, {Key: "likeCount", Value: -1}, {Key: "createdAt", Value: -1}}}},
+	},
+	// 热度 = (点赞数 + 2*回复数 + 1) / (发布小时数 + 2)^1.5
+	CommentSortHot: {
+		{{Key: "$addFields", Value: bson.M{
+			"hot": bson.M{"$divide": bson.A{
+				bson.M{"$add": bson.A{
+					bson.M{"$ifNull": bson.A{"$likeCount", 0}},
+					bson.M{"$multiply": bson.A{bson.M{"$ifNull": bson.A{"$replyCount", 0}}, 2}},
+					1,
+				}},
+				bson.M{"$pow": bson.A{
+					bson.M{"$add": bson.A{
+						bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{"$$NOW", "$createdAt"}}, 3600000}},
+						2,
+					}},
+					1.5,
+				}},
+			}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "pinned", Value: -1}, {Key: "hot", Value: -1}, {Key: "createdAt", Value: -1}}}},
+	},
+}
+
+// GetCommentReplies 分页获取顶层评论下的回复，按发布时间正序
+func (s *NovelService) GetCommentReplies(ctx context.Context, deviceID, commentID string, page, size int) ([]models.CommentResponse, int64, error) {
+	cacheKey := fmt.Sprintf("%sreplies:%s:%d:%d", cache.CommentListKey, commentID, page, size)
+	totalKey := fmt.Sprintf("%sreplies:%s:total", cache.CommentListKey, commentID)
+
+	var replies []models.CommentResponse
+	err := s.cache.Get(ctx, cacheKey, &replies)
+	if err == nil && len(replies) > 0 {
+		var total int64
+		s.cache.Get(ctx, totalKey, &total)
+		return replies, total, s.fillCommentLiked(ctx, deviceID, replies)
+	}
+
+	parent, err := s.getComment(ctx, commentID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if parent.ParentID != "" {
+		return nil, 0, errors.NewErrorWithMessage(errors.ErrInvalidParameter, "只能获取顶层评论的回复")
+	}
+
+	collection := s.db.GetCollection("comments")
+	filter := bson.M{"parentId": commentID, "status": visibleComment}
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetSkip(int64((page - 1) * size)).
+		SetLimit(int64(size))
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var comments []models.Comment
+	if err = cursor.All(ctx, &comments); err != nil {
+		return nil, 0, err
+	}
+
+	replies = s.toCommentResponses(ctx, comments)
+
+	s.cache.Set(ctx, cacheKey, replies, s.cfg.Cache.Comment)
+	s.cache.Set(ctx, totalKey, total, s.cfg.Cache.Comment)
+
+	return replies, total, s.fillCommentLiked(ctx, deviceID, replies)
+}
+
+// LikeComment 点赞评论，重复点赞不会重复计数
+func (s *NovelService) LikeComment(ctx context.Context, deviceID, commentID string) (*models.CommentLikeResponse, error) {
+	comment, err := s.getComment(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.GetCollection("comment_likes").InsertOne(ctx, models.CommentLike{
+		ID:        primitive.NewObjectID(),
+		CommentID: comment.ID,
+		NovelID:   comment.NovelID,
+		DeviceID:  deviceID,
+		CreatedAt: time.Now(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return &models.CommentLikeResponse{Liked: true, LikeCount: comment.LikeCount}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.incLikeCount(ctx, comment, 1)
+}
+
+// UnlikeComment 取消点赞
+func (s *NovelService) UnlikeComment(ctx context.Context, deviceID, commentID string) (*models.CommentLikeResponse, error) {
+	comment, err := s.getComment(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.GetCollection("comment_likes").DeleteOne(ctx, bson.M{
+		"commentId": comment.ID,
+		"deviceId":  deviceID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.DeletedCount == 0 {
+		return &models.CommentLikeResponse{Liked: false, LikeCount: comment.LikeCount}, nil
+	}
+
+	return s.incLikeCount(ctx, comment, -1)
+}
+
+// PinComment 置顶或取消置顶顶层评论
+func (s *NovelService) PinComment(ctx context.Context, commentID string, pinned bool) (*models.Comment, error) {
+	comment, err := s.getComment(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.ParentID != "" {
+		return nil, errors.NewErrorWithMessage(errors.ErrInvalidParameter, "只能置顶顶层评论")
+	}
+
+	_, err = s.db.GetCollection("comments").UpdateOne(ctx,
+		bson.M{"_id": comment.ID},
+		bson.M{"$set": bson.M{"pinned": pinned}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	comment.Pinned = pinned
+
+	s.invalidateCommentCache(ctx, comment)
+	return comment, nil
+}
+
+// incLikeCount 更新评论点赞数并返回最新结果
+func (s *NovelService) incLikeCount(ctx context.Context, comment *models.Comment, delta int) (*models.CommentLikeResponse, error) {
+	var updated models.Comment
+	err := s.db.GetCollection("comments").FindOneAndUpdate(ctx,
+		bson.M{"_id": comment.ID},
+		bson.M{"$inc": bson.M{"likeCount": delta}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.NewError(errors.ErrNotFound)
+		}
+		return nil, err
+	}
+
+	s.invalidateCommentCache(ctx, comment)
+	if updated.Status == "" {
+		s.publishCommentEvent(&updated, websocket.CommentCounts{
+			CommentID:  updated.ID.Hex(),
+			ReplyCount: updated.ReplyCount,
+			LikeCount:  updated.LikeCount,
+		})
+	}
+	return &models.CommentLikeResponse{Liked: delta > 0, LikeCount: updated.LikeCount}, nil
+}
+
+// getComment 根据ID获取评论
+func (s *NovelService) getComment(ctx context.Context, commentID string) (*models.Comment, error) {
+	id, err := primitive.ObjectIDFromHex(commentID)
+	if err != nil {
+		return nil, errors.NewError(errors.ErrInvalidParameter)
+	}
+
+	var comment models.Comment
+	err = s.db.GetCollection("comments").FindOne(ctx, bson.M{"_id": id}).Decode(&comment)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.NewError(errors.ErrNotFound)
+		}
+		return nil, err
+	}
+
+	return &comment, nil
+}
+
+// toCommentResponses 补充评论者的昵称和头像
+func (s *NovelService) toCommentResponses(ctx context.Context, comments []models.Comment) []models.CommentResponse {
+	userCollection := s.db.GetCollection("users")
+	users := make(map[string]models.User)
+	responses := make([]models.CommentResponse, 0, len(comments))
+
+	for _, comment := range comments {
+		user, ok := users[comment.DeviceID]
+		if !ok {
+			err := userCollection.FindOne(ctx, bson.M{"_id": comment.DeviceID}).Decode(&user)
+			if err != nil {
+				user.Name = "已删除用户"
+				user.Avatar = "/static/avatars/default.png"
+			}
+			users[comment.DeviceID] = user
+		}
+
+		responses = append(responses, models.CommentResponse{
+			ID:            comment.ID,
+			UserID:        comment.DeviceID,
+			UserName:      user.Name,
+			UserAvatar:    user.Avatar,
+			NovelID:       comment.NovelID,
+			VolumeNumber:  comment.VolumeNumber,
+			ChapterNumber: comment.ChapterNumber,
+			ParentID:      comment.ParentID,
+			ReplyTo:       comment.ReplyTo,
+			Anchor:        comment.Anchor,
+			Content:       comment.Content,
+			ReplyCount:    comment.ReplyCount,
+			LikeCount:     comment.LikeCount,
+			Pinned:        comment.Pinned,
+			CreatedAt:     comment.CreatedAt,
+		})
+	}
+
+	return responses
+}
+
+// fillCommentLiked 标记当前用户已点赞的评论，点赞状态因人而异不进入缓存
+func (s *NovelService) fillCommentLiked(ctx context.Context, deviceID string, comments []models.CommentResponse) error {
+	if deviceID == "" || len(comments) == 0 {
+		return nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(comments))
+	for _, comment := range comments {
+		ids = append(ids, comment.ID)
+	}
+
+	var likes []models.CommentLike
+	err := s.findAll(ctx, "comment_likes", bson.M{"deviceId": deviceID, "commentId": bson.M{"$in": ids}}, &likes)
+	if err != nil {
+		return err
+	}
+
+	liked := make(map[primitive.ObjectID]bool, len(likes))
+	for _, like := range likes {
+		liked[like.CommentID] = true
+	}
+	for i := range comments {
+		comments[i].Liked = liked[comments[i].ID]
+	}
+
+	return nil
+}
+
+// invalidateCommentCache 清除评论所在章节和所属回复列表的缓存
+func (s *NovelService) invalidateCommentCache(ctx context.Context, comment *models.Comment) {
+	pattern := fmt.Sprintf("%s%s:%d:%d:*", cache.CommentListKey, comment.NovelID, comment.VolumeNumber, comment.ChapterNumber)
+	s.cache.DeleteByPattern(ctx, pattern)
+
+	rootID := comment.ParentID
+	if rootID == "" {
+		rootID = comment.ID.Hex()
+	}
+	s.cache.DeleteByPattern(ctx, fmt.Sprintf("%sreplies:%s:*", cache.CommentListKey, rootID))
+}
+
+// publishCommentCreated 向章节主题推送新的公开评论
+func (s *NovelService) publishCommentCreated(ctx context.Context, comment *models.Comment) {
+	event := websocket.CommentCreated{Comment: s.toCommentResponses(ctx, []models.Comment{*comment})[0]}
+	if comment.ParentID != "" {
+		event.Root = s.commentCounts(ctx, comment.ParentID)
+	}
+	s.publishCommentEvent(comment, event)
+}
+
+// publishCommentDeleted 向章节主题推送评论被删除或不再公开
+func (s *NovelService) publishCommentDeleted(ctx context.Context, comment *models.Comment) {
+	event := websocket.CommentDeleted{CommentID: comment.ID.Hex(), ParentID: comment.ParentID}
+	if comment.ParentID != "" {
+		event.Root = s.commentCounts(ctx, comment.ParentID)
+	}
+	s.publishCommentEvent(comment, event)
+}
+
+// publishCommentEvent 向评论所在章节的主题推送事件
+func (s *NovelService) publishCommentEvent(comment *models.Comment, event websocket.Event) {
+	topic := websocket.ChapterTopic(comment.NovelID, comment.VolumeNumber, comment.ChapterNumber)
+	if !s.publisher.PublishToTopic(topic, websocket.NewMessage("", event)) {
+		log.Printf("Dropped %s event for %s", event.EventType(), topic)
+	}
+}
+
+// commentCounts 获取评论最新的回复数和点赞数，评论不存在时返回nil
+func (s *NovelService) commentCounts(ctx context.Context, commentID string) *websocket.CommentCounts {
+	comment, err := s.getComment(ctx, commentID)
+	if err != nil {
+		return nil
+	}
+	return &websocket.CommentCounts{
+		CommentID:  commentID,
+		ReplyCount: comment.ReplyCount,
+		LikeCount:  comment.LikeCount,
+	}
+}
@@ -0,0 +1,357 @@
+// ****************************************************************************
+//
+// @file       moderation_service.go
+// @brief      评论审核：敏感词过滤、举报、审核队列与设备禁言封禁
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"lightnovel/config"
+	"lightnovel/internal/models"
+	"lightnovel/pkg/cache"
+	"lightnovel/pkg/errors"
+	"lightnovel/pkg/filter"
+)
+
+// 评论状态，公开的评论不设置状态
+const (
+	CommentStatusHidden  = "hidden"  // 已被管理员隐藏
+	CommentStatusPending = "pending" // 待审核，不公开展示
+)
+
+// 命中敏感词时的处理方式
+const (
+	ModerateMask   = "mask"
+	ModerateReject = "reject"
+	ModerateReview = "review"
+)
+
+// visibleComment 公开评论的查询条件
+var visibleComment = bson.M{"$nin": bson.A{CommentStatusHidden, CommentStatusPending}}
+
+// newWordFilter 根据配置加载敏感词
+func newWordFilter(cfg *config.Config) *filter.Filter {
+	words := cfg.Moderate.Words
+	if cfg.Moderate.WordsFile != "" {
+		fileWords, err := filter.LoadWords(cfg.Moderate.WordsFile)
+		if err != nil {
+			log.Printf("Failed to load sensitive words from %s: %v", cfg.Moderate.WordsFile, err)
+		} else {
+			words = append(append([]string{}, words...), fileWords...)
+		}
+	}
+	return filter.New(words)
+}
+
+// moderateContent 按配置处理评论中的敏感词，返回处理后的内容和评论状态
+func (s *NovelService) moderateContent(content string) (string, string, error) {
+	if !s.words.Contains(content) {
+		return content, "", nil
+	}
+
+	switch s.cfg.Moderate.Action {
+	case ModerateReject:
+		return "", "", errors.NewError(errors.ErrSensitiveContent)
+	case ModerateReview:
+		return content, CommentStatusPending, nil
+	default:
+		return s.words.Replace(content, '*'), "", nil
+	}
+}
+
+// ReportComment 举报评论，举报数达到阈值时自动隐藏并进入审核队列
+func (s *NovelService) ReportComment(ctx context.Context, deviceID, commentID, reason string) error {
+	comment, err := s.getComment(ctx, commentID)
+	if err != nil {
+		return err
+	}
+	if comment.DeviceID == deviceID {
+		return errors.NewErrorWithMessage(errors.ErrInvalidParameter, "不能举报自己的评论")
+	}
+
+	_, err = s.db.GetCollection("reports").InsertOne(ctx, models.CommentReport{
+		ID:        primitive.NewObjectID(),
+		CommentID: comment.ID,
+		NovelID:   comment.NovelID,
+		DeviceID:  deviceID,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return errors.NewErrorWithMessage(errors.ErrAlreadyExists, "已举报过该评论")
+	}
+	if err != nil {
+		return err
+	}
+
+	var updated models.Comment
+	err = s.db.GetCollection("comments").FindOneAndUpdate(ctx,
+		bson.M{"_id": comment.ID},
+		bson.M{"$inc": bson.M{"reportCount": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		return err
+	}
+
+	if updated.Status == "" && updated.ReportCount >= s.cfg.Moderate.ReportThreshold {
+		log.Printf("Comment %s reached %d reports, hidden for review", commentID, updated.ReportCount)
+		return s.setCommentStatus(ctx, &updated, CommentStatusPending)
+	}
+	return nil
+}
+
+// GetModerationQueue 获取审核队列，status为pending时返回待审核和有未处理举报的评论，为hidden时返回已隐藏的评论
+func (s *NovelService) GetModerationQueue(ctx context.Context, status string, page, size int) ([]models.ModerationItem, int64, error) {
+	query := bson.M{"status": CommentStatusHidden}
+	if status != CommentStatusHidden {
+		query = bson.M{"$or": bson.A{
+			bson.M{"status": CommentStatusPending},
+			bson.M{"status": bson.M{"$exists": false}, "reportCount": bson.M{"$gt": 0}},
+		}}
+	}
+
+	collection := s.db.GetCollection("comments")
+	total, err := collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "reportCount", Value: -1}, {Key: "createdAt", Value: 1}}).
+		SetSkip(int64((page - 1) * size)).
+		SetLimit(int64(size))
+
+	cursor, err := collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var comments []models.Comment
+	if err = cursor.All(ctx, &comments); err != nil {
+		return nil, 0, err
+	}
+
+	items := make([]models.ModerationItem, 0, len(comments))
+	for _, comment := range comments {
+		reports := []models.CommentReport{}
+		err := s.findAll(ctx, "reports", bson.M{"commentId": comment.ID, "resolved": false}, &reports)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, models.ModerationItem{Comment: comment, Reports: reports})
+	}
+
+	return items, total, nil
+}
+
+// HideComment 隐藏评论并处理其举报
+func (s *NovelService) HideComment(ctx context.Context, commentID string) error {
+	comment, err := s.getComment(ctx, commentID)
+	if err != nil {
+		return err
+	}
+	if err := s.resolveReports(ctx, comment.ID); err != nil {
+		return err
+	}
+	return s.setCommentStatus(ctx, comment, CommentStatusHidden)
+}
+
+// RestoreComment 恢复评论公开展示并处理其举报
+func (s *NovelService) RestoreComment(ctx context.Context, commentID string) error {
+	comment, err := s.getComment(ctx, commentID)
+	if err != nil {
+		return err
+	}
+	if err := s.resolveReports(ctx, comment.ID); err != nil {
+		return err
+	}
+	return s.setCommentStatus(ctx, comment, "")
+}
+
+// RemoveComment 管理员删除评论，不校验作者
+func (s *NovelService) RemoveComment(ctx context.Context, commentID string) error {
+	comment, err := s.getComment(ctx, commentID)
+	if err != nil {
+		return err
+	}
+	if err := s.removeComment(ctx, comment); err != nil {
+		return err
+	}
+
+	_, err = s.db.GetCollection("reports").DeleteMany(ctx, bson.M{"commentId": comment.ID})
+	return err
+}
+
+// SetRestriction 设置禁言和封禁，mute为0时解除禁言
+//
+// id可以是设备ID，也可以是账号ID：登录用户发表的评论以账号ID为作者，管理员按评论作者处理时传入的是账号ID。
+// 账号的设置同时写入账号及其绑定的所有设备
+func (s *NovelService) SetRestriction(ctx context.Context, id string, mute time.Duration, banned bool, reason string) (*models.Restriction, error) {
+	result := &models.Restriction{ID: id, Banned: banned}
+	set := bson.M{}
+	unset := bson.M{}
+	if mute > 0 {
+		mutedUntil := time.Now().Add(mute)
+		set["mutedUntil"] = mutedUntil
+		result.MutedUntil = &mutedUntil
+	} else {
+		unset["mutedUntil"] = ""
+	}
+	if banned {
+		set["banned"] = true
+		set["banReason"] = reason
+		result.BanReason = reason
+	} else {
+		unset["banned"] = ""
+		unset["banReason"] = ""
+	}
+
+	update := bson.M{}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+
+	devices := s.db.GetCollection("devices")
+	deviceResult, err := devices.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return nil, err
+	}
+	if deviceResult.MatchedCount > 0 {
+		result.Devices = 1
+		log.Printf("Updated restriction of device %s: mute=%v banned=%v", id, mute, banned)
+		return result, nil
+	}
+
+	// 不是设备ID时按账号处理
+	userResult, err := s.db.GetCollection("users").UpdateOne(ctx, bson.M{"_id": id, "username": bson.M{"$exists": true}}, update)
+	if err != nil {
+		return nil, err
+	}
+	if userResult.MatchedCount == 0 {
+		return nil, errors.NewError(errors.ErrNotFound)
+	}
+	s.cache.Delete(ctx, cache.UserKey+id)
+
+	deviceResult, err = devices.UpdateMany(ctx, bson.M{"userId": id}, update)
+	if err != nil {
+		return nil, err
+	}
+	result.Account = true
+	result.Devices = deviceResult.MatchedCount
+
+	log.Printf("Updated restriction of account %s and %d devices: mute=%v banned=%v", id, result.Devices, mute, banned)
+	return result, nil
+}
+
+// inheritRestriction 新设备继承同一IP和UA下近期受限设备的限制，避免匿名用户清除设备ID绕过禁言
+//
+// 共享IP(校园网、运营商NAT)下的其他用户不应被连坐，因此默认关闭，开启后也只继承为不超过
+// InheritWindow 的禁言，封禁本身仍只作用于原设备和账号
+func (s *NovelService) inheritRestriction(ctx context.Context, device *models.Device) error {
+	window := s.cfg.Moderate.InheritWindow
+	if window <= 0 || device.IP == "" {
+		return nil
+	}
+
+	now := time.Now()
+	var restricted models.Device
+	err := s.db.GetCollection("devices").FindOne(ctx, bson.M{
+		"ip":        device.IP,
+		"userAgent": device.UserAgent,
+		"lastSeen":  bson.M{"$gte": now.Add(-window)},
+		"$or": bson.A{
+			bson.M{"banned": true},
+			bson.M{"mutedUntil": bson.M{"$gt": now}},
+		},
+	}).Decode(&restricted)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	mutedUntil := now.Add(window)
+	if !restricted.Banned && restricted.MutedUntil.Before(mutedUntil) {
+		mutedUntil = *restricted.MutedUntil
+	}
+	device.MutedUntil = &mutedUntil
+	return nil
+}
+
+// setCommentStatus 修改评论状态，回复的公开与否会同步到顶层评论的回复数
+func (s *NovelService) setCommentStatus(ctx context.Context, comment *models.Comment, status string) error {
+	update := bson.M{"$set": bson.M{"status": status}}
+	if status == "" {
+		update = bson.M{"$unset": bson.M{"status": ""}}
+	}
+
+	_, err := s.db.GetCollection("comments").UpdateOne(ctx, bson.M{"_id": comment.ID}, update)
+	if err != nil {
+		return err
+	}
+
+	wasVisible, isVisible := comment.Status == "", status == ""
+	if comment.ParentID != "" && wasVisible != isVisible {
+		delta := 1
+		if wasVisible {
+			delta = -1
+		}
+		rootID, _ := primitive.ObjectIDFromHex(comment.ParentID)
+		_, err = s.db.GetCollection("comments").UpdateOne(ctx,
+			bson.M{"_id": rootID},
+			bson.M{"$inc": bson.M{"replyCount": delta}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	comment.Status = status
+	s.invalidateCommentCache(ctx, comment)
+
+	// 订阅章节的客户端同步评论的公开状态
+	switch {
+	case wasVisible && !isVisible:
+		s.publishCommentDeleted(ctx, comment)
+	case !wasVisible && isVisible:
+		s.publishCommentCreated(ctx, comment)
+	}
+	return nil
+}
+
+// resolveReports 将评论的举报标记为已处理
+func (s *NovelService) resolveReports(ctx context.Context, commentID primitive.ObjectID) error {
+	_, err := s.db.GetCollection("reports").UpdateMany(ctx,
+		bson.M{"commentId": commentID, "resolved": false},
+		bson.M{"$set": bson.M{"resolved": true}},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.GetCollection("comments").UpdateOne(ctx,
+		bson.M{"_id": commentID},
+		bson.M{"$set": bson.M{"reportCount": 0}},
+	)
+	return err
+}
@@ -0,0 +1,556 @@
+// ****************************************************************************
+//
+// @file       notification_service.go
+// @brief      关注小说、通知偏好、站内通知与按设备定向推送
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"lightnovel/internal/models"
+	"lightnovel/pkg/errors"
+	"lightnovel/pkg/websocket"
+)
+
+// 小说更新类型
+const (
+	UpdateNewChapter    = "new_chapter"
+	UpdateNewVolume     = "new_volume"
+	UpdateContentChange = "content_update"
+)
+
+const (
+	notifyTimeout      = 10 * time.Second // 查找通知对象或保存一批通知的超时时间
+	maxPendingDelivery = 100              // 重新连接时最多补发的通知数，更早的可在通知列表中查看
+	noticeBatchSize    = 500              // 系统通知和更新通知每批保存和推送的用户数
+)
+
+// GetFollows 获取关注的小说，按关注时间倒序，已删除的小说不返回
+func (s *NovelService) GetFollows(ctx context.Context, deviceID string) ([]models.Follow, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := s.db.GetCollection("follows").Find(ctx, bson.M{"deviceId": deviceID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var follows []models.Follow
+	if err = cursor.All(ctx, &follows); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(follows))
+	for _, follow := range follows {
+		ids = append(ids, follow.NovelID)
+	}
+	novels, err := s.GetNovelsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.Follow, 0, len(follows))
+	for _, follow := range follows {
+		if novel, ok := novels[follow.NovelID]; ok {
+			follow.Novel = novel
+			result = append(result, follow)
+		}
+	}
+	return result, nil
+}
+
+// FollowNovel 关注小说
+func (s *NovelService) FollowNovel(ctx context.Context, deviceID, novelID string) error {
+	if _, err := s.GetNovelByID(ctx, novelID); err != nil {
+		return err
+	}
+
+	_, err := s.db.GetCollection("follows").InsertOne(ctx, models.Follow{
+		ID:        primitive.NewObjectID(),
+		DeviceID:  deviceID,
+		NovelID:   novelID,
+		CreatedAt: time.Now(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return errors.NewError(errors.ErrAlreadyExists)
+	}
+	return err
+}
+
+// UnfollowNovel 取消关注
+func (s *NovelService) UnfollowNovel(ctx context.Context, deviceID, novelID string) error {
+	result, err := s.db.GetCollection("follows").DeleteOne(ctx, bson.M{"deviceId": deviceID, "novelId": novelID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.NewError(errors.ErrNotFound)
+	}
+	return nil
+}
+
+// GetNotificationPrefs 获取通知偏好
+func (s *NovelService) GetNotificationPrefs(ctx context.Context, deviceID string) (*models.NotificationPrefs, error) {
+	prefs := models.NotificationPrefs{DeviceID: deviceID, NewChapter: true, NewVolume: true, CommentReply: true}
+	err := s.db.GetCollection("notify_prefs").FindOne(ctx, bson.M{"_id": deviceID}).Decode(&prefs)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+// UpdateNotificationPrefs 修改通知偏好，为nil的项不修改
+func (s *NovelService) UpdateNotificationPrefs(ctx context.Context, deviceID string, newChapter, newVolume, contentUpdate, commentReply *bool) (*models.NotificationPrefs, error) {
+	prefs, err := s.GetNotificationPrefs(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if newChapter != nil {
+		prefs.NewChapter = *newChapter
+	}
+	if newVolume != nil {
+		prefs.NewVolume = *newVolume
+	}
+	if contentUpdate != nil {
+		prefs.ContentUpdate = *contentUpdate
+	}
+	if commentReply != nil {
+		prefs.CommentReply = *commentReply
+	}
+	prefs.UpdatedAt = time.Now()
+
+	_, err = s.db.GetCollection("notify_prefs").ReplaceOne(ctx, bson.M{"_id": deviceID}, prefs, options.Replace().SetUpsert(true))
+	if err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// notifyFollowers 向收藏或关注了小说、且开启了对应通知的设备发送更新通知，在后台调用，按批保存和推送
+//
+// 管理员每次修订正文都会触发内容更新，这类通知默认关闭，只发给主动开启了的设备
+func (s *NovelService) notifyFollowers(novelID, title, updateType string) {
+	var pref string
+	optIn := false
+	switch updateType {
+	case UpdateNewChapter:
+		pref = "newChapter"
+	case UpdateNewVolume:
+		pref = "newVolume"
+	case UpdateContentChange:
+		pref, optIn = "contentUpdate", true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	deviceIDs, err := s.novelFollowers(ctx, novelID, pref, optIn)
+	cancel()
+	if err != nil {
+		log.Printf("Failed to find followers of novel %s: %v", novelID, err)
+		return
+	}
+
+	event := websocket.NovelUpdate{
+		NovelID:     novelID,
+		Title:       title,
+		UpdateType:  updateType,
+		Description: novelUpdateDescription(updateType, title),
+	}
+	for start := 0; start < len(deviceIDs); start += noticeBatchSize {
+		end := min(start+noticeBatchSize, len(deviceIDs))
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		err := s.notify(ctx, deviceIDs[start:end], event)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to notify followers of novel %s: %v", novelID, err)
+			return
+		}
+	}
+}
+
+// notifyCommentReply 通知被回复的评论作者，回复自己的评论时不通知
+func (s *NovelService) notifyCommentReply(ctx context.Context, reply *models.Comment) {
+	if reply.ReplyTo == "" || reply.ReplyTo == reply.DeviceID {
+		return
+	}
+
+	prefs, err := s.GetNotificationPrefs(ctx, reply.ReplyTo)
+	if err != nil {
+		log.Printf("Failed to load notification preferences of %s: %v", reply.ReplyTo, err)
+		return
+	}
+	if !prefs.CommentReply {
+		return
+	}
+
+	err = s.notify(ctx, []string{reply.ReplyTo}, websocket.CommentReply{
+		CommentID:     reply.ID.Hex(),
+		ParentID:      reply.ParentID,
+		NovelID:       reply.NovelID,
+		VolumeNumber:  reply.VolumeNumber,
+		ChapterNumber: reply.ChapterNumber,
+		Content:       reply.Content,
+	})
+	if err != nil {
+		log.Printf("Failed to notify comment reply %s: %v", reply.ID.Hex(), err)
+	}
+}
+
+// SendSystemNotice 在后台向所有设备发送系统通知，绑定了账号的设备发给账号，分批保存和推送
+func (s *NovelService) SendSystemNotice(level, content string) {
+	go func() {
+		recipients, err := s.sendSystemNotice(context.Background(), websocket.SystemNotice{Level: level, Content: content})
+		if err != nil {
+			log.Printf("Failed to send system notice after %d recipients: %v", recipients, err)
+			return
+		}
+		log.Printf("Sent system notice to %d recipients", recipients)
+	}()
+}
+
+// sendSystemNotice 遍历设备并按批通知，返回已通知的用户数
+func (s *NovelService) sendSystemNotice(ctx context.Context, event websocket.SystemNotice) (int, error) {
+	opts := options.Find().SetProjection(bson.M{"_id": 1, "userId": 1}).SetBatchSize(noticeBatchSize)
+	cursor, err := s.db.GetCollection("devices").Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	sent := 0
+	flush := func(batch []string) error {
+		batchCtx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		defer cancel()
+		if err := s.notify(batchCtx, batch, event); err != nil {
+			return err
+		}
+		sent += len(batch)
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	batch := make([]string, 0, noticeBatchSize)
+	for cursor.Next(ctx) {
+		var device models.Device
+		if err := cursor.Decode(&device); err != nil {
+			return sent, err
+		}
+		id := device.ID
+		if device.UserID != "" {
+			id = device.UserID
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		batch = append(batch, id)
+
+		if len(batch) == noticeBatchSize {
+			if err := flush(batch); err != nil {
+				return sent, err
+			}
+			batch = make([]string, 0, noticeBatchSize)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return sent, err
+	}
+	if len(batch) > 0 {
+		if err := flush(batch); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// GetNotifications 分页获取通知，按时间倒序，同时返回未读数
+func (s *NovelService) GetNotifications(ctx context.Context, deviceID string, page, size int) ([]models.Notification, int64, int64, error) {
+	collection := s.db.GetCollection("notifications")
+	filter := bson.M{"deviceId": deviceID}
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	unread, err := collection.CountDocuments(ctx, bson.M{"deviceId": deviceID, "read": false})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip(int64((page - 1) * size)).
+		SetLimit(int64(size))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	notifications := []models.Notification{}
+	if err = cursor.All(ctx, &notifications); err != nil {
+		return nil, 0, 0, err
+	}
+	return notifications, total, unread, nil
+}
+
+// MarkNotificationRead 将通知标记为已读
+func (s *NovelService) MarkNotificationRead(ctx context.Context, deviceID, notificationID string) error {
+	id, err := primitive.ObjectIDFromHex(notificationID)
+	if err != nil {
+		return errors.NewError(errors.ErrInvalidParameter)
+	}
+
+	result, err := s.db.GetCollection("notifications").UpdateOne(ctx,
+		bson.M{"_id": id, "deviceId": deviceID},
+		bson.M{"$set": bson.M{"read": true}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.NewError(errors.ErrNotFound)
+	}
+	return nil
+}
+
+// AckNotifications 处理WebSocket客户端对通知的确认，已确认的通知视为已读，不合法的ID忽略
+func (s *NovelService) AckNotifications(ctx context.Context, deviceID string, notificationIDs []string) (int64, error) {
+	ids := make([]primitive.ObjectID, 0, len(notificationIDs))
+	for _, notificationID := range notificationIDs {
+		if id, err := primitive.ObjectIDFromHex(notificationID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// 推送的消息ID是整批共用的推送ID，通知列表中是通知ID，两者都可用于确认
+	result, err := s.db.GetCollection("notifications").UpdateMany(ctx,
+		bson.M{"deviceId": deviceID, "$or": bson.A{bson.M{"_id": bson.M{"$in": ids}}, bson.M{"pushId": bson.M{"$in": ids}}}},
+		bson.M{"$set": bson.M{"read": true, "delivered": true}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// MarkAllNotificationsRead 将全部通知标记为已读，返回标记的数量
+func (s *NovelService) MarkAllNotificationsRead(ctx context.Context, deviceID string) (int64, error) {
+	result, err := s.db.GetCollection("notifications").UpdateMany(ctx,
+		bson.M{"deviceId": deviceID, "read": false},
+		bson.M{"$set": bson.M{"read": true}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// DeliverPendingNotifications 设备连接后补发未确认且未读的通知，按时间顺序推送，客户端确认后才标记为已推送
+func (s *NovelService) DeliverPendingNotifications(ctx context.Context, deviceID string) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetLimit(maxPendingDelivery)
+	cursor, err := s.db.GetCollection("notifications").Find(ctx, bson.M{"deviceId": deviceID, "delivered": false, "read": false}, opts)
+	if err != nil {
+		log.Printf("Failed to load pending notifications of %s: %v", deviceID, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var pending []models.Notification
+	if err = cursor.All(ctx, &pending); err != nil {
+		log.Printf("Failed to load pending notifications of %s: %v", deviceID, err)
+		return
+	}
+
+	for i := range pending {
+		if !s.pushNotification(&pending[i]) {
+			log.Printf("Dropped %d pending notifications of %s, queue is full", len(pending)-i, deviceID)
+			break
+		}
+	}
+}
+
+// notify 为每个接收者保存一条通知，再以一条共用推送ID的消息推送给其中在线的设备，启用转发时由其他实例推送给连接在那里的设备。
+// 离线的设备不推送，客户端确认前通知都按未推送记录，重新连接时补发，可能重复收到，客户端按消息ID去重
+func (s *NovelService) notify(ctx context.Context, deviceIDs []string, event websocket.Event) error {
+	if len(deviceIDs) == 0 {
+		return nil
+	}
+
+	data, err := eventData(event)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	pushID := primitive.NewObjectID()
+	writes := make([]mongo.WriteModel, 0, len(deviceIDs))
+	for _, deviceID := range deviceIDs {
+		writes = append(writes, mongo.NewInsertOneModel().SetDocument(models.Notification{
+			ID:        primitive.NewObjectID(),
+			DeviceID:  deviceID,
+			Type:      event.EventType(),
+			Data:      data,
+			PushID:    pushID,
+			CreatedAt: now,
+		}))
+	}
+	if err := s.bulkWrite(ctx, "notifications", writes); err != nil {
+		return err
+	}
+
+	online := s.publisher.OnlineDevices(deviceIDs)
+	if len(online) == 0 {
+		return nil
+	}
+	msg := websocket.Message{ID: pushID.Hex(), Type: event.EventType(), Data: data, Time: now}
+	if !s.publisher.SendToDevices(online, msg) {
+		log.Printf("Dropped %s notification %s for %d online devices, delivered on reconnect", event.EventType(), pushID.Hex(), len(online))
+	}
+	return nil
+}
+
+// eventData 将事件转换为通知中保存的数据
+func eventData(event websocket.Event) (map[string]interface{}, error) {
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	err = json.Unmarshal(raw, &data)
+	return data, err
+}
+
+// novelFollowers 收藏或关注了小说的设备，pref不为空时排除关闭了该项通知的设备，optIn为真时只保留显式开启了的设备
+func (s *NovelService) novelFollowers(ctx context.Context, novelID, pref string, optIn bool) ([]string, error) {
+	followers := make(map[string]bool)
+	for _, name := range []string{"favorites", "follows"} {
+		ids, err := s.db.GetCollection(name).Distinct(ctx, "deviceId", bson.M{"novelId": novelID})
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if deviceID, ok := id.(string); ok {
+				followers[deviceID] = true
+			}
+		}
+	}
+	if len(followers) == 0 {
+		return nil, nil
+	}
+
+	if pref != "" {
+		ids := make([]string, 0, len(followers))
+		for id := range followers {
+			ids = append(ids, id)
+		}
+		// 默认开启的项排除显式关闭的设备，默认关闭的项只保留显式开启的设备
+		matched, err := s.db.GetCollection("notify_prefs").Distinct(ctx, "_id", bson.M{"_id": bson.M{"$in": ids}, pref: optIn})
+		if err != nil {
+			return nil, err
+		}
+		if optIn {
+			followers = make(map[string]bool, len(matched))
+		}
+		for _, id := range matched {
+			if deviceID, ok := id.(string); ok {
+				if optIn {
+					followers[deviceID] = true
+				} else {
+					delete(followers, deviceID)
+				}
+			}
+		}
+	}
+
+	deviceIDs := make([]string, 0, len(followers))
+	for id := range followers {
+		deviceIDs = append(deviceIDs, id)
+	}
+	return deviceIDs, nil
+}
+
+// pushNotification 通过WebSocket推送通知，队列已满时丢弃并返回false，通知保持未推送，客户端重新连接后补发。
+// 批量保存的通知沿用实时推送时的消息ID，便于客户端去重
+func (s *NovelService) pushNotification(n *models.Notification) bool {
+	id := n.ID
+	if !n.PushID.IsZero() {
+		id = n.PushID
+	}
+	msg := websocket.Message{ID: id.Hex(), Type: n.Type, Data: n.Data, Time: n.CreatedAt}
+	return s.publisher.SendToDevices([]string{n.DeviceID}, msg)
+}
+
+// migrateNotifications 将设备的关注、通知和通知偏好迁移到账号，账号已有通知偏好时保留账号的
+func (s *NovelService) migrateNotifications(ctx context.Context, deviceID, userID string) error {
+	_, err := s.db.GetCollection("notifications").UpdateMany(ctx, bson.M{"deviceId": deviceID}, bson.M{"$set": bson.M{"deviceId": userID}})
+	if err != nil {
+		return err
+	}
+
+	var follows []models.Follow
+	if err := s.findAll(ctx, "follows", bson.M{"deviceId": deviceID}, &follows); err != nil {
+		return err
+	}
+	for _, f := range follows {
+		_, err := s.db.GetCollection("follows").UpdateOne(ctx,
+			bson.M{"deviceId": userID, "novelId": f.NovelID},
+			bson.M{"$setOnInsert": bson.M{"createdAt": f.CreatedAt}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := s.db.GetCollection("follows").DeleteMany(ctx, bson.M{"deviceId": deviceID}); err != nil {
+		return err
+	}
+
+	prefs := s.db.GetCollection("notify_prefs")
+	var device models.NotificationPrefs
+	err = prefs.FindOne(ctx, bson.M{"_id": deviceID}).Decode(&device)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	device.DeviceID = userID
+	if _, err := prefs.InsertOne(ctx, device); err != nil && !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	_, err = prefs.DeleteOne(ctx, bson.M{"_id": deviceID})
+	return err
+}
+
+// novelUpdateDescription 根据更新类型生成通知文案
+func novelUpdateDescription(updateType, title string) string {
+	switch updateType {
+	case UpdateNewChapter:
+		return fmt.Sprintf("《%s》有新章节更新啦！", title)
+	case UpdateNewVolume:
+		return fmt.Sprintf("《%s》新卷发布！", title)
+	case UpdateContentChange:
+		return fmt.Sprintf("《%s》内容已更新", title)
+	default:
+		return fmt.Sprintf("《%s》有新的更新", title)
+	}
+}
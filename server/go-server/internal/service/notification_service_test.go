@@ -0,0 +1,187 @@
+// ****************************************************************************
+//
+// @file       notification_service_test.go
+// @brief      服务层通过Publisher推送通知和评论事件的测试
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"lightnovel/internal/models"
+	"lightnovel/pkg/websocket"
+)
+
+// recordingPublisher 记录发布的消息，不投递
+type recordingPublisher struct {
+	mu        sync.Mutex
+	broadcast []websocket.Message
+	direct    map[string][]websocket.Message
+	topics    map[string][]websocket.Message
+	online    map[string]bool
+}
+
+func newRecordingPublisher() *recordingPublisher {
+	return &recordingPublisher{
+		direct: make(map[string][]websocket.Message),
+		topics: make(map[string][]websocket.Message),
+		online: make(map[string]bool),
+	}
+}
+
+func (p *recordingPublisher) Broadcast(msg websocket.Message) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.broadcast = append(p.broadcast, msg)
+	return true
+}
+
+func (p *recordingPublisher) SendToDevices(deviceIDs []string, msg websocket.Message) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, id := range deviceIDs {
+		p.direct[id] = append(p.direct[id], msg)
+	}
+	return true
+}
+
+func (p *recordingPublisher) OnlineDevices(deviceIDs []string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	online := make([]string, 0, len(deviceIDs))
+	for _, id := range deviceIDs {
+		if p.online[id] {
+			online = append(online, id)
+		}
+	}
+	return online
+}
+
+func (p *recordingPublisher) PublishToTopic(topic string, msg websocket.Message) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.topics[topic] = append(p.topics[topic], msg)
+	return true
+}
+
+// TestNotificationReachesConnectedClient 服务层推送的通知经过已运行的Hub送达设备的WebSocket连接
+func TestNotificationReachesConnectedClient(t *testing.T) {
+	hub := websocket.NewHub()
+	go hub.Run()
+	s := &NovelService{publisher: hub}
+
+	upgrader := gorilla.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		client := websocket.NewClient(hub, conn)
+		client.DeviceID = r.URL.Query().Get("device")
+		hub.Register <- client
+		go client.WritePump()
+		go client.ReadPump()
+	}))
+	defer server.Close()
+
+	conn, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?device=device-1", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(hub.OnlineDevices([]string{"device-1"})) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("client was not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	event := websocket.NovelUpdate{NovelID: "novel-1", Title: "测试", UpdateType: UpdateNewChapter, Description: novelUpdateDescription(UpdateNewChapter, "测试")}
+	data, err := eventData(event)
+	if err != nil {
+		t.Fatalf("event data: %v", err)
+	}
+	n := models.Notification{ID: primitive.NewObjectID(), DeviceID: "device-1", Type: event.EventType(), Data: data, CreatedAt: time.Now()}
+	if !s.pushNotification(&n) {
+		t.Fatal("notification was dropped")
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, raw, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	var got struct {
+		ID   string                `json:"id"`
+		Type string                `json:"type"`
+		Data websocket.NovelUpdate `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("decode %s: %v", raw, err)
+	}
+	if got.ID != n.ID.Hex() || got.Type != websocket.EventNovelUpdate || got.Data != event {
+		t.Fatalf("unexpected message %s", raw)
+	}
+}
+
+// TestCommentDeletedPublishedToChapterTopic 删除顶层评论的事件发布到评论所在章节的主题
+func TestCommentDeletedPublishedToChapterTopic(t *testing.T) {
+	publisher := newRecordingPublisher()
+	s := &NovelService{publisher: publisher}
+
+	comment := &models.Comment{ID: primitive.NewObjectID(), NovelID: "novel-1", VolumeNumber: 2, ChapterNumber: 3}
+	s.publishCommentDeleted(context.Background(), comment)
+
+	topic := websocket.ChapterTopic("novel-1", 2, 3)
+	messages := publisher.topics[topic]
+	if len(messages) != 1 {
+		t.Fatalf("published %d messages to %s, want 1", len(messages), topic)
+	}
+	if messages[0].Type != websocket.EventCommentDeleted || messages[0].ID != "" {
+		t.Fatalf("unexpected message %+v", messages[0])
+	}
+	if event, ok := messages[0].Data.(websocket.CommentDeleted); !ok || event.CommentID != comment.ID.Hex() || event.Root != nil {
+		t.Fatalf("unexpected event %+v", messages[0].Data)
+	}
+	if len(publisher.direct) != 0 || len(publisher.broadcast) != 0 {
+		t.Fatalf("comment event was sent outside the topic: %v %v", publisher.direct, publisher.broadcast)
+	}
+}
+
+// TestPendingNotificationKeepsPushID 补发批量保存的通知时沿用实时推送的消息ID，客户端可据此去重
+func TestPendingNotificationKeepsPushID(t *testing.T) {
+	publisher := newRecordingPublisher()
+	s := &NovelService{publisher: publisher}
+
+	single := models.Notification{ID: primitive.NewObjectID(), DeviceID: "device-1", Type: websocket.EventNovelUpdate}
+	batched := models.Notification{ID: primitive.NewObjectID(), DeviceID: "device-1", Type: websocket.EventNovelUpdate, PushID: primitive.NewObjectID()}
+	s.pushNotification(&single)
+	s.pushNotification(&batched)
+
+	messages := publisher.direct["device-1"]
+	if len(messages) != 2 {
+		t.Fatalf("sent %d messages, want 2", len(messages))
+	}
+	if messages[0].ID != single.ID.Hex() || messages[1].ID != batched.PushID.Hex() {
+		t.Fatalf("unexpected message ids %q %q", messages[0].ID, messages[1].ID)
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"lightnovel/pkg/concurrency"
 	"lightnovel/pkg/database"
 	"lightnovel/pkg/errors"
+	"lightnovel/pkg/filter"
 	"lightnovel/pkg/websocket"
 
 	"github.com/google/uuid"
@@ -36,35 +37,58 @@ import (
 
 // NovelService 小说服务
 type NovelService struct {
-	db    *database.MongoDB
-	cache cache.Cache
-	wsHub *websocket.Hub
-	cfg   *config.Config
+	db        *database.MongoDB
+	cache     cache.Cache
+	publisher websocket.Publisher // 推送WebSocket消息
+	cfg       *config.Config
+	words     *filter.Filter // 评论敏感词过滤
 }
 
-// NewNovelService 创建小说服务
-func NewNovelService(db *database.MongoDB, cache cache.Cache, cfg *config.Config) *NovelService {
+// NewNovelService 创建小说服务，publisher通常是与WebSocket处理器共用的同一个已运行的Hub
+func NewNovelService(db *database.MongoDB, cache cache.Cache, publisher websocket.Publisher, cfg *config.Config) *NovelService {
 	return &NovelService{
-		db:    db,
-		cache: cache,
-		wsHub: websocket.NewHub(),
-		cfg:   cfg,
+		db:        db,
+		cache:     cache,
+		publisher: publisher,
+		cfg:       cfg,
+		words:     newWordFilter(cfg),
 	}
 }
 
-// NotifyNovelUpdate 通知小说更新并清除相关缓存
-func (s *NovelService) NotifyNovelUpdate(novelID string, title string) {
+// NotifyNovelUpdate 清除小说相关缓存，向订阅了小说主题的连接推送更新事件，并在后台通知收藏或关注了该小说的设备，管理请求只等待缓存清除
+func (s *NovelService) NotifyNovelUpdate(novelID string, title string, updateType string) {
 	ctx := context.Background()
 
 	// 清除相关缓存
-	s.cache.Delete(ctx, cache.NovelListKey)
+	s.invalidateNovelCache(ctx, novelID)
+
+	// 正在浏览该小说的连接实时刷新，不保存通知，消息不带ID，无需确认
+	s.publisher.PublishToTopic(websocket.NovelTopic(novelID), websocket.NewMessage("", websocket.NovelUpdate{
+		NovelID:     novelID,
+		Title:       title,
+		UpdateType:  updateType,
+		Description: novelUpdateDescription(updateType, title),
+	}))
+
+	// 只推送给关注者，按各自的通知偏好过滤
+	go s.notifyFollowers(novelID, title, updateType)
+}
+
+// invalidateNovelCache 清除与小说相关的所有缓存
+func (s *NovelService) invalidateNovelCache(ctx context.Context, novelID string) {
+	s.cache.DeleteByPattern(ctx, cache.NovelListKey+"*")
 	s.cache.Delete(ctx, cache.NovelDetailKey+novelID)
+	s.cache.Delete(ctx, fmt.Sprintf("%s:%s", cache.NovelDetailKey, novelID))
 	s.cache.Delete(ctx, cache.LatestNovelsKey)
+	s.cache.DeleteByPattern(ctx, cache.PopularNovelsKey+"*")
+	s.cache.DeleteByPattern(ctx, cache.TopRatedKey+"*")
+	s.cache.DeleteByPattern(ctx, cache.RelatedKey+"*")
+	s.cache.DeleteByPattern(ctx, cache.SearchKey+"*")
+	s.cache.DeleteByPattern(ctx, cache.ContentSearchKey+novelID+"*")
+	s.cache.Delete(ctx, cache.VolumeListKey+novelID)
+	s.cache.DeleteByPattern(ctx, cache.ChapterListKey+novelID+"*")
 	s.cache.DeleteByPattern(ctx, cache.ChapterKey+novelID+"*")
-
-	// 发送WebSocket通知
-	message := fmt.Sprintf("小说《%s》已更新", title)
-	s.wsHub.Broadcast <- []byte(message)
+	s.cache.DeleteByPattern(ctx, fmt.Sprintf("%s:%s*", cache.ChapterKey, novelID))
 }
 
 // GetAllNovels 获取所有小说（支持分页）
@@ -339,65 +363,6 @@ func (s *NovelService) GetChapterByNumber(ctx context.Context, novelID string, v
 	return &chapter, nil
 }
 
-// SearchNovels 搜索小说
-func (s *NovelService) SearchNovels(ctx context.Context, keyword string, page, size int) ([]models.Novel, int64, error) {
-	var novels []models.Novel
-	var total int64
-	cacheKey := cache.SearchKey + keyword
-
-	// 计算跳过的文档数
-	skip := (page - 1) * size
-
-	// 尝试从缓存获取
-	err := s.cache.Get(ctx, cacheKey, &novels)
-	if err == nil && len(novels) > 0 {
-		// 获取总数
-		countKey := cacheKey + ":count"
-		err = s.cache.Get(ctx, countKey, &total)
-		if err == nil {
-			return novels, total, nil
-		}
-	}
-
-	// 从数据库搜索
-	filter := bson.M{
-		"$or": []bson.M{
-			{"title": bson.M{"$regex": keyword, "$options": "i"}},
-			{"description": bson.M{"$regex": keyword, "$options": "i"}},
-			{"author": bson.M{"$regex": keyword, "$options": "i"}},
-			{"tags": bson.M{"$in": []string{keyword}}},
-		},
-	}
-
-	// 获取总数
-	total, err = s.db.GetCollection("novels").CountDocuments(ctx, filter)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	// 查询数据
-	opts := options.Find().
-		SetSort(bson.D{{Key: "readCount", Value: -1}}).
-		SetSkip(int64(skip)).
-		SetLimit(int64(size))
-
-	cursor, err := s.db.GetCollection("novels").Find(ctx, filter, opts)
-	if err != nil {
-		return nil, 0, err
-	}
-	defer cursor.Close(ctx)
-
-	if err = cursor.All(ctx, &novels); err != nil {
-		return nil, 0, err
-	}
-
-	// 设置缓存
-	s.cache.Set(ctx, cacheKey, novels, s.cfg.Cache.SearchResult)
-	s.cache.Set(ctx, cacheKey+":count", total, s.cfg.Cache.SearchResult)
-
-	return novels, total, nil
-}
-
 // GetLatestNovels 获取最新小说
 func (s *NovelService) GetLatestNovels(ctx context.Context, limit int) ([]models.Novel, error) {
 	var novels []models.Novel
@@ -451,7 +416,7 @@ func (s *NovelService) GetPopularNovelsParallel(ctx context.Context, limit int)
 	defer pool.Stop()
 
 	// 尝试从缓存获取
-	cacheKey := fmt.Sprintf("popular_novels:%d", limit)
+	cacheKey := fmt.Sprintf("%s:%d", cache.PopularNovelsKey, limit)
 	var cachedNovels []*models.Novel
 	if err := s.cache.Get(ctx, cacheKey, &cachedNovels); err == nil && len(cachedNovels) > 0 {
 		return cachedNovels, nil
@@ -490,28 +455,6 @@ func (s *NovelService) GetPopularNovelsParallel(ctx context.Context, limit int)
 	return novels, nil
 }
 
-// IncrementNovelReadCount 增加小说阅读量
-func (s *NovelService) IncrementNovelReadCount(ctx context.Context, novelID string) error {
-	objectID, err := primitive.ObjectIDFromHex(novelID)
-	if err != nil {
-		return err
-	}
-
-	_, err = s.db.GetCollection("novels").UpdateOne(
-		ctx,
-		bson.M{"_id": objectID},
-		bson.M{"$inc": bson.M{"readCount": 1}},
-	)
-	if err != nil {
-		return err
-	}
-
-	// 清除相关缓存
-	s.cache.Delete(ctx, cache.PopularNovelsKey)
-	s.cache.Delete(ctx, cache.NovelDetailKey+novelID)
-	return nil
-}
-
 // FindDeviceByIP 通过IP地址查找设备
 func (s *NovelService) FindDeviceByIP(ctx context.Context, ip string) (*models.Device, error) {
 	var device models.Device
@@ -536,6 +479,9 @@ func (s *NovelService) CreateNewDevice(ctx context.Context, ip string, userAgent
 		FirstSeen:  time.Now(),
 		LastSeen:   time.Now(),
 	}
+	if err := s.inheritRestriction(ctx, device); err != nil {
+		return nil, err
+	}
 
 	_, err := s.db.GetCollection("devices").InsertOne(ctx, device)
 	if err != nil {
@@ -565,6 +511,9 @@ func (s *NovelService) GetOrCreateDevice(ctx context.Context, deviceID string, i
 			FirstSeen:  time.Now(),
 			LastSeen:   time.Now(),
 		}
+		if err := s.inheritRestriction(ctx, &device); err != nil {
+			return nil, err
+		}
 
 		_, err = collection.InsertOne(ctx, device)
 		if err != nil {
@@ -646,28 +595,7 @@ func (s *NovelService) UpsertReadHistory(ctx context.Context, deviceID string, n
 	}
 
 	// 使用 upsert 操作,如果存在则更新 lastRead
-	filter := bson.M{
-		"deviceId": deviceID,
-		"novelId":  novelID,
-	}
-	update := bson.M{
-		"$set": bson.M{
-			"lastRead": lastRead,
-		},
-		"$setOnInsert": bson.M{
-			"_id": primitive.NewObjectID(),
-		},
-	}
-	opts := options.Update().SetUpsert(true)
-
-	_, err := s.db.GetCollection("read_history").UpdateOne(ctx, filter, update, opts)
-	if err != nil {
-		return err
-	}
-
-	// 删除缓存
-	s.cache.Delete(ctx, cache.ReadHistoryKey+deviceID)
-	return nil
+	return s.writeHistory(ctx, deviceID, novelID, *lastRead, now)
 }
 
 // DeleteReadHistory 删除单条阅读历史
@@ -677,7 +605,7 @@ func (s *NovelService) DeleteReadHistory(ctx context.Context, deviceID string, n
 		"novelId":  novelID,
 	}
 
-	_, err := s.db.GetCollection("read_history").DeleteOne(ctx, filter)
+	result, err := s.db.GetCollection("read_history").DeleteOne(ctx, filter)
 	if err != nil {
 		return err
 	}
@@ -685,6 +613,10 @@ func (s *NovelService) DeleteReadHistory(ctx context.Context, deviceID string, n
 	// 删除相关缓存
 	s.cache.Delete(ctx, cache.ReadHistoryKey+deviceID)
 	s.cache.Delete(ctx, cache.ReadProgressKey+deviceID+":"+novelID)
+
+	if result.DeletedCount > 0 {
+		return s.recordDeletion(ctx, deviceID, KindHistory, novelID)
+	}
 	return nil
 }
 
@@ -711,6 +643,10 @@ func (s *NovelService) ClearReadHistory(ctx context.Context, deviceID string) er
 	}
 
 	// 删除阅读进度
+	var progresses []models.ReadProgress
+	if err = s.findAll(ctx, "read_progress", filter, &progresses); err != nil {
+		return err
+	}
 	_, err = s.db.GetCollection("read_progress").DeleteMany(ctx, filter)
 	if err != nil {
 		return err
@@ -722,6 +658,19 @@ func (s *NovelService) ClearReadHistory(ctx context.Context, deviceID string) er
 		s.cache.Delete(ctx, cache.ReadProgressKey+deviceID+":"+history.NovelID)
 	}
 
+	// 记录删除，供其他设备同步
+	for _, history := range histories {
+		if err := s.recordDeletion(ctx, deviceID, KindHistory, history.NovelID); err != nil {
+			return err
+		}
+	}
+	for _, progress := range progresses {
+		s.cache.Delete(ctx, cache.ReadProgressKey+deviceID+":"+progress.NovelID)
+		if err := s.recordDeletion(ctx, deviceID, KindProgress, progress.NovelID); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -754,42 +703,31 @@ func (s *NovelService) GetReadProgress(ctx context.Context, deviceID string, nov
 	return &progress, nil
 }
 
-// UpdateReadProgress 更新阅读进度
-func (s *NovelService) UpdateReadProgress(ctx context.Context, deviceID string, novelID string, volumeNumber int, chapterNumber int, position int) error {
-	now := time.Now()
-
-	// 使用 upsert 操作
-	filter := bson.M{
-		"deviceId": deviceID,
-		"novelId":  novelID,
+// UpdateReadProgress 更新阅读进度，按同步冲突规则处理，旧于服务端记录的进度会被忽略
+func (s *NovelService) UpdateReadProgress(ctx context.Context, deviceID string, novelID string, volumeNumber int, chapterNumber int, position int, clientUpdatedAt *time.Time) error {
+	updatedAt := time.Now()
+	if clientUpdatedAt != nil {
+		updatedAt = *clientUpdatedAt
 	}
-	update := bson.M{
-		"$set": bson.M{
-			"volumeNumber":  volumeNumber,
-			"chapterNumber": chapterNumber,
-			"position":      position,
-			"updatedAt":     now,
-		},
-		"$setOnInsert": bson.M{
-			"_id": primitive.NewObjectID(),
-		},
-	}
-	opts := options.Update().SetUpsert(true)
 
-	_, err := s.db.GetCollection("read_progress").UpdateOne(ctx, filter, update, opts)
+	_, err := s.applyProgress(ctx, deviceID, models.ProgressChange{
+		NovelID:       novelID,
+		VolumeNumber:  volumeNumber,
+		ChapterNumber: chapterNumber,
+		Position:      position,
+		UpdatedAt:     updatedAt,
+	})
 	if err != nil {
 		return err
 	}
 
 	// 更新阅读历史
-	err = s.UpsertReadHistory(ctx, deviceID, novelID, nil)
-	if err != nil {
-		return err
-	}
-
-	// 删除缓存
-	s.cache.Delete(ctx, cache.ReadProgressKey+deviceID+":"+novelID)
-	return nil
+	_, err = s.applyHistory(ctx, deviceID, models.HistoryChange{
+		NovelID:   novelID,
+		LastRead:  updatedAt,
+		UpdatedAt: updatedAt,
+	})
+	return err
 }
 
 // DeleteReadProgress 删除阅读进度
@@ -799,13 +737,18 @@ func (s *NovelService) DeleteReadProgress(ctx context.Context, deviceID string,
 		"novelId":  novelID,
 	}
 
-	_, err := s.db.GetCollection("read_progress").DeleteOne(ctx, filter)
+	result, err := s.db.GetCollection("read_progress").DeleteOne(ctx, filter)
 	if err != nil {
 		return err
 	}
 
 	// 删除相关缓存
 	s.cache.Delete(ctx, cache.ReadProgressKey+deviceID+":"+novelID)
+	s.cache.Delete(ctx, cache.ReadingStatKey+deviceID)
+
+	if result.DeletedCount > 0 {
+		return s.recordDeletion(ctx, deviceID, KindProgress, novelID)
+	}
 	return nil
 }
 
@@ -843,8 +786,14 @@ func (s *NovelService) GetNovelsByIDs(ctx context.Context, ids []string) (map[st
 		return result, nil
 	}
 
-	// 从数据库批量获取未命中缓存的数据
-	filter := bson.M{"_id": bson.M{"$in": notFound}}
+	// 从数据库批量获取未命中缓存的数据，小说的_id为ObjectID
+	objectIDs := make([]primitive.ObjectID, 0, len(notFound))
+	for _, id := range notFound {
+		if objectID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objectIDs = append(objectIDs, objectID)
+		}
+	}
+	filter := bson.M{"_id": bson.M{"$in": objectIDs}}
 	cursor, err := s.db.GetCollection("novels").Find(ctx, filter)
 	if err != nil {
 		return nil, err
@@ -900,27 +849,37 @@ func (s *NovelService) GetChaptersByNovelID(ctx context.Context, novelID string)
 	return result, nil
 }
 
-// IncrementNovelReadCountBatch 批量增加小说阅读量
-func (s *NovelService) IncrementNovelReadCountBatch(ctx context.Context, novelIDs []string) error {
-	if len(novelIDs) == 0 {
+// IncrementNovelReadCountBatch 批量增加小说阅读量，reads为新增阅读次数，uniqueReaders为最新的独立读者数
+func (s *NovelService) IncrementNovelReadCountBatch(ctx context.Context, reads, uniqueReaders map[string]int64) error {
+	if len(reads) == 0 {
 		return nil
 	}
 
-	// 批量更新阅读量
-	filter := bson.M{"_id": bson.M{"$in": novelIDs}}
-	update := bson.M{"$inc": bson.M{"readCount": 1}}
-	_, err := s.db.GetCollection("novels").UpdateMany(ctx, filter, update)
-	if err != nil {
+	// 批量更新阅读量，独立读者数只增不减，避免 Redis 数据丢失后回退
+	writes := make([]mongo.WriteModel, 0, len(reads))
+	for id, count := range reads {
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			continue
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": objectID}).
+			SetUpdate(bson.M{
+				"$inc": bson.M{"readCount": count, "totalReads": count},
+				"$max": bson.M{"uniqueReaders": uniqueReaders[id]},
+			}))
+	}
+	if err := s.bulkWrite(ctx, "novels", writes); err != nil {
 		return err
 	}
 
 	// 删除相关缓存
-	for _, id := range novelIDs {
-		cacheKey := fmt.Sprintf("%s:%s", cache.NovelDetailKey, id)
-		s.cache.Delete(ctx, cacheKey)
+	for id := range reads {
+		s.cache.Delete(ctx, cache.NovelDetailKey+id)
+		s.cache.Delete(ctx, fmt.Sprintf("%s:%s", cache.NovelDetailKey, id))
 	}
 	// 删除热门小说缓存
-	s.cache.Delete(ctx, fmt.Sprintf("%s:%d", cache.PopularNovelsKey, 10))
+	s.cache.DeleteByPattern(ctx, cache.PopularNovelsKey+"*")
 
 	return nil
 }
@@ -950,6 +909,11 @@ func (s *NovelService) CreateBookmark(ctx context.Context, deviceID string, nove
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
+	bookmark.ClientUpdatedAt = bookmark.UpdatedAt
+	bookmark.Version, err = s.nextSyncVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	_, err = s.db.GetCollection("bookmarks").InsertOne(ctx, bookmark)
 	if err != nil {
@@ -986,7 +950,7 @@ func (s *NovelService) DeleteBookmark(ctx context.Context, deviceID string, book
 	cacheKey := fmt.Sprintf("%s:%s", cache.BookmarkKey, deviceID)
 	s.cache.Delete(ctx, cacheKey)
 
-	return nil
+	return s.recordDeletion(ctx, deviceID, KindBookmark, objectID.Hex())
 }
 
 // UpdateBookmark 更新书签
@@ -996,10 +960,18 @@ func (s *NovelService) UpdateBookmark(ctx context.Context, deviceID string, book
 		return nil, errors.NewError(errors.ErrInvalidParameter)
 	}
 
+	version, err := s.nextSyncVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
 	update := bson.M{
 		"$set": bson.M{
-			"note":      note,
-			"updatedAt": time.Now(),
+			"note":            note,
+			"updatedAt":       now,
+			"clientUpdatedAt": now,
+			"version":         version,
 		},
 	}
 
@@ -1082,6 +1054,10 @@ func (s *NovelService) AddFavorite(ctx context.Context, deviceID string, novelID
 		}
 		return err
 	}
+	s.recordRanking(ctx, RankingFavorites, novelID)
+	if err := s.syncFavoriteShelf(ctx, deviceID, novelID, true); err != nil {
+		return err
+	}
 
 	// 清除相关缓存
 	s.cache.Delete(ctx, cache.FavoriteKey+deviceID)
@@ -1101,6 +1077,9 @@ func (s *NovelService) RemoveFavorite(ctx context.Context, deviceID string, nove
 	if result.DeletedCount == 0 {
 		return errors.NewError(errors.ErrNotFound)
 	}
+	if err := s.syncFavoriteShelf(ctx, deviceID, novelID, false); err != nil {
+		return err
+	}
 
 	// 清除相关缓存
 	s.cache.Delete(ctx, cache.FavoriteKey+deviceID)
@@ -1254,28 +1233,48 @@ func (s *NovelService) UpdateUserProfile(ctx context.Context, deviceID string, n
 	return &updatedUser, nil
 }
 
-// GetComments 获取章节评论
-func (s *NovelService) GetComments(ctx context.Context, novelID string, volumeNumber, chapterNumber, page, size int) ([]models.CommentResponse, int64, error) {
-	cacheKey := fmt.Sprintf("%s%s:%d:%d:%d:%d", cache.CommentListKey, novelID, volumeNumber, chapterNumber, page, size)
+// GetComments 获取章节的顶层评论，置顶评论排在最前
+//
+// paragraph为空时返回章评(含无法重新定位的段评)，否则返回该段落的段评
+func (s *NovelService) GetComments(ctx context.Context, deviceID, novelID string, volumeNumber, chapterNumber int, paragraph *int, sort string, page, size int) ([]models.CommentResponse, int64, error) {
+	if _, ok := commentSorts[sort]; !ok {
+		sort = CommentSortNew
+	}
+	scope := "chapter"
+	if paragraph != nil {
+		scope = fmt.Sprintf("p%d", *paragraph)
+	}
+	cacheKey := fmt.Sprintf("%s%s:%d:%d:%s:%s:%d:%d", cache.CommentListKey, novelID, volumeNumber, chapterNumber, scope, sort, page, size)
+	totalKey := fmt.Sprintf("%s%s:%d:%d:%s:total", cache.CommentListKey, novelID, volumeNumber, chapterNumber, scope)
 
 	var commentResponses []models.CommentResponse
 	err := s.cache.Get(ctx, cacheKey, &commentResponses)
 	if err == nil && len(commentResponses) > 0 {
 		// 从缓存获取总数
 		var total int64
-		totalKey := fmt.Sprintf("%s%s:%d:%d:total", cache.CommentListKey, novelID, volumeNumber, chapterNumber)
 		s.cache.Get(ctx, totalKey, &total)
-		return commentResponses, total, nil
+		return commentResponses, total, s.fillCommentLiked(ctx, deviceID, commentResponses)
 	}
 
 	// 缓存未命中，查询数据库
 	collection := s.db.GetCollection("comments")
 
-	// 查询条件
+	// 查询条件，只查顶层评论
 	filter := bson.M{
 		"novelId":       novelID,
 		"volumeNumber":  volumeNumber,
 		"chapterNumber": chapterNumber,
+		"parentId":      bson.M{"$exists": false},
+		"status":        visibleComment,
+	}
+	if paragraph != nil {
+		filter["anchor.paragraph"] = *paragraph
+		filter["anchor.orphaned"] = bson.M{"$ne": true}
+	} else {
+		filter["$or"] = bson.A{
+			bson.M{"anchor": bson.M{"$exists": false}},
+			bson.M{"anchor.orphaned": true},
+		}
 	}
 
 	// 查询总数
@@ -1285,12 +1284,14 @@ func (s *NovelService) GetComments(ctx context.Context, novelID string, volumeNu
 	}
 
 	// 查询评论列表
-	opts := options.Find().
-		SetSort(bson.M{"createdAt": -1}).
-		SetSkip(int64((page - 1) * size)).
-		SetLimit(int64(size))
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: filter}}}
+	pipeline = append(pipeline, commentSorts[sort]...)
+	pipeline = append(pipeline,
+		bson.D{{Key: "$skip", Value: int64((page - 1) * size)}},
+		bson.D{{Key: "$limit", Value: int64(size)}},
+	)
 
-	cursor, err := collection.Find(ctx, filter, opts)
+	cursor, err := collection.Aggregate(ctx, pipeline)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -1301,43 +1302,17 @@ func (s *NovelService) GetComments(ctx context.Context, novelID string, volumeNu
 		return nil, 0, err
 	}
 
-	// 获取用户信息
-	userCollection := s.db.GetCollection("users")
-	commentResponses = make([]models.CommentResponse, 0, len(comments))
-
-	for _, comment := range comments {
-		var user models.User
-		err := userCollection.FindOne(ctx, bson.M{"_id": comment.DeviceID}).Decode(&user)
-		if err != nil {
-			user.Name = "已删除用户"
-			user.Avatar = "/static/avatars/default.png"
-		}
-
-		commentResponse := models.CommentResponse{
-			ID:            comment.ID,
-			UserID:        comment.DeviceID,
-			UserName:      user.Name,
-			UserAvatar:    user.Avatar,
-			NovelID:       comment.NovelID,
-			VolumeNumber:  comment.VolumeNumber,
-			ChapterNumber: comment.ChapterNumber,
-			Content:       comment.Content,
-			CreatedAt:     comment.CreatedAt,
-		}
-
-		commentResponses = append(commentResponses, commentResponse)
-	}
+	commentResponses = s.toCommentResponses(ctx, comments)
 
 	// 存入缓存
 	s.cache.Set(ctx, cacheKey, commentResponses, s.cfg.Cache.Comment)
-	totalKey := fmt.Sprintf("%s%s:%d:%d:total", cache.CommentListKey, novelID, volumeNumber, chapterNumber)
 	s.cache.Set(ctx, totalKey, total, s.cfg.Cache.Comment)
 
-	return commentResponses, total, nil
+	return commentResponses, total, s.fillCommentLiked(ctx, deviceID, commentResponses)
 }
 
-// CreateComment 创建评论
-func (s *NovelService) CreateComment(ctx context.Context, deviceID, novelID string, volumeNumber, chapterNumber int, content string) (*models.Comment, error) {
+// CreateComment 创建评论，parentID不为空时作为回复挂到所属的顶层评论下，anchor不为空时作为段评
+func (s *NovelService) CreateComment(ctx context.Context, deviceID, novelID string, volumeNumber, chapterNumber int, content, parentID string, anchor *models.CommentAnchor) (*models.Comment, error) {
 	// 验证小说和章节是否存在
 	chapter, err := s.GetChapterByNumber(ctx, novelID, volumeNumber, chapterNumber)
 	if err != nil {
@@ -1348,6 +1323,12 @@ func (s *NovelService) CreateComment(ctx context.Context, deviceID, novelID stri
 		return nil, errors.NewError(errors.ErrChapterNotFound)
 	}
 
+	// 敏感词处理
+	content, status, err := s.moderateContent(content)
+	if err != nil {
+		return nil, err
+	}
+
 	// 创建评论
 	now := time.Now()
 	comment := models.Comment{
@@ -1357,10 +1338,34 @@ func (s *NovelService) CreateComment(ctx context.Context, deviceID, novelID stri
 		VolumeNumber:  volumeNumber,
 		ChapterNumber: chapterNumber,
 		Content:       content,
+		Status:        status,
 		CreatedAt:     now,
 		UpdatedAt:     now,
 	}
 
+	// 回复统一挂在顶层评论下，回复的回复记录被回复者
+	if parentID != "" {
+		parent, err := s.getComment(ctx, parentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent.NovelID != novelID || parent.VolumeNumber != volumeNumber || parent.ChapterNumber != chapterNumber {
+			return nil, errors.NewErrorWithMessage(errors.ErrInvalidParameter, "回复的评论不属于该章节")
+		}
+
+		comment.ParentID = parent.ID.Hex()
+		if parent.ParentID != "" {
+			comment.ParentID = parent.ParentID
+		}
+		comment.ReplyTo = parent.DeviceID
+	} else if anchor != nil {
+		// 段评锚点只记录在顶层评论上，回复随所属评论展示
+		comment.Anchor, err = newAnchor(chapter.Content, anchor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// 插入数据库
 	collection := s.db.GetCollection("comments")
 	_, err = collection.InsertOne(ctx, comment)
@@ -1368,9 +1373,22 @@ func (s *NovelService) CreateComment(ctx context.Context, deviceID, novelID stri
 		return nil, err
 	}
 
+	// 更新顶层评论的回复数，待审核的回复在审核通过后计入
+	if comment.ParentID != "" && comment.Status == "" {
+		rootID, _ := primitive.ObjectIDFromHex(comment.ParentID)
+		_, err = collection.UpdateOne(ctx, bson.M{"_id": rootID}, bson.M{"$inc": bson.M{"replyCount": 1}})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// 清除相关缓存
-	pattern := fmt.Sprintf("%s%s:%d:%d:*", cache.CommentListKey, novelID, volumeNumber, chapterNumber)
-	s.cache.DeleteByPattern(ctx, pattern)
+	s.invalidateCommentCache(ctx, &comment)
+	s.recordRanking(ctx, RankingComments, novelID)
+	if comment.Status == "" {
+		s.notifyCommentReply(ctx, &comment)
+		s.publishCommentCreated(ctx, &comment)
+	}
 
 	// 更新用户最后活跃时间
 	s.updateUserLastActive(ctx, deviceID)
@@ -1378,7 +1396,7 @@ func (s *NovelService) CreateComment(ctx context.Context, deviceID, novelID stri
 	return &comment, nil
 }
 
-// DeleteComment 删除评论
+// DeleteComment 删除评论，删除顶层评论时一并删除其回复
 func (s *NovelService) DeleteComment(ctx context.Context, deviceID, commentID string) error {
 	id, err := primitive.ObjectIDFromHex(commentID)
 	if err != nil {
@@ -1401,15 +1419,51 @@ func (s *NovelService) DeleteComment(ctx context.Context, deviceID, commentID st
 		return err
 	}
 
+	return s.removeComment(ctx, &comment)
+}
+
+// removeComment 删除评论及其回复和点赞，并维护回复数
+func (s *NovelService) removeComment(ctx context.Context, comment *models.Comment) error {
+	collection := s.db.GetCollection("comments")
+	ids := []primitive.ObjectID{comment.ID}
+
+	if comment.ParentID == "" {
+		var replies []models.Comment
+		if err := s.findAll(ctx, "comments", bson.M{"parentId": comment.ID.Hex()}, &replies); err != nil {
+			return err
+		}
+		for _, reply := range replies {
+			ids = append(ids, reply.ID)
+		}
+	}
+
 	// 删除评论
-	_, err = collection.DeleteOne(ctx, bson.M{"_id": id})
+	_, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.GetCollection("comment_likes").DeleteMany(ctx, bson.M{"commentId": bson.M{"$in": ids}})
 	if err != nil {
 		return err
 	}
 
+	if comment.ParentID != "" && comment.Status == "" {
+		rootID, _ := primitive.ObjectIDFromHex(comment.ParentID)
+		_, err = collection.UpdateOne(ctx,
+			bson.M{"_id": rootID, "replyCount": bson.M{"$gt": 0}},
+			bson.M{"$inc": bson.M{"replyCount": -1}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
 	// 清除相关缓存
-	pattern := fmt.Sprintf("%s%s:%d:%d:*", cache.CommentListKey, comment.NovelID, comment.VolumeNumber, comment.ChapterNumber)
-	s.cache.DeleteByPattern(ctx, pattern)
+	s.invalidateCommentCache(ctx, comment)
+	if comment.Status == "" {
+		s.publishCommentDeleted(ctx, comment)
+	}
 
 	return nil
 }
@@ -0,0 +1,121 @@
+// ****************************************************************************
+//
+// @file       paragraph_comment_service.go
+// @brief      段评的锚点定位、段落计数与正文修改后的重新定位
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"lightnovel/internal/models"
+	"lightnovel/pkg/anchor"
+	"lightnovel/pkg/cache"
+	"lightnovel/pkg/errors"
+)
+
+const maxQuoteLen = 100 // 整段评论时保存的原文最大字符数
+
+// GetParagraphCommentCounts 获取章节各段落的段评数量，没有段评的段落不返回
+func (s *NovelService) GetParagraphCommentCounts(ctx context.Context, novelID string, volumeNumber, chapterNumber int) ([]models.ParagraphCommentCount, error) {
+	cacheKey := fmt.Sprintf("%s%s:%d:%d:paragraphs", cache.CommentListKey, novelID, volumeNumber, chapterNumber)
+
+	var counts []models.ParagraphCommentCount
+	if err := s.cache.Get(ctx, cacheKey, &counts); err == nil && counts != nil {
+		return counts, nil
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"novelId":         novelID,
+			"volumeNumber":    volumeNumber,
+			"chapterNumber":   chapterNumber,
+			"parentId":        bson.M{"$exists": false},
+			"status":          visibleComment,
+			"anchor":          bson.M{"$exists": true},
+			"anchor.orphaned": bson.M{"$ne": true},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$anchor.paragraph",
+			"count": bson.M{"$sum": bson.M{"$add": bson.A{1, bson.M{"$ifNull": bson.A{"$replyCount", 0}}}}},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+
+	cursor, err := s.db.GetCollection("comments").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	counts = []models.ParagraphCommentCount{}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+
+	s.cache.Set(ctx, cacheKey, counts, s.cfg.Cache.Comment)
+	return counts, nil
+}
+
+// reanchorComments 章节正文修改后重新定位该章的段评，找不到原文的段评标记为失效
+func (s *NovelService) reanchorComments(ctx context.Context, novelID string, volumeNumber, chapterNumber int, content string) error {
+	moved, err := anchor.Reanchor(ctx, s.db.GetCollection("comments"), novelID, volumeNumber, chapterNumber, content)
+	if moved > 0 {
+		log.Printf("Re-anchored %d comments of novel %s volume %d chapter %d", moved, novelID, volumeNumber, chapterNumber)
+		s.invalidateChapterComments(ctx, novelID, volumeNumber, chapterNumber)
+	}
+	return err
+}
+
+// orphanComments 章节删除后将该章的段评标记为失效
+func (s *NovelService) orphanComments(ctx context.Context, novelID string, volumeNumber, chapterNumber int) error {
+	orphaned, err := anchor.Orphan(ctx, s.db.GetCollection("comments"), novelID, volumeNumber, chapterNumber)
+	if orphaned > 0 {
+		s.invalidateChapterComments(ctx, novelID, volumeNumber, chapterNumber)
+	}
+	return err
+}
+
+// invalidateChapterComments 清除章节评论列表的缓存
+func (s *NovelService) invalidateChapterComments(ctx context.Context, novelID string, volumeNumber, chapterNumber int) {
+	pattern := fmt.Sprintf("%s%s:%d:%d:*", cache.CommentListKey, novelID, volumeNumber, chapterNumber)
+	s.cache.DeleteByPattern(ctx, pattern)
+}
+
+// newAnchor 校验客户端提交的段落位置并记录被评论的原文
+func newAnchor(content string, input *models.CommentAnchor) (*models.CommentAnchor, error) {
+	paragraphs := anchor.SplitParagraphs(content)
+	if input.Paragraph < 0 || input.Paragraph >= len(paragraphs) {
+		return nil, errors.NewErrorWithMessage(errors.ErrInvalidParameter, "段落不存在")
+	}
+
+	text := []rune(paragraphs[input.Paragraph])
+	if input.Start < 0 || input.End < input.Start || input.End > len(text) {
+		return nil, errors.NewErrorWithMessage(errors.ErrInvalidParameter, "段落范围无效")
+	}
+
+	result := &models.CommentAnchor{
+		Paragraph: input.Paragraph,
+		Start:     input.Start,
+		End:       input.End,
+	}
+	if input.Start == input.End {
+		// 整段评论只保存段首，足以在修改后重新找到该段
+		result.Start, result.End = 0, 0
+		result.Quote = string(text[:min(len(text), maxQuoteLen)])
+	} else {
+		result.Quote = string(text[input.Start:input.End])
+	}
+
+	return result, nil
+}
@@ -0,0 +1,285 @@
+// ****************************************************************************
+//
+// @file       ranking_service.go
+// @brief      按日、周、月统计的排行榜，每日计数保存在 Redis 有序集合中
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"lightnovel/internal/models"
+	"lightnovel/pkg/cache"
+	"lightnovel/pkg/errors"
+)
+
+// 排行榜类型
+const (
+	RankingReads     = "reads"     // 阅读榜
+	RankingFavorites = "favorites" // 收藏榜
+	RankingComments  = "comments"  // 评论榜
+	RankingRising    = "rising"    // 飙升榜，按阅读量相对上一个窗口的增长率排序
+)
+
+// 排行榜时间窗口
+const (
+	WindowDay   = "day"
+	WindowWeek  = "week"
+	WindowMonth = "month"
+)
+
+const (
+	rankingDayLayout = "20060102"
+	rankingRetention = 62 * 24 * time.Hour // 每日计数的保留时间，需覆盖飙升榜月榜的两个窗口
+	rankingCacheTTL  = time.Minute
+	risingSmoothing  = 10 // 飙升榜增长率的平滑值，避免阅读量很少的小说增长率过高
+)
+
+// rankingWindows 各时间窗口包含的天数
+var rankingWindows = map[string]int{
+	WindowDay:   1,
+	WindowWeek:  7,
+	WindowMonth: 30,
+}
+
+// countedBoards 直接计数的排行榜，飙升榜由阅读榜计算得到
+var countedBoards = []string{RankingReads, RankingFavorites, RankingComments}
+
+// recordRanking 为小说在当天的排行榜中加一，失败时只记录日志
+func (s *NovelService) recordRanking(ctx context.Context, board, novelID string) {
+	key := rankingDayKey(board, time.Now().Format(rankingDayLayout))
+	if err := s.cache.ZIncrBy(ctx, key, novelID, 1, rankingRetention); err != nil {
+		log.Printf("Failed to record %s ranking for novel %s: %v", board, novelID, err)
+	}
+}
+
+// GetRankings 获取排行榜
+func (s *NovelService) GetRankings(ctx context.Context, board, window string, limit int) ([]models.RankedNovel, error) {
+	days, ok := rankingWindows[window]
+	if !ok {
+		return nil, errors.NewErrorWithMessage(errors.ErrInvalidParameter, "window取值为day、week或month")
+	}
+	switch board {
+	case RankingReads, RankingFavorites, RankingComments, RankingRising:
+	default:
+		return nil, errors.NewErrorWithMessage(errors.ErrInvalidParameter, "board取值为reads、favorites、comments或rising")
+	}
+
+	cacheKey := fmt.Sprintf("%sresult:%s:%s:%d", cache.RankingKey, board, window, limit)
+	var result []models.RankedNovel
+	if err := s.cache.Get(ctx, cacheKey, &result); err == nil && len(result) > 0 {
+		return result, nil
+	}
+
+	now := time.Now()
+	var scores []redis.Z
+	var err error
+	if board == RankingRising {
+		scores, err = s.risingScores(ctx, now, days)
+	} else {
+		scores, err = s.windowScores(ctx, board, now, 0, days, int64(limit)-1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(scores) > limit {
+		scores = scores[:limit]
+	}
+
+	ids := make([]string, 0, len(scores))
+	for _, z := range scores {
+		ids = append(ids, z.Member.(string))
+	}
+	novels, err := s.GetNovelsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result = make([]models.RankedNovel, 0, len(scores))
+	for _, z := range scores {
+		novel, ok := novels[z.Member.(string)]
+		if !ok {
+			continue
+		}
+		result = append(result, models.RankedNovel{Rank: len(result) + 1, Novel: novel, Score: z.Score})
+	}
+
+	s.cache.Set(ctx, cacheKey, result, rankingCacheTTL)
+	return result, nil
+}
+
+// RunRankingJob 启动时从数据库恢复 Redis 中缺失的每日计数，之后按配置的间隔持久化当天和前一天的计数
+func (s *NovelService) RunRankingJob(ctx context.Context) {
+	if err := s.restoreRankings(ctx); err != nil {
+		log.Printf("Failed to restore rankings: %v", err)
+	}
+
+	ticker := time.NewTicker(s.cfg.Jobs.Ranking)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.persistRankings(ctx); err != nil {
+				log.Printf("Failed to persist rankings: %v", err)
+			}
+		}
+	}
+}
+
+// persistRankings 将当天和前一天的计数写入数据库，并清理超出保留时间的计数
+func (s *NovelService) persistRankings(ctx context.Context) error {
+	now := time.Now()
+
+	var writes []mongo.WriteModel
+	for _, day := range []string{now.Format(rankingDayLayout), now.AddDate(0, 0, -1).Format(rankingDayLayout)} {
+		for _, board := range countedBoards {
+			scores, err := s.cache.ZRevRange(ctx, rankingDayKey(board, day), 0, -1)
+			if err != nil {
+				return err
+			}
+			for _, z := range scores {
+				writes = append(writes, mongo.NewUpdateOneModel().
+					SetFilter(bson.M{"board": board, "day": day, "novelId": z.Member}).
+					SetUpdate(bson.M{"$set": bson.M{"score": z.Score}}).
+					SetUpsert(true))
+			}
+		}
+	}
+	if err := s.bulkWrite(ctx, "rank_daily", writes); err != nil {
+		return err
+	}
+
+	cutoff := now.Add(-rankingRetention).Format(rankingDayLayout)
+	_, err := s.db.GetCollection("rank_daily").DeleteMany(ctx, bson.M{"day": bson.M{"$lt": cutoff}})
+	return err
+}
+
+// restoreRankings 将数据库中保留期内、但 Redis 中已不存在的每日计数写回 Redis
+func (s *NovelService) restoreRankings(ctx context.Context) error {
+	now := time.Now()
+	cutoff := now.Add(-rankingRetention).Format(rankingDayLayout)
+
+	var counts []models.RankingCount
+	if err := s.findAll(ctx, "rank_daily", bson.M{"day": bson.M{"$gte": cutoff}}, &counts); err != nil {
+		return err
+	}
+
+	type daySet struct {
+		day     string
+		members []redis.Z
+	}
+	sets := make(map[string]*daySet)
+	for _, count := range counts {
+		key := rankingDayKey(count.Board, count.Day)
+		if sets[key] == nil {
+			sets[key] = &daySet{day: count.Day}
+		}
+		sets[key].members = append(sets[key].members, redis.Z{Member: count.NovelID, Score: count.Score})
+	}
+
+	restored := 0
+	for key, set := range sets {
+		exists, err := s.cache.Exists(ctx, key)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		day, _ := time.ParseInLocation(rankingDayLayout, set.day, time.Local)
+		if err := s.cache.ZAdd(ctx, key, set.members, rankingRetention-now.Sub(day)); err != nil {
+			return err
+		}
+		restored++
+	}
+
+	if restored > 0 {
+		log.Printf("Restored %d daily ranking sets from database", restored)
+	}
+	return nil
+}
+
+// windowScores 合并从now往前第offset天起连续days天的计数，按分数降序返回前stop+1个，stop为-1时返回全部
+func (s *NovelService) windowScores(ctx context.Context, board string, now time.Time, offset, days int, stop int64) ([]redis.Z, error) {
+	keys := make([]string, 0, days)
+	for i := offset; i < offset+days; i++ {
+		keys = append(keys, rankingDayKey(board, now.AddDate(0, 0, -i).Format(rankingDayLayout)))
+	}
+
+	dest := fmt.Sprintf("%sunion:%s:%s:%d:%d", cache.RankingKey, board, now.Format(rankingDayLayout), offset, days)
+	if err := s.cache.ZUnionStore(ctx, dest, keys, rankingCacheTTL); err != nil {
+		return nil, err
+	}
+	return s.cache.ZRevRange(ctx, dest, 0, stop)
+}
+
+// risingScores 计算阅读量相对上一个等长窗口的增长率：(本期 - 上期) / (上期 + 平滑值)，只保留增长的小说
+func (s *NovelService) risingScores(ctx context.Context, now time.Time, days int) ([]redis.Z, error) {
+	current, err := s.windowScores(ctx, RankingReads, now, 0, days, -1)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.windowScores(ctx, RankingReads, now, days, days, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	before := make(map[string]float64, len(previous))
+	for _, z := range previous {
+		before[z.Member.(string)] = z.Score
+	}
+
+	rising := make([]redis.Z, 0, len(current))
+	for _, z := range current {
+		prev := before[z.Member.(string)]
+		if z.Score > prev {
+			rising = append(rising, redis.Z{Member: z.Member, Score: (z.Score - prev) / (prev + risingSmoothing)})
+		}
+	}
+	sort.Slice(rising, func(i, j int) bool {
+		if rising[i].Score != rising[j].Score {
+			return rising[i].Score > rising[j].Score
+		}
+		return rising[i].Member.(string) < rising[j].Member.(string)
+	})
+	return rising, nil
+}
+
+// removeFromRankings 从保留期内各排行榜的每日计数中删除小说
+func (s *NovelService) removeFromRankings(ctx context.Context, novelID string) error {
+	now := time.Now()
+	days := int(rankingRetention / (24 * time.Hour))
+	keys := make([]string, 0, days*len(countedBoards))
+	for _, board := range countedBoards {
+		for i := 0; i <= days; i++ {
+			keys = append(keys, rankingDayKey(board, now.AddDate(0, 0, -i).Format(rankingDayLayout)))
+		}
+	}
+	if err := s.cache.ZRem(ctx, keys, novelID); err != nil {
+		return err
+	}
+	s.cache.DeleteByPattern(ctx, cache.RankingKey+"result:*")
+	return nil
+}
+
+// rankingDayKey 排行榜某一天计数的键
+func rankingDayKey(board, day string) string {
+	return fmt.Sprintf("%s%s:%s", cache.RankingKey, board, day)
+}
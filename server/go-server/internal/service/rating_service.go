@@ -0,0 +1,342 @@
+// ****************************************************************************
+//
+// @file       rating_service.go
+// @brief      小说评分、书评与评分榜
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"lightnovel/internal/models"
+	"lightnovel/pkg/cache"
+	"lightnovel/pkg/errors"
+)
+
+// 评分范围
+const (
+	MinScore = 1
+	MaxScore = 10
+)
+
+// bayesianPriorWeight 贝叶斯平均的先验权重，相当于每部小说预先有这么多人打了全站平均分
+const bayesianPriorWeight = 10
+
+// RateNovel 评分并可附带书评，重复提交时覆盖之前的评分
+func (s *NovelService) RateNovel(ctx context.Context, deviceID, novelID string, score int, content string) (*models.Review, error) {
+	if score < MinScore || score > MaxScore {
+		return nil, errors.NewErrorWithMessage(errors.ErrInvalidParameter, fmt.Sprintf("评分范围为%d-%d", MinScore, MaxScore))
+	}
+	if _, err := s.GetNovelByID(ctx, novelID); err != nil {
+		return nil, err
+	}
+
+	// 书评同样过滤敏感词，书评不进入审核队列
+	if s.words.Contains(content) {
+		if s.cfg.Moderate.Action == ModerateReject {
+			return nil, errors.NewError(errors.ErrSensitiveContent)
+		}
+		content = s.words.Replace(content, '*')
+	}
+
+	now := time.Now()
+	var previous models.Review
+	err := s.db.GetCollection("reviews").FindOneAndUpdate(ctx,
+		bson.M{"novelId": novelID, "deviceId": deviceID},
+		bson.M{
+			"$set": bson.M{
+				"score":     score,
+				"content":   content,
+				"updatedAt": now,
+			},
+			"$setOnInsert": bson.M{
+				"_id":       primitive.NewObjectID(),
+				"createdAt": now,
+			},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before),
+	).Decode(&previous)
+
+	isNew := err == mongo.ErrNoDocuments
+	if err != nil && !isNew {
+		return nil, err
+	}
+
+	// 更新小说的评分统计
+	inc := bson.M{
+		"ratingSum":                    score,
+		"histogram." + scoreKey(score): 1,
+	}
+	if isNew {
+		inc["ratingCount"] = 1
+	} else if previous.Score != score {
+		inc["ratingSum"] = score - previous.Score
+		inc["histogram."+scoreKey(previous.Score)] = -1
+	} else {
+		inc = nil
+	}
+	if inc != nil {
+		if err := s.updateRating(ctx, novelID, inc); err != nil {
+			return nil, err
+		}
+	}
+
+	var review models.Review
+	err = s.db.GetCollection("reviews").FindOne(ctx, bson.M{"novelId": novelID, "deviceId": deviceID}).Decode(&review)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.DeleteByPattern(ctx, cache.ReviewListKey+novelID+":*")
+	return &review, nil
+}
+
+// DeleteRating 删除自己的评分和书评
+func (s *NovelService) DeleteRating(ctx context.Context, deviceID, novelID string) error {
+	var review models.Review
+	err := s.db.GetCollection("reviews").FindOneAndDelete(ctx, bson.M{
+		"novelId":  novelID,
+		"deviceId": deviceID,
+	}).Decode(&review)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.NewError(errors.ErrNotFound)
+		}
+		return err
+	}
+
+	if err := s.updateRating(ctx, novelID, reviewRemoval(review)); err != nil {
+		return err
+	}
+
+	s.cache.DeleteByPattern(ctx, cache.ReviewListKey+novelID+":*")
+	return nil
+}
+
+// GetMyRating 获取自己对小说的评分，未评分时返回nil
+func (s *NovelService) GetMyRating(ctx context.Context, deviceID, novelID string) (*models.Review, error) {
+	var review models.Review
+	err := s.db.GetCollection("reviews").FindOne(ctx, bson.M{
+		"novelId":  novelID,
+		"deviceId": deviceID,
+	}).Decode(&review)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// GetReviews 分页获取小说的书评，只返回有正文的评分，按更新时间倒序
+func (s *NovelService) GetReviews(ctx context.Context, novelID string, page, size int) ([]models.ReviewResponse, int64, error) {
+	cacheKey := fmt.Sprintf("%s%s:%d:%d", cache.ReviewListKey, novelID, page, size)
+	totalKey := fmt.Sprintf("%s%s:total", cache.ReviewListKey, novelID)
+
+	var reviews []models.ReviewResponse
+	if err := s.cache.Get(ctx, cacheKey, &reviews); err == nil && len(reviews) > 0 {
+		var total int64
+		s.cache.Get(ctx, totalKey, &total)
+		return reviews, total, nil
+	}
+
+	collection := s.db.GetCollection("reviews")
+	filter := bson.M{"novelId": novelID, "content": bson.M{"$ne": ""}}
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "updatedAt", Value: -1}}).
+		SetSkip(int64((page - 1) * size)).
+		SetLimit(int64(size))
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []models.Review
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, 0, err
+	}
+
+	// 获取用户信息
+	userCollection := s.db.GetCollection("users")
+	reviews = make([]models.ReviewResponse, 0, len(results))
+	for _, review := range results {
+		var user models.User
+		if err := userCollection.FindOne(ctx, bson.M{"_id": review.DeviceID}).Decode(&user); err != nil {
+			user.Name = "已删除用户"
+			user.Avatar = "/static/avatars/default.png"
+		}
+		reviews = append(reviews, models.ReviewResponse{
+			Review:     review,
+			UserName:   user.Name,
+			UserAvatar: user.Avatar,
+		})
+	}
+
+	s.cache.Set(ctx, cacheKey, reviews, s.cfg.Cache.Comment)
+	s.cache.Set(ctx, totalKey, total, s.cfg.Cache.Comment)
+
+	return reviews, total, nil
+}
+
+// GetTopRatedNovels 获取评分榜，按贝叶斯平均分排序，评分人数少的小说会被拉向全站平均分
+func (s *NovelService) GetTopRatedNovels(ctx context.Context, limit int) ([]models.RatedNovel, error) {
+	cacheKey := fmt.Sprintf("%s:%d", cache.TopRatedKey, limit)
+
+	var novels []models.RatedNovel
+	if err := s.cache.Get(ctx, cacheKey, &novels); err == nil && len(novels) > 0 {
+		return novels, nil
+	}
+
+	collection := s.db.GetCollection("novels")
+
+	// 全站平均分
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":   nil,
+			"sum":   bson.M{"$sum": "$ratingSum"},
+			"count": bson.M{"$sum": "$ratingCount"},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var totals []struct {
+		Sum   float64 `bson:"sum"`
+		Count float64 `bson:"count"`
+	}
+	if err = cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	if len(totals) == 0 || totals[0].Count == 0 {
+		return []models.RatedNovel{}, nil
+	}
+	mean := totals[0].Sum / totals[0].Count
+
+	// 贝叶斯平均 = (C*m + 评分总和) / (C + 评分人数)
+	cursor, err = collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"ratingCount": bson.M{"$gt": 0}}}},
+		{{Key: "$addFields", Value: bson.M{
+			"score": bson.M{"$divide": bson.A{
+				bson.M{"$add": bson.A{bayesianPriorWeight * mean, "$ratingSum"}},
+				bson.M{"$add": bson.A{bayesianPriorWeight, "$ratingCount"}},
+			}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "ratingCount", Value: -1}}}},
+		{{Key: "$limit", Value: int64(limit)}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	novels = []models.RatedNovel{}
+	if err = cursor.All(ctx, &novels); err != nil {
+		return nil, err
+	}
+
+	if err := s.cache.Set(ctx, cacheKey, novels, s.cfg.Cache.PopularNovels); err != nil {
+		log.Printf("Failed to cache top rated novels: %v", err)
+	}
+	return novels, nil
+}
+
+// migrateReviews 将设备的评分迁移到账号，账号已评过的小说保留账号的评分
+func (s *NovelService) migrateReviews(ctx context.Context, deviceID, userID string) error {
+	var reviews []models.Review
+	if err := s.findAll(ctx, "reviews", bson.M{"deviceId": deviceID}, &reviews); err != nil {
+		return err
+	}
+
+	for _, review := range reviews {
+		_, err := s.db.GetCollection("reviews").UpdateOne(ctx,
+			bson.M{"_id": review.ID},
+			bson.M{"$set": bson.M{"deviceId": userID}},
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			if _, err := s.db.GetCollection("reviews").DeleteOne(ctx, bson.M{"_id": review.ID}); err != nil {
+				return err
+			}
+			err = s.updateRating(ctx, review.NovelID, reviewRemoval(review))
+		}
+		if err != nil {
+			return err
+		}
+		s.cache.DeleteByPattern(ctx, cache.ReviewListKey+review.NovelID+":*")
+	}
+	return nil
+}
+
+// updateRating 更新小说的评分统计并重新计算平均分
+func (s *NovelService) updateRating(ctx context.Context, novelID string, inc bson.M) error {
+	objectID, err := primitive.ObjectIDFromHex(novelID)
+	if err != nil {
+		return errors.NewError(errors.ErrInvalidParameter)
+	}
+
+	var novel models.Novel
+	err = s.db.GetCollection("novels").FindOneAndUpdate(ctx,
+		bson.M{"_id": objectID},
+		bson.M{"$inc": inc},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&novel)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.NewError(errors.ErrNovelNotFound)
+		}
+		return err
+	}
+
+	var rating float64
+	if novel.RatingCount > 0 {
+		rating = float64(novel.RatingSum) / float64(novel.RatingCount)
+	}
+	_, err = s.db.GetCollection("novels").UpdateOne(ctx,
+		bson.M{"_id": objectID},
+		bson.M{"$set": bson.M{"rating": rating}},
+	)
+	if err != nil {
+		return err
+	}
+
+	s.cache.Delete(ctx, cache.NovelDetailKey+novelID)
+	s.cache.Delete(ctx, fmt.Sprintf("%s:%s", cache.NovelDetailKey, novelID))
+	s.cache.DeleteByPattern(ctx, cache.TopRatedKey+"*")
+	return nil
+}
+
+// reviewRemoval 删除一条评分时对统计的修改
+func reviewRemoval(review models.Review) bson.M {
+	return bson.M{
+		"ratingCount":                         -1,
+		"ratingSum":                           -review.Score,
+		"histogram." + scoreKey(review.Score): -1,
+	}
+}
+
+// scoreKey 评分分布中分数对应的键
+func scoreKey(score int) string {
+	return strconv.Itoa(score)
+}
@@ -0,0 +1,88 @@
+// ****************************************************************************
+//
+// @file       read_count_service.go
+// @brief      去重的阅读量统计，先在 Redis 中缓冲再批量写入数据库
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"lightnovel/pkg/cache"
+)
+
+// RecordRead 记录一次章节阅读，同一设备在窗口内重复阅读同一章节只计一次
+func (s *NovelService) RecordRead(ctx context.Context, deviceID, novelID string, volumeNumber, chapterNumber int) error {
+	key := fmt.Sprintf("%s%s:%s:%d:%d", cache.ReadDedupKey, deviceID, novelID, volumeNumber, chapterNumber)
+	first, err := s.cache.SetNX(ctx, key, s.cfg.Reads.Window)
+	if err != nil || !first {
+		return err
+	}
+
+	if err := s.cache.HIncrBy(ctx, cache.ReadPendingKey, novelID, 1); err != nil {
+		return err
+	}
+	if err := s.cache.PFAdd(ctx, cache.ReadersKey+novelID, deviceID); err != nil {
+		return err
+	}
+
+	s.recordRanking(ctx, RankingReads, novelID)
+	return nil
+}
+
+// RunReadCountJob 按配置的间隔将缓冲的阅读量写入数据库，直到ctx结束
+func (s *NovelService) RunReadCountJob(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Jobs.ReadFlush)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.FlushReadCounts(ctx); err != nil {
+				log.Printf("Failed to flush read counts: %v", err)
+			}
+		}
+	}
+}
+
+// FlushReadCounts 取出 Redis 中缓冲的阅读量写入数据库，写入失败时放回缓冲
+func (s *NovelService) FlushReadCounts(ctx context.Context) error {
+	pending, err := s.cache.HDrain(ctx, cache.ReadPendingKey)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	reads := make(map[string]int64, len(pending))
+	uniqueReaders := make(map[string]int64, len(pending))
+	for novelID, value := range pending {
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || count <= 0 {
+			continue
+		}
+		reads[novelID] = count
+
+		uniqueReaders[novelID], err = s.cache.PFCount(ctx, cache.ReadersKey+novelID)
+		if err != nil {
+			log.Printf("Failed to count unique readers of novel %s: %v", novelID, err)
+		}
+	}
+
+	if err := s.IncrementNovelReadCountBatch(ctx, reads, uniqueReaders); err != nil {
+		for novelID, count := range reads {
+			s.cache.HIncrBy(ctx, cache.ReadPendingKey, novelID, count)
+		}
+		return err
+	}
+	return nil
+}
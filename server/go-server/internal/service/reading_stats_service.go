@@ -0,0 +1,265 @@
+// ****************************************************************************
+//
+// @file       reading_stats_service.go
+// @brief      阅读会话记录与用户阅读统计
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"lightnovel/internal/models"
+	"lightnovel/pkg/cache"
+	"lightnovel/pkg/errors"
+)
+
+const (
+	statDayLayout      = "2006-01-02"
+	maxSessionDuration = 2 * time.Hour // 单次会话的最长计时，避免忘记关闭页面时时长过长
+	heatmapDays        = 365
+)
+
+// StartReadingSession 打开章节时开始一次阅读会话
+func (s *NovelService) StartReadingSession(ctx context.Context, deviceID, novelID string, volumeNumber, chapterNumber int) (*models.ReadingSession, error) {
+	if _, err := s.GetChapterByNumber(ctx, novelID, volumeNumber, chapterNumber); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := models.ReadingSession{
+		ID:            primitive.NewObjectID(),
+		DeviceID:      deviceID,
+		NovelID:       novelID,
+		VolumeNumber:  volumeNumber,
+		ChapterNumber: chapterNumber,
+		Day:           now.Format(statDayLayout),
+		StartedAt:     now,
+	}
+	if _, err := s.db.GetCollection("read_sessions").InsertOne(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// EndReadingSession 离开章节时结束阅读会话，characters为nil时视为读完整章，超过章节字数的按章节字数计
+func (s *NovelService) EndReadingSession(ctx context.Context, deviceID, sessionID string, characters *int) (*models.ReadingSession, error) {
+	id, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return nil, errors.NewError(errors.ErrInvalidParameter)
+	}
+
+	collection := s.db.GetCollection("read_sessions")
+	var session models.ReadingSession
+	err = collection.FindOne(ctx, bson.M{"_id": id, "deviceId": deviceID}).Decode(&session)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.NewError(errors.ErrNotFound)
+		}
+		return nil, err
+	}
+	if session.EndedAt != nil {
+		return nil, errors.NewErrorWithMessage(errors.ErrInvalidParameter, "阅读会话已结束")
+	}
+
+	chapter, err := s.GetChapterByNumber(ctx, session.NovelID, session.VolumeNumber, session.ChapterNumber)
+	if err != nil {
+		return nil, err
+	}
+	chapterChars := utf8.RuneCountInString(chapter.Content)
+	read := chapterChars
+	if characters != nil {
+		read = max(0, min(*characters, chapterChars))
+	}
+
+	now := time.Now()
+	duration := min(now.Sub(session.StartedAt), maxSessionDuration)
+
+	// 只结束尚未结束的会话，避免重复提交时计两次
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "endedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{
+			"endedAt":    now,
+			"duration":   int64(duration.Seconds()),
+			"characters": read,
+		}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if result.ModifiedCount == 0 {
+		return nil, errors.NewErrorWithMessage(errors.ErrInvalidParameter, "阅读会话已结束")
+	}
+
+	session.EndedAt = &now
+	session.Duration = int64(duration.Seconds())
+	session.Characters = read
+
+	s.cache.Delete(ctx, cache.ReadingStatKey+deviceID)
+	return &session, nil
+}
+
+// GetReadingStats 获取用户的阅读统计
+func (s *NovelService) GetReadingStats(ctx context.Context, deviceID string) (*models.ReadingStats, error) {
+	cacheKey := cache.ReadingStatKey + deviceID
+
+	var stats models.ReadingStats
+	if err := s.cache.Get(ctx, cacheKey, &stats); err == nil {
+		return &stats, nil
+	}
+
+	collection := s.db.GetCollection("read_sessions")
+	ended := bson.M{"deviceId": deviceID, "endedAt": bson.M{"$exists": true}}
+
+	// 按天汇总
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: ended}},
+		{{Key: "$group", Value: bson.M{
+			"_id":        "$day",
+			"seconds":    bson.M{"$sum": "$duration"},
+			"characters": bson.M{"$sum": "$characters"},
+			"sessions":   bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var days []models.ReadingDay
+	if err = cursor.All(ctx, &days); err != nil {
+		return nil, err
+	}
+
+	// 读过的不同章节数
+	cursor, err = collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: ended}},
+		{{Key: "$group", Value: bson.M{"_id": bson.M{
+			"novelId":       "$novelId",
+			"volumeNumber":  "$volumeNumber",
+			"chapterNumber": "$chapterNumber",
+		}}}},
+		{{Key: "$count", Value: "chapters"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var chapters []struct {
+		Chapters int64 `bson:"chapters"`
+	}
+	if err = cursor.All(ctx, &chapters); err != nil {
+		return nil, err
+	}
+
+	stats = models.ReadingStats{Heatmap: []models.ReadingDay{}}
+	if len(chapters) > 0 {
+		stats.ChaptersRead = chapters[0].Chapters
+	}
+
+	since := time.Now().AddDate(0, 0, -heatmapDays+1).Format(statDayLayout)
+	for _, day := range days {
+		stats.TotalSeconds += day.Seconds
+		stats.Characters += day.Characters
+		stats.Sessions += day.Sessions
+		if day.Day >= since {
+			stats.Heatmap = append(stats.Heatmap, day)
+		}
+	}
+	stats.CurrentStreak, stats.LongestStreak = readingStreaks(days, time.Now())
+
+	stats.Novels, err = s.novelCompletions(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.Set(ctx, cacheKey, stats, s.cfg.Cache.ReadingStat)
+	return &stats, nil
+}
+
+// novelCompletions 根据阅读进度计算各小说的完成度
+func (s *NovelService) novelCompletions(ctx context.Context, deviceID string) ([]models.NovelCompletion, error) {
+	var progresses []models.ReadProgress
+	if err := s.findAll(ctx, "read_progress", bson.M{"deviceId": deviceID}, &progresses); err != nil {
+		return nil, err
+	}
+	sort.Slice(progresses, func(i, j int) bool {
+		return progresses[i].UpdatedAt.After(progresses[j].UpdatedAt)
+	})
+
+	ids := make([]string, 0, len(progresses))
+	for _, progress := range progresses {
+		ids = append(ids, progress.NovelID)
+	}
+	novels, err := s.GetNovelsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	outlines, err := s.chapterOutlines(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	completions := make([]models.NovelCompletion, 0, len(progresses))
+	for _, progress := range progresses {
+		novel, ok := novels[progress.NovelID]
+		if !ok {
+			continue
+		}
+
+		chapters := outlines[progress.NovelID]
+		done := int64(chaptersUpTo(chapters, progress.VolumeNumber, progress.ChapterNumber))
+		total := int64(len(chapters))
+		completions = append(completions, models.NovelCompletion{
+			NovelID:       progress.NovelID,
+			Title:         novel.Title,
+			VolumeNumber:  progress.VolumeNumber,
+			ChapterNumber: progress.ChapterNumber,
+			ChaptersDone:  done,
+			TotalChapters: total,
+			Percent:       completionPercent(done, total),
+		})
+	}
+
+	return completions, nil
+}
+
+// readingStreaks 根据按日期升序排列的阅读日计算当前和最长连续阅读天数，今天还没读时从昨天算起
+func readingStreaks(days []models.ReadingDay, now time.Time) (current, longest int) {
+	var prev time.Time
+	run := 0
+	for _, d := range days {
+		day, err := time.ParseInLocation(statDayLayout, d.Day, time.Local)
+		if err != nil {
+			continue
+		}
+		if run > 0 && day.Equal(prev.AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+		}
+		longest = max(longest, run)
+		prev = day
+	}
+
+	if run == 0 {
+		return 0, longest
+	}
+	today := now.Format(statDayLayout)
+	yesterday := now.AddDate(0, 0, -1).Format(statDayLayout)
+	if last := prev.Format(statDayLayout); last == today || last == yesterday {
+		current = run
+	}
+	return current, longest
+}
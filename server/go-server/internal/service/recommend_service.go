@@ -0,0 +1,514 @@
+// ****************************************************************************
+//
+// @file       recommend_service.go
+// @brief      基于阅读历史、收藏和标签的个性化推荐
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"lightnovel/internal/models"
+	"lightnovel/pkg/cache"
+)
+
+const (
+	maxNeighbors       = 20  // 每部小说保留的相似小说数
+	maxRecommendations = 50  // 每个用户保留的推荐数
+	maxProfileSeeds    = 200 // 计算共读时每个用户最多取的小说数
+	coReadWeight       = 0.7 // 共读相似度在推荐分中的权重
+	tagWeight          = 0.3 // 标签偏好在推荐分中的权重
+	favoriteWeight     = 1.0 // 收藏额外增加的偏好权重
+	historyHalfLife    = 30  // 阅读历史的权重随天数衰减，30天时减半
+	maxCandidates      = 500 // 在线计算时按标签最多加载的候选小说数
+)
+
+// catalogNovel 推荐计算用到的小说信息
+type catalogNovel struct {
+	ID        string
+	Title     string
+	Author    string
+	Tags      []string
+	ReadCount int64
+}
+
+// RunRecommendJob 按配置的间隔重新计算共读相似度、相关小说和所有用户的推荐，直到ctx结束
+func (s *NovelService) RunRecommendJob(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Jobs.Recommend)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RefreshRecommendations(ctx); err != nil {
+			log.Printf("Failed to refresh recommendations: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RefreshRecommendations 重新计算共读相似度、相关小说和所有用户的推荐
+func (s *NovelService) RefreshRecommendations(ctx context.Context) error {
+	start := time.Now()
+
+	catalog, err := s.loadCatalog(ctx, bson.M{}, 0)
+	if err != nil {
+		return err
+	}
+	profiles, err := s.loadProfiles(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+
+	// 保存共读相似度
+	similarities := coReadSimilarity(profiles)
+	writes := make([]mongo.WriteModel, 0, len(similarities))
+	for novelID, neighbors := range similarities {
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": novelID}).
+			SetReplacement(bson.M{"neighbors": neighbors, "updatedAt": start}).
+			SetUpsert(true))
+	}
+	if err := s.bulkWrite(ctx, "similarities", writes); err != nil {
+		return err
+	}
+	if _, err := s.db.GetCollection("similarities").DeleteMany(ctx, bson.M{"updatedAt": bson.M{"$lt": start}}); err != nil {
+		return err
+	}
+	if err := s.refreshRelated(ctx, catalog, similarities, start); err != nil {
+		return err
+	}
+
+	// 保存每个用户的推荐
+	writes = make([]mongo.WriteModel, 0, len(profiles))
+	for deviceID, seeds := range profiles {
+		items := recommend(seeds, similarities, catalog)
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": deviceID}).
+			SetReplacement(bson.M{"items": items, "updatedAt": start}).
+			SetUpsert(true))
+	}
+	if err := s.bulkWrite(ctx, "user_recs", writes); err != nil {
+		return err
+	}
+	if _, err := s.db.GetCollection("user_recs").DeleteMany(ctx, bson.M{"updatedAt": bson.M{"$lt": start}}); err != nil {
+		return err
+	}
+
+	s.cache.DeleteByPattern(ctx, cache.RecommendKey+"*")
+	log.Printf("Refreshed recommendations for %d readers and %d novels in %v", len(profiles), len(similarities), time.Since(start))
+	return nil
+}
+
+// GetRecommendations 获取个性化推荐，优先使用后台任务的结果，没有阅读记录时按热度推荐
+func (s *NovelService) GetRecommendations(ctx context.Context, deviceID string, limit int) ([]models.RecommendedNovel, error) {
+	cacheKey := fmt.Sprintf("%s%s:%d", cache.RecommendKey, deviceID, limit)
+
+	// 空结果同样缓存，避免没有可推荐小说时每次请求都重新计算
+	var result []models.RecommendedNovel
+	if err := s.cache.Get(ctx, cacheKey, &result); err == nil {
+		return result, nil
+	}
+
+	var stored models.UserRecommendations
+	err := s.db.GetCollection("user_recs").FindOne(ctx, bson.M{"_id": deviceID}).Decode(&stored)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	computed := err == nil
+
+	items := stored.Items
+	profiles, err := s.loadProfiles(ctx, bson.M{"deviceId": deviceID})
+	if err != nil {
+		return nil, err
+	}
+	seeds := profiles[deviceID]
+
+	// 新用户尚未被后台任务计算时，用已有的相似度在线计算
+	if !computed && len(seeds) > 0 {
+		similarities, err := s.loadSimilarities(ctx, seeds)
+		if err != nil {
+			return nil, err
+		}
+		catalog, err := s.loadCandidates(ctx, seeds, similarities)
+		if err != nil {
+			return nil, err
+		}
+		items = recommend(seeds, similarities, catalog)
+	}
+
+	// 推荐不足时用热门小说补齐，冷启动用户全部为热门
+	if len(items) < limit {
+		popular, err := s.loadCatalog(ctx, bson.M{}, int64(limit+len(items)+len(seeds)))
+		if err != nil {
+			return nil, err
+		}
+		items = padWithPopular(items, seeds, popular, limit)
+	}
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.NovelID)
+	}
+	novels, err := s.GetNovelsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result = make([]models.RecommendedNovel, 0, len(items))
+	for _, item := range items {
+		novel, ok := novels[item.NovelID]
+		if !ok {
+			continue
+		}
+		result = append(result, models.RecommendedNovel{
+			Novel:     novel,
+			Score:     item.Score,
+			Reason:    item.Reason,
+			BecauseOf: item.BecauseOf,
+		})
+	}
+
+	s.cache.Set(ctx, cacheKey, result, s.cfg.Jobs.Recommend)
+	return result, nil
+}
+
+// loadCandidates 加载在线推荐需要的小说：已读小说、共读相似的小说和标签相同的热门小说
+func (s *NovelService) loadCandidates(ctx context.Context, seeds map[string]float64, similarities map[string][]models.SimilarNovel) (map[string]*catalogNovel, error) {
+	ids := make([]string, 0, len(seeds))
+	for id := range seeds {
+		ids = append(ids, id)
+	}
+	for _, neighbors := range similarities {
+		for _, neighbor := range neighbors {
+			ids = append(ids, neighbor.NovelID)
+		}
+	}
+	catalog, err := s.loadCatalog(ctx, bson.M{"_id": bson.M{"$in": objectIDs(ids)}}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tagSet := make(map[string]bool)
+	for id := range seeds {
+		if novel, ok := catalog[id]; ok {
+			for _, tag := range novel.Tags {
+				tagSet[tag] = true
+			}
+		}
+	}
+	if len(tagSet) == 0 {
+		return catalog, nil
+	}
+
+	tags := make([]string, 0, len(tagSet))
+	for tag := range tagSet {
+		tags = append(tags, tag)
+	}
+	tagged, err := s.loadCatalog(ctx, bson.M{"tags": bson.M{"$in": tags}}, maxCandidates)
+	if err != nil {
+		return nil, err
+	}
+	for id, novel := range tagged {
+		catalog[id] = novel
+	}
+	return catalog, nil
+}
+
+// loadCatalog 加载小说的标题、作者、标签和阅读量，limit大于0时只取阅读量最高的limit部
+func (s *NovelService) loadCatalog(ctx context.Context, filter bson.M, limit int64) (map[string]*catalogNovel, error) {
+	opts := options.Find().SetProjection(bson.M{"title": 1, "author": 1, "tags": 1, "readCount": 1})
+	if limit > 0 {
+		opts.SetSort(bson.D{{Key: "readCount", Value: -1}, {Key: "_id", Value: 1}}).SetLimit(limit)
+	}
+	cursor, err := s.db.GetCollection("novels").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	catalog := make(map[string]*catalogNovel)
+	for cursor.Next(ctx) {
+		var novel models.Novel
+		if err := cursor.Decode(&novel); err != nil {
+			return nil, err
+		}
+		id := novel.ID.Hex()
+		catalog[id] = &catalogNovel{ID: id, Title: novel.Title, Author: novel.Author, Tags: novel.Tags, ReadCount: novel.ReadCount}
+	}
+	return catalog, cursor.Err()
+}
+
+// objectIDs 将小说ID转换为ObjectID，忽略格式错误的ID
+func objectIDs(ids []string) []primitive.ObjectID {
+	result := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if objectID, err := primitive.ObjectIDFromHex(id); err == nil {
+			result = append(result, objectID)
+		}
+	}
+	return result
+}
+
+// loadProfiles 根据阅读历史和收藏计算用户对小说的偏好权重
+func (s *NovelService) loadProfiles(ctx context.Context, filter bson.M) (map[string]map[string]float64, error) {
+	profiles := make(map[string]map[string]float64)
+	add := func(deviceID, novelID string, weight float64) {
+		if profiles[deviceID] == nil {
+			profiles[deviceID] = make(map[string]float64)
+		}
+		profiles[deviceID][novelID] += weight
+	}
+
+	var histories []models.ReadHistory
+	if err := s.findAll(ctx, "read_history", filter, &histories); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, history := range histories {
+		days := now.Sub(history.LastRead).Hours() / 24
+		add(history.DeviceID, history.NovelID, 1/(1+math.Max(days, 0)/historyHalfLife))
+	}
+
+	var favorites []models.Favorite
+	if err := s.findAll(ctx, "favorites", filter, &favorites); err != nil {
+		return nil, err
+	}
+	for _, favorite := range favorites {
+		add(favorite.DeviceID, favorite.NovelID, favoriteWeight)
+	}
+
+	return profiles, nil
+}
+
+// loadSimilarities 加载指定小说的共读相似小说
+func (s *NovelService) loadSimilarities(ctx context.Context, seeds map[string]float64) (map[string][]models.SimilarNovel, error) {
+	ids := make([]string, 0, len(seeds))
+	for id := range seeds {
+		ids = append(ids, id)
+	}
+
+	var docs []models.NovelSimilarity
+	if err := s.findAll(ctx, "similarities", bson.M{"_id": bson.M{"$in": ids}}, &docs); err != nil {
+		return nil, err
+	}
+
+	similarities := make(map[string][]models.SimilarNovel, len(docs))
+	for _, doc := range docs {
+		similarities[doc.NovelID] = doc.Neighbors
+	}
+	return similarities, nil
+}
+
+// bulkWrite 分批执行批量写入
+func (s *NovelService) bulkWrite(ctx context.Context, collection string, writes []mongo.WriteModel) error {
+	const batchSize = 1000
+	for start := 0; start < len(writes); start += batchSize {
+		end := min(start+batchSize, len(writes))
+		_, err := s.db.GetCollection(collection).BulkWrite(ctx, writes[start:end], options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// coReadSimilarity 计算小说间的共读余弦相似度：共同读者数 / sqrt(读者数A * 读者数B)
+func coReadSimilarity(profiles map[string]map[string]float64) map[string][]models.SimilarNovel {
+	readers := make(map[string]int)
+	coReads := make(map[string]map[string]int)
+
+	for _, seeds := range profiles {
+		ids := topSeeds(seeds, maxProfileSeeds)
+		for i, a := range ids {
+			readers[a]++
+			for _, b := range ids[i+1:] {
+				if coReads[a] == nil {
+					coReads[a] = make(map[string]int)
+				}
+				if coReads[b] == nil {
+					coReads[b] = make(map[string]int)
+				}
+				coReads[a][b]++
+				coReads[b][a]++
+			}
+		}
+	}
+
+	similarities := make(map[string][]models.SimilarNovel, len(coReads))
+	for a, others := range coReads {
+		neighbors := make([]models.SimilarNovel, 0, len(others))
+		for b, count := range others {
+			score := float64(count) / math.Sqrt(float64(readers[a]*readers[b]))
+			neighbors = append(neighbors, models.SimilarNovel{NovelID: b, Score: score})
+		}
+		sort.Slice(neighbors, func(i, j int) bool {
+			if neighbors[i].Score != neighbors[j].Score {
+				return neighbors[i].Score > neighbors[j].Score
+			}
+			return neighbors[i].NovelID < neighbors[j].NovelID
+		})
+		if len(neighbors) > maxNeighbors {
+			neighbors = neighbors[:maxNeighbors]
+		}
+		similarities[a] = neighbors
+	}
+
+	return similarities
+}
+
+// recommend 结合共读相似度和标签偏好为一个用户计算推荐
+func recommend(seeds map[string]float64, similarities map[string][]models.SimilarNovel, catalog map[string]*catalogNovel) []models.RecommendationItem {
+	var totalWeight float64
+	affinity := make(map[string]float64)
+	for novelID, weight := range seeds {
+		totalWeight += weight
+		if novel, ok := catalog[novelID]; ok {
+			for _, tag := range novel.Tags {
+				affinity[tag] += weight
+			}
+		}
+	}
+	if totalWeight == 0 {
+		return nil
+	}
+
+	// 共读得分，并记录贡献最大的已读小说作为推荐理由
+	coRead := make(map[string]float64)
+	because := make(map[string]string)
+	best := make(map[string]float64)
+	for seedID, weight := range seeds {
+		for _, neighbor := range similarities[seedID] {
+			if _, read := seeds[neighbor.NovelID]; read {
+				continue
+			}
+			contribution := weight * neighbor.Score
+			coRead[neighbor.NovelID] += contribution / totalWeight
+			if contribution > best[neighbor.NovelID] {
+				best[neighbor.NovelID] = contribution
+				because[neighbor.NovelID] = seedID
+			}
+		}
+	}
+
+	items := make([]models.RecommendationItem, 0)
+	for novelID, novel := range catalog {
+		if _, read := seeds[novelID]; read {
+			continue
+		}
+
+		// 标签得分，按标签数开方归一，避免标签多的小说占优
+		var tagScore, topAffinity float64
+		var topTag string
+		for _, tag := range novel.Tags {
+			tagScore += affinity[tag] / totalWeight
+			if affinity[tag] > topAffinity {
+				topAffinity, topTag = affinity[tag], tag
+			}
+		}
+		if len(novel.Tags) > 0 {
+			tagScore /= math.Sqrt(float64(len(novel.Tags)))
+		}
+
+		cf := coReadWeight * coRead[novelID]
+		tg := tagWeight * tagScore
+		if cf+tg <= 0 {
+			continue
+		}
+
+		item := models.RecommendationItem{NovelID: novelID, Score: cf + tg}
+		if seedID := because[novelID]; seedID != "" && cf >= tg && catalog[seedID] != nil {
+			item.Reason = fmt.Sprintf("因为你读过《%s》", catalog[seedID].Title)
+			item.BecauseOf = seedID
+		} else {
+			item.Reason = fmt.Sprintf("因为你喜欢「%s」", topTag)
+		}
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Score != items[j].Score {
+			return items[i].Score > items[j].Score
+		}
+		return items[i].NovelID < items[j].NovelID
+	})
+	if len(items) > maxRecommendations {
+		items = items[:maxRecommendations]
+	}
+	return items
+}
+
+// padWithPopular 用未读过的热门小说补齐推荐
+func padWithPopular(items []models.RecommendationItem, seeds map[string]float64, catalog map[string]*catalogNovel, limit int) []models.RecommendationItem {
+	if len(items) >= limit {
+		return items
+	}
+
+	exclude := make(map[string]bool, len(items)+len(seeds))
+	for _, item := range items {
+		exclude[item.NovelID] = true
+	}
+	for novelID := range seeds {
+		exclude[novelID] = true
+	}
+
+	popular := make([]*catalogNovel, 0, len(catalog))
+	for _, novel := range catalog {
+		if !exclude[novel.ID] {
+			popular = append(popular, novel)
+		}
+	}
+	sort.Slice(popular, func(i, j int) bool {
+		if popular[i].ReadCount != popular[j].ReadCount {
+			return popular[i].ReadCount > popular[j].ReadCount
+		}
+		return popular[i].ID < popular[j].ID
+	})
+
+	for _, novel := range popular {
+		if len(items) >= limit {
+			break
+		}
+		items = append(items, models.RecommendationItem{NovelID: novel.ID, Reason: "热门推荐"})
+	}
+	return items
+}
+
+// topSeeds 按偏好权重取前n部小说
+func topSeeds(seeds map[string]float64, n int) []string {
+	ids := make([]string, 0, len(seeds))
+	for id := range seeds {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if seeds[ids[i]] != seeds[ids[j]] {
+			return seeds[ids[i]] > seeds[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > n {
+		ids = ids[:n]
+	}
+	return ids
+}
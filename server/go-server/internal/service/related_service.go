@@ -0,0 +1,166 @@
+// ****************************************************************************
+//
+// @file       related_service.go
+// @brief      "读过这本的人也在读"相关小说
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"lightnovel/internal/models"
+	"lightnovel/pkg/cache"
+)
+
+const (
+	maxRelated         = 20   // 每部小说保留的相关小说数
+	relatedCoReadShare = 0.6  // 共读相似度在相关度中的权重
+	relatedTagShare    = 0.25 // 标签重合度在相关度中的权重
+	relatedAuthorShare = 0.15 // 同一作者在相关度中的权重
+)
+
+// GetRelatedNovels 获取与小说相关的小说，未被后台任务计算过的小说按标签和作者在线计算
+func (s *NovelService) GetRelatedNovels(ctx context.Context, novelID string, limit int) ([]models.RelatedNovel, error) {
+	cacheKey := fmt.Sprintf("%s%s:%d", cache.RelatedKey, novelID, limit)
+
+	var result []models.RelatedNovel
+	if err := s.cache.Get(ctx, cacheKey, &result); err == nil {
+		return result, nil
+	}
+
+	novel, err := s.GetNovelByID(ctx, novelID)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored models.NovelSimilarity
+	err = s.db.GetCollection("related").FindOne(ctx, bson.M{"_id": novelID}).Decode(&stored)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	related := stored.Neighbors
+	if err == mongo.ErrNoDocuments {
+		// 只加载标签相同或同一作者的小说作为候选
+		conditions := bson.A{bson.M{"_id": novel.ID}}
+		if len(novel.Tags) > 0 {
+			conditions = append(conditions, bson.M{"tags": bson.M{"$in": novel.Tags}})
+		}
+		if novel.Author != "" {
+			conditions = append(conditions, bson.M{"author": novel.Author})
+		}
+		catalog, err := s.loadCatalog(ctx, bson.M{"$or": conditions}, maxCandidates)
+		if err != nil {
+			return nil, err
+		}
+		catalog[novelID] = &catalogNovel{ID: novelID, Title: novel.Title, Author: novel.Author, Tags: novel.Tags, ReadCount: novel.ReadCount}
+		related = relatedNovels(novelID, catalog, nil)
+	}
+	if len(related) > limit {
+		related = related[:limit]
+	}
+
+	ids := make([]string, 0, len(related))
+	for _, item := range related {
+		ids = append(ids, item.NovelID)
+	}
+	novels, err := s.GetNovelsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result = make([]models.RelatedNovel, 0, len(related))
+	for _, item := range related {
+		if novel, ok := novels[item.NovelID]; ok {
+			result = append(result, models.RelatedNovel{Novel: novel, Score: item.Score})
+		}
+	}
+
+	s.cache.Set(ctx, cacheKey, result, s.cfg.Jobs.Recommend)
+	return result, nil
+}
+
+// refreshRelated 重新计算所有小说的相关小说
+func (s *NovelService) refreshRelated(ctx context.Context, catalog map[string]*catalogNovel, similarities map[string][]models.SimilarNovel, start time.Time) error {
+	writes := make([]mongo.WriteModel, 0, len(catalog))
+	for novelID := range catalog {
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": novelID}).
+			SetReplacement(bson.M{"neighbors": relatedNovels(novelID, catalog, similarities[novelID]), "updatedAt": start}).
+			SetUpsert(true))
+	}
+	if err := s.bulkWrite(ctx, "related", writes); err != nil {
+		return err
+	}
+	if _, err := s.db.GetCollection("related").DeleteMany(ctx, bson.M{"updatedAt": bson.M{"$lt": start}}); err != nil {
+		return err
+	}
+
+	s.cache.DeleteByPattern(ctx, cache.RelatedKey+"*")
+	return nil
+}
+
+// relatedNovels 综合共读相似度、标签重合度(Jaccard)和作者计算一部小说的相关小说
+func relatedNovels(novelID string, catalog map[string]*catalogNovel, coRead []models.SimilarNovel) []models.SimilarNovel {
+	novel, ok := catalog[novelID]
+	if !ok {
+		return []models.SimilarNovel{}
+	}
+
+	tags := make(map[string]bool, len(novel.Tags))
+	for _, tag := range novel.Tags {
+		tags[tag] = true
+	}
+
+	scores := make(map[string]float64)
+	for _, neighbor := range coRead {
+		if _, ok := catalog[neighbor.NovelID]; ok {
+			scores[neighbor.NovelID] += relatedCoReadShare * neighbor.Score
+		}
+	}
+
+	for id, other := range catalog {
+		if id == novelID {
+			continue
+		}
+
+		var shared int
+		for _, tag := range other.Tags {
+			if tags[tag] {
+				shared++
+			}
+		}
+		if union := len(tags) + len(other.Tags) - shared; shared > 0 && union > 0 {
+			scores[id] += relatedTagShare * float64(shared) / float64(union)
+		}
+		if novel.Author != "" && other.Author == novel.Author {
+			scores[id] += relatedAuthorShare
+		}
+	}
+
+	related := make([]models.SimilarNovel, 0, len(scores))
+	for id, score := range scores {
+		related = append(related, models.SimilarNovel{NovelID: id, Score: score})
+	}
+	sort.Slice(related, func(i, j int) bool {
+		if related[i].Score != related[j].Score {
+			return related[i].Score > related[j].Score
+		}
+		return related[i].NovelID < related[j].NovelID
+	})
+	if len(related) > maxRelated {
+		related = related[:maxRelated]
+	}
+	return related
+}
@@ -0,0 +1,348 @@
+// ****************************************************************************
+//
+// @file       search_service.go
+// @brief      小说全文搜索，基于text_search索引并对中日韩文本使用正则回退
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"lightnovel/internal/models"
+	"lightnovel/pkg/cache"
+	"lightnovel/pkg/errors"
+)
+
+const (
+	maxSearchKeywordLen = 64 // 关键词最大长度(字符数)
+	snippetRadius       = 30 // 高亮片段中命中位置两侧保留的字符数
+)
+
+// SearchOptions 小说搜索条件，Keyword为空时只按过滤条件查询
+type SearchOptions struct {
+	Keyword string
+	Tags    []string
+	Status  string
+	Author  string
+	Page    int
+	Size    int
+}
+
+// searchPage 搜索结果缓存
+type searchPage struct {
+	Items []models.NovelSearchResult `json:"items"`
+	Total int64                      `json:"total"`
+}
+
+// SearchNovels 搜索小说，按相关度排序并返回高亮片段
+func (s *NovelService) SearchNovels(ctx context.Context, opts SearchOptions) ([]models.NovelSearchResult, int64, error) {
+	opts.Keyword = strings.TrimSpace(opts.Keyword)
+	if utf8.RuneCountInString(opts.Keyword) > maxSearchKeywordLen {
+		return nil, 0, errors.NewErrorWithMessage(errors.ErrInvalidParameter, fmt.Sprintf("搜索关键词不能超过%d个字符", maxSearchKeywordLen))
+	}
+
+	// 尝试从缓存获取
+	cacheKey := searchCacheKey(opts)
+	var cached searchPage
+	if err := s.cache.Get(ctx, cacheKey, &cached); err == nil && cached.Items != nil {
+		return cached.Items, cached.Total, nil
+	}
+
+	var results []models.NovelSearchResult
+	var total int64
+	var err error
+	terms := []string{opts.Keyword}
+
+	// text索引按空白分词，中日韩文本无法命中子串，直接走正则
+	if opts.Keyword != "" && !containsCJK(opts.Keyword) {
+		results, total, err = s.textSearch(ctx, opts)
+		if err != nil {
+			return nil, 0, err
+		}
+		terms = textSearchTerms(opts.Keyword)
+	}
+
+	// 文本索引无结果时回退到转义后的正则匹配，支持单词片段
+	if total == 0 {
+		results, total, err = s.regexSearch(ctx, opts)
+		if err != nil {
+			return nil, 0, err
+		}
+		terms = []string{opts.Keyword}
+	}
+
+	if results == nil {
+		results = []models.NovelSearchResult{}
+	}
+	for i := range results {
+		results[i].Highlights = buildHighlights(&results[i].Novel, terms)
+	}
+
+	// 设置缓存
+	s.cache.Set(ctx, cacheKey, searchPage{Items: results, Total: total}, s.cfg.Cache.SearchResult)
+
+	return results, total, nil
+}
+
+// textSearch 使用$text查询，按textScore排序
+func (s *NovelService) textSearch(ctx context.Context, opts SearchOptions) ([]models.NovelSearchResult, int64, error) {
+	filter := searchFilter(opts)
+	filter["$text"] = bson.M{"$search": opts.Keyword}
+
+	collection := s.db.GetCollection("novels")
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil || total == 0 {
+		return nil, 0, err
+	}
+
+	findOpts := options.Find().
+		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetSort(bson.D{
+			{Key: "score", Value: bson.M{"$meta": "textScore"}},
+			{Key: "readCount", Value: -1},
+		}).
+		SetSkip(int64((opts.Page - 1) * opts.Size)).
+		SetLimit(int64(opts.Size))
+
+	cursor, err := collection.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []models.NovelSearchResult
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
+
+// regexSearch 使用转义后的正则做子串匹配，按命中字段加权计算相关度
+func (s *NovelService) regexSearch(ctx context.Context, opts SearchOptions) ([]models.NovelSearchResult, int64, error) {
+	match := searchFilter(opts)
+	score := bson.M{"$literal": 0}
+
+	if opts.Keyword != "" {
+		pattern := regexp.QuoteMeta(opts.Keyword)
+		regex := bson.M{"$regex": pattern, "$options": "i"}
+		match["$or"] = []bson.M{
+			{"title": regex},
+			{"author": regex},
+			{"description": regex},
+			{"tags": opts.Keyword},
+		}
+
+		regexScore := func(field string, p string, weight int) bson.M {
+			return bson.M{"$cond": bson.A{
+				bson.M{"$regexMatch": bson.M{
+					"input":   bson.M{"$ifNull": bson.A{"$" + field, ""}},
+					"regex":   p,
+					"options": "i",
+				}},
+				weight, 0,
+			}}
+		}
+		score = bson.M{"$add": bson.A{
+			regexScore("title", "^"+pattern+"$", 5),
+			regexScore("title", pattern, 3),
+			regexScore("author", pattern, 2),
+			regexScore("description", pattern, 1),
+			bson.M{"$cond": bson.A{
+				bson.M{"$in": bson.A{opts.Keyword, bson.M{"$ifNull": bson.A{"$tags", bson.A{}}}}},
+				2, 0,
+			}},
+		}}
+	}
+
+	collection := s.db.GetCollection("novels")
+	total, err := collection.CountDocuments(ctx, match)
+	if err != nil || total == 0 {
+		return nil, 0, err
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$addFields": bson.M{"score": score}},
+		bson.M{"$sort": bson.D{{Key: "score", Value: -1}, {Key: "readCount", Value: -1}, {Key: "_id", Value: 1}}},
+		bson.M{"$skip": int64((opts.Page - 1) * opts.Size)},
+		bson.M{"$limit": int64(opts.Size)},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []models.NovelSearchResult
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
+
+// searchFilter 构造标签、状态、作者过滤条件
+func searchFilter(opts SearchOptions) bson.M {
+	filter := bson.M{}
+	if len(opts.Tags) > 0 {
+		filter["tags"] = bson.M{"$all": opts.Tags}
+	}
+	if opts.Status != "" {
+		filter["status"] = opts.Status
+	}
+	if opts.Author != "" {
+		filter["author"] = bson.M{"$regex": "^" + regexp.QuoteMeta(opts.Author) + "$", "$options": "i"}
+	}
+	return filter
+}
+
+func searchCacheKey(opts SearchOptions) string {
+	tags := append([]string(nil), opts.Tags...)
+	sort.Strings(tags)
+	return fmt.Sprintf("%s%s|%s|%s|%s|%d|%d", cache.SearchKey,
+		strings.ToLower(opts.Keyword), strings.Join(tags, ","), opts.Status, strings.ToLower(opts.Author), opts.Page, opts.Size)
+}
+
+// containsCJK 判断是否包含中日韩字符
+func containsCJK(s string) bool {
+	for _, r := range s {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			return true
+		}
+	}
+	return false
+}
+
+// textSearchTerms 提取$text查询中用于高亮的词，忽略排除词
+func textSearchTerms(keyword string) []string {
+	var terms []string
+	for _, term := range strings.Fields(keyword) {
+		term = strings.Trim(term, `"`)
+		if term == "" || strings.HasPrefix(term, "-") {
+			continue
+		}
+		terms = append(terms, term)
+	}
+	return terms
+}
+
+// buildHighlights 为标题、作者、简介生成高亮片段
+func buildHighlights(novel *models.Novel, terms []string) []models.Highlight {
+	highlights := []models.Highlight{}
+	fields := []struct {
+		name  string
+		text  string
+		whole bool
+	}{
+		{"title", novel.Title, true},
+		{"author", novel.Author, true},
+		{"description", novel.Description, false},
+	}
+	for _, f := range fields {
+		if snippet := highlightText(f.text, terms, f.whole); snippet != "" {
+			highlights = append(highlights, models.Highlight{Field: f.name, Snippet: snippet})
+		}
+	}
+	return highlights
+}
+
+// highlightText 标记文本中所有命中的词，whole为false时只截取首个命中附近的片段
+func highlightText(text string, terms []string, whole bool) string {
+	src := []rune(text)
+	spans := matchSpans(src, terms)
+	if len(spans) == 0 {
+		return ""
+	}
+
+	start, end := 0, len(src)
+	if !whole {
+		start = max(0, spans[0][0]-snippetRadius)
+		end = min(len(src), spans[0][1]+snippetRadius)
+	}
+	return renderSnippet(src, spans, start, end)
+}
+
+// matchSpans 查找所有不区分大小写的命中区间(按字符计)，结果有序且互不重叠
+func matchSpans(src []rune, terms []string) [][2]int {
+	lower := make([]rune, len(src))
+	for i, r := range src {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	var spans [][2]int
+	for _, term := range terms {
+		t := []rune(strings.ToLower(term))
+		if len(t) == 0 {
+			continue
+		}
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if slices.Equal(lower[i:i+len(t)], t) {
+				spans = append(spans, [2]int{i, i + len(t)})
+				i += len(t) - 1
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	// 合并重叠区间
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	merged := spans[:1]
+	for _, sp := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if sp[0] <= last[1] {
+			if sp[1] > last[1] {
+				last[1] = sp[1]
+			}
+			continue
+		}
+		merged = append(merged, sp)
+	}
+	return merged
+}
+
+// renderSnippet 截取[start, end)范围的文本，并用<em></em>包裹其中的命中区间
+//
+// 客户端按HTML渲染片段，原文需转义，只有<em>标签是标记
+func renderSnippet(src []rune, spans [][2]int, start, end int) string {
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	pos := start
+	for _, sp := range spans {
+		if sp[1] <= start {
+			continue
+		}
+		if sp[0] >= end {
+			break
+		}
+		sb.WriteString(html.EscapeString(string(src[pos:max(sp[0], start)])))
+		sb.WriteString("<em>")
+		sb.WriteString(html.EscapeString(string(src[max(sp[0], start):min(sp[1], end)])))
+		sb.WriteString("</em>")
+		pos = min(sp[1], end)
+	}
+	sb.WriteString(html.EscapeString(string(src[pos:end])))
+	if end < len(src) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
@@ -0,0 +1,194 @@
+// ****************************************************************************
+//
+// @file       shelf_service.go
+// @brief      "继续阅读"书架：合并阅读历史、进度、收藏与小说信息
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"lightnovel/internal/models"
+)
+
+// chapterOutline 计算进度用到的章节信息
+type chapterOutline struct {
+	NovelID       string    `bson:"novelId"`
+	VolumeNumber  int       `bson:"volumeNumber"`
+	ChapterNumber int       `bson:"chapterNumber"`
+	Title         string    `bson:"title"`
+	CreatedAt     time.Time `bson:"createdAt"`
+}
+
+// GetShelf 获取书架，读到一半的小说按最后阅读时间排在前面，其次是未读的收藏，已读完且没有更新的排在最后
+func (s *NovelService) GetShelf(ctx context.Context, deviceID string) ([]models.ShelfItem, error) {
+	var histories []models.ReadHistory
+	if err := s.findAll(ctx, "read_history", bson.M{"deviceId": deviceID}, &histories); err != nil {
+		return nil, err
+	}
+	var progresses []models.ReadProgress
+	if err := s.findAll(ctx, "read_progress", bson.M{"deviceId": deviceID}, &progresses); err != nil {
+		return nil, err
+	}
+	var favorites []models.Favorite
+	if err := s.findAll(ctx, "favorites", bson.M{"deviceId": deviceID}, &favorites); err != nil {
+		return nil, err
+	}
+
+	items := make(map[string]*models.ShelfItem)
+	item := func(novelID string) *models.ShelfItem {
+		if items[novelID] == nil {
+			items[novelID] = &models.ShelfItem{}
+		}
+		return items[novelID]
+	}
+	favoritedAt := make(map[string]time.Time, len(favorites))
+	for _, favorite := range favorites {
+		item(favorite.NovelID).Favorite = true
+		favoritedAt[favorite.NovelID] = favorite.CreatedAt
+	}
+	for _, history := range histories {
+		lastRead := history.LastRead
+		item(history.NovelID).LastRead = &lastRead
+	}
+	for i := range progresses {
+		item(progresses[i].NovelID).Progress = &progresses[i]
+	}
+
+	ids := make([]string, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	novels, err := s.GetNovelsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	outlines, err := s.chapterOutlines(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	shelf := make([]models.ShelfItem, 0, len(items))
+	for id, entry := range items {
+		novel, ok := novels[id]
+		if !ok {
+			continue
+		}
+		entry.Novel = novel
+
+		chapters := outlines[id]
+		entry.TotalChapters = int64(len(chapters))
+		next := 0
+		if entry.Progress != nil {
+			next = chaptersUpTo(chapters, entry.Progress.VolumeNumber, entry.Progress.ChapterNumber)
+			entry.ChaptersDone = int64(next)
+			entry.Percent = completionPercent(entry.ChaptersDone, entry.TotalChapters)
+		}
+		if next < len(chapters) {
+			entry.NextChapter = &models.ShelfChapter{
+				VolumeNumber:  chapters[next].VolumeNumber,
+				ChapterNumber: chapters[next].ChapterNumber,
+				Title:         chapters[next].Title,
+			}
+		}
+		if entry.LastRead != nil {
+			for _, chapter := range chapters {
+				if chapter.CreatedAt.After(*entry.LastRead) {
+					entry.NewChapters++
+				}
+			}
+		}
+
+		shelf = append(shelf, *entry)
+	}
+
+	sort.Slice(shelf, func(i, j int) bool {
+		gi, gj := shelfGroup(&shelf[i]), shelfGroup(&shelf[j])
+		if gi != gj {
+			return gi < gj
+		}
+		ti, tj := shelfTime(&shelf[i], favoritedAt), shelfTime(&shelf[j], favoritedAt)
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return shelf[i].Novel.ID.Hex() < shelf[j].Novel.ID.Hex()
+	})
+
+	return shelf, nil
+}
+
+// chapterOutlines 按卷号和章节号顺序获取小说的章节信息，不包含正文
+func (s *NovelService) chapterOutlines(ctx context.Context, novelIDs []string) (map[string][]chapterOutline, error) {
+	outlines := make(map[string][]chapterOutline, len(novelIDs))
+	if len(novelIDs) == 0 {
+		return outlines, nil
+	}
+
+	opts := options.Find().
+		SetProjection(bson.M{"novelId": 1, "volumeNumber": 1, "chapterNumber": 1, "title": 1, "createdAt": 1}).
+		SetSort(bson.D{{Key: "volumeNumber", Value: 1}, {Key: "chapterNumber", Value: 1}})
+	cursor, err := s.db.GetCollection("chapters").Find(ctx, bson.M{"novelId": bson.M{"$in": novelIDs}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var chapters []chapterOutline
+	if err = cursor.All(ctx, &chapters); err != nil {
+		return nil, err
+	}
+	for _, chapter := range chapters {
+		outlines[chapter.NovelID] = append(outlines[chapter.NovelID], chapter)
+	}
+	return outlines, nil
+}
+
+// chaptersUpTo 统计排在指定章节及之前的章节数，当前章节算作已读
+func chaptersUpTo(chapters []chapterOutline, volumeNumber, chapterNumber int) int {
+	return sort.Search(len(chapters), func(i int) bool {
+		c := chapters[i]
+		return c.VolumeNumber > volumeNumber || (c.VolumeNumber == volumeNumber && c.ChapterNumber > chapterNumber)
+	})
+}
+
+// completionPercent 完成百分比，保留一位小数
+func completionPercent(done, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(done)/float64(total)*1000) / 10
+}
+
+// shelfGroup 书架分组：0 读到一半或有更新，1 未读的收藏，2 已读完
+func shelfGroup(item *models.ShelfItem) int {
+	switch {
+	case item.LastRead == nil && item.Progress == nil:
+		return 1
+	case item.NextChapter == nil && item.NewChapters == 0 && item.TotalChapters > 0:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// shelfTime 书架排序用的时间，读过的用最后阅读时间，未读的收藏用收藏时间
+func shelfTime(item *models.ShelfItem, favoritedAt map[string]time.Time) time.Time {
+	if item.LastRead != nil {
+		return *item.LastRead
+	}
+	if item.Progress != nil {
+		return item.Progress.UpdatedAt
+	}
+	return favoritedAt[item.Novel.ID.Hex()]
+}
@@ -0,0 +1,441 @@
+// ****************************************************************************
+//
+// @file       sync_service.go
+// @brief      阅读进度、阅读历史和书签的多设备同步与冲突处理
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"lightnovel/internal/models"
+	"lightnovel/pkg/cache"
+	"lightnovel/pkg/errors"
+)
+
+// 冲突规则
+const (
+	RuleLastWriterWins = "lww"      // 客户端修改时间晚者为准
+	RuleFurthest       = "furthest" // 进度取最远，时间取最晚
+)
+
+// 删除记录类型
+const (
+	KindProgress = "progress"
+	KindHistory  = "history"
+	KindBookmark = "bookmark"
+)
+
+const (
+	maxSyncChanges = 500 // 单次同步每类变更的最大数量
+	syncPageSize   = 500 // 单次同步返回的服务端变更总数上限
+)
+
+// Sync 应用客户端的离线变更，并分页返回游标之后服务端的变更，HasMore 为真时客户端应以新游标继续同步
+func (s *NovelService) Sync(ctx context.Context, deviceID string, req *models.SyncRequest) (*models.SyncResponse, error) {
+	if len(req.Progress) > maxSyncChanges || len(req.History) > maxSyncChanges || len(req.Bookmarks) > maxSyncChanges {
+		return nil, errors.NewErrorWithMessage(errors.ErrInvalidParameter, fmt.Sprintf("单次同步每类变更不能超过%d条", maxSyncChanges))
+	}
+
+	resp := &models.SyncResponse{}
+	for _, change := range req.Progress {
+		applied, err := s.applyProgress(ctx, deviceID, change)
+		if err != nil {
+			return nil, err
+		}
+		if !applied {
+			resp.Rejected++
+		}
+	}
+	for _, change := range req.History {
+		applied, err := s.applyHistory(ctx, deviceID, change)
+		if err != nil {
+			return nil, err
+		}
+		if !applied {
+			resp.Rejected++
+		}
+	}
+	for _, change := range req.Bookmarks {
+		applied, err := s.applyBookmark(ctx, deviceID, change)
+		if err != nil {
+			return nil, err
+		}
+		if !applied {
+			resp.Rejected++
+		}
+	}
+
+	resp.Progress = []models.ReadProgress{}
+	if err := s.findSyncPage(ctx, "read_progress", deviceID, req.Cursor, &resp.Progress); err != nil {
+		return nil, err
+	}
+	resp.History = []models.ReadHistory{}
+	if err := s.findSyncPage(ctx, "read_history", deviceID, req.Cursor, &resp.History); err != nil {
+		return nil, err
+	}
+	resp.Bookmarks = []models.Bookmark{}
+	if err := s.findSyncPage(ctx, "bookmarks", deviceID, req.Cursor, &resp.Bookmarks); err != nil {
+		return nil, err
+	}
+	resp.Deleted = []models.SyncDeletion{}
+	if req.Cursor > 0 {
+		if err := s.findSyncPage(ctx, "tombstones", deviceID, req.Cursor, &resp.Deleted); err != nil {
+			return nil, err
+		}
+	}
+
+	// 各集合分别取了 syncPageSize+1 条，合并后按版本号截断到一页，
+	// 超出部分留给下一次同步，游标停在截断处
+	versions := make([]int64, 0, len(resp.Progress)+len(resp.History)+len(resp.Bookmarks)+len(resp.Deleted))
+	for _, p := range resp.Progress {
+		versions = append(versions, p.Version)
+	}
+	for _, h := range resp.History {
+		versions = append(versions, h.Version)
+	}
+	for _, b := range resp.Bookmarks {
+		versions = append(versions, b.Version)
+	}
+	for _, d := range resp.Deleted {
+		versions = append(versions, d.Version)
+	}
+	slices.Sort(versions)
+
+	// 游标取本次实际返回的最大版本号，而不是全局计数器的当前值。计数器由所有设备共用，
+	// 取计数器会越过已分配版本号但查询时尚未提交的写入。这只保证游标不越过未返回的版本，
+	// 若某次写入提交时其版本号已小于客户端游标，仍需下次全量同步(cursor=0)才能取回
+	resp.Cursor = req.Cursor
+	if len(versions) > syncPageSize {
+		cutoff := versions[syncPageSize-1]
+		resp.Progress = slices.DeleteFunc(resp.Progress, func(p models.ReadProgress) bool { return p.Version > cutoff })
+		resp.History = slices.DeleteFunc(resp.History, func(h models.ReadHistory) bool { return h.Version > cutoff })
+		resp.Bookmarks = slices.DeleteFunc(resp.Bookmarks, func(b models.Bookmark) bool { return b.Version > cutoff })
+		resp.Deleted = slices.DeleteFunc(resp.Deleted, func(d models.SyncDeletion) bool { return d.Version > cutoff })
+		resp.Cursor = cutoff
+		resp.HasMore = true
+	} else if len(versions) > 0 {
+		resp.Cursor = max(resp.Cursor, versions[len(versions)-1])
+	}
+
+	// 首次同步时补上尚未分配版本号的旧记录，它们无法按版本分页，只在第一页返回
+	if req.Cursor == 0 {
+		legacy := bson.M{"deviceId": deviceID, "version": bson.M{"$not": bson.M{"$gt": 0}}}
+		var progress []models.ReadProgress
+		if err := s.findAll(ctx, "read_progress", legacy, &progress); err != nil {
+			return nil, err
+		}
+		var history []models.ReadHistory
+		if err := s.findAll(ctx, "read_history", legacy, &history); err != nil {
+			return nil, err
+		}
+		var bookmarks []models.Bookmark
+		if err := s.findAll(ctx, "bookmarks", legacy, &bookmarks); err != nil {
+			return nil, err
+		}
+		resp.Progress = append(resp.Progress, progress...)
+		resp.History = append(resp.History, history...)
+		resp.Bookmarks = append(resp.Bookmarks, bookmarks...)
+	}
+
+	return resp, nil
+}
+
+// findSyncPage 按版本号升序查询游标之后的变更，多取一条用于判断是否还有下一页
+func (s *NovelService) findSyncPage(ctx context.Context, collection, deviceID string, cursor int64, results interface{}) error {
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: 1}}).SetLimit(syncPageSize + 1)
+	cur, err := s.db.GetCollection(collection).Find(ctx, bson.M{"deviceId": deviceID, "version": bson.M{"$gt": cursor}}, opts)
+	if err != nil {
+		return err
+	}
+	return cur.All(ctx, results)
+}
+
+// applyProgress 按冲突规则应用一条阅读进度变更
+func (s *NovelService) applyProgress(ctx context.Context, deviceID string, change models.ProgressChange) (bool, error) {
+	collection := s.db.GetCollection("read_progress")
+	filter := bson.M{"deviceId": deviceID, "novelId": change.NovelID}
+
+	var existing models.ReadProgress
+	err := collection.FindOne(ctx, filter).Decode(&existing)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return false, err
+	}
+	found := err == nil
+
+	if change.Deleted {
+		if !found || !newerThan(change.UpdatedAt, existing.ClientUpdatedAt, existing.UpdatedAt) {
+			return false, nil
+		}
+		if _, err := collection.DeleteOne(ctx, filter); err != nil {
+			return false, err
+		}
+		s.cache.Delete(ctx, cache.ReadProgressKey+deviceID+":"+change.NovelID)
+		return true, s.recordDeletion(ctx, deviceID, KindProgress, change.NovelID)
+	}
+
+	if found {
+		var wins bool
+		if s.cfg.Sync.Position == RuleFurthest {
+			wins = comparePosition(change.VolumeNumber, change.ChapterNumber, change.Position,
+				existing.VolumeNumber, existing.ChapterNumber, existing.Position) > 0
+		} else {
+			wins = newerThan(change.UpdatedAt, existing.ClientUpdatedAt, existing.UpdatedAt)
+		}
+		if !wins {
+			return false, nil
+		}
+	}
+
+	version, err := s.nextSyncVersion(ctx)
+	if err != nil {
+		return false, err
+	}
+	_, err = collection.UpdateOne(ctx, filter,
+		bson.M{
+			"$set": bson.M{
+				"volumeNumber":    change.VolumeNumber,
+				"chapterNumber":   change.ChapterNumber,
+				"position":        change.Position,
+				"updatedAt":       time.Now(),
+				"clientUpdatedAt": change.UpdatedAt,
+				"version":         version,
+			},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	s.cache.Delete(ctx, cache.ReadProgressKey+deviceID+":"+change.NovelID)
+	s.cache.Delete(ctx, cache.ReadingStatKey+deviceID)
+	return true, nil
+}
+
+// applyHistory 按冲突规则应用一条阅读历史变更
+func (s *NovelService) applyHistory(ctx context.Context, deviceID string, change models.HistoryChange) (bool, error) {
+	collection := s.db.GetCollection("read_history")
+	filter := bson.M{"deviceId": deviceID, "novelId": change.NovelID}
+
+	var existing models.ReadHistory
+	err := collection.FindOne(ctx, filter).Decode(&existing)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return false, err
+	}
+	found := err == nil
+
+	if change.Deleted {
+		if !found || !newerThan(change.UpdatedAt, existing.ClientUpdatedAt, existing.LastRead) {
+			return false, nil
+		}
+		if _, err := collection.DeleteOne(ctx, filter); err != nil {
+			return false, err
+		}
+		s.cache.Delete(ctx, cache.ReadHistoryKey+deviceID)
+		return true, s.recordDeletion(ctx, deviceID, KindHistory, change.NovelID)
+	}
+
+	lastRead := change.LastRead
+	if lastRead.IsZero() {
+		lastRead = change.UpdatedAt
+	}
+	if found {
+		var wins bool
+		if s.cfg.Sync.LastRead == RuleFurthest {
+			wins = lastRead.After(existing.LastRead)
+		} else {
+			wins = newerThan(change.UpdatedAt, existing.ClientUpdatedAt, existing.LastRead)
+		}
+		if !wins {
+			return false, nil
+		}
+	}
+
+	if err := s.writeHistory(ctx, deviceID, change.NovelID, lastRead, change.UpdatedAt); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// applyBookmark 按冲突规则应用一条书签变更
+func (s *NovelService) applyBookmark(ctx context.Context, deviceID string, change models.BookmarkChange) (bool, error) {
+	collection := s.db.GetCollection("bookmarks")
+
+	filter := bson.M{"deviceId": deviceID}
+	switch {
+	case change.ID != "":
+		objectID, err := primitive.ObjectIDFromHex(change.ID)
+		if err != nil {
+			return false, errors.NewError(errors.ErrInvalidParameter)
+		}
+		filter["_id"] = objectID
+	case change.ClientID != "":
+		filter["clientId"] = change.ClientID
+	default:
+		return false, errors.NewError(errors.ErrInvalidParameter)
+	}
+
+	var existing models.Bookmark
+	err := collection.FindOne(ctx, filter).Decode(&existing)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return false, err
+	}
+	found := err == nil
+	cacheKey := fmt.Sprintf("%s:%s", cache.BookmarkKey, deviceID)
+
+	if found && !newerThan(change.UpdatedAt, existing.ClientUpdatedAt, existing.UpdatedAt) {
+		return false, nil
+	}
+
+	if change.Deleted {
+		if !found {
+			return false, nil
+		}
+		if _, err := collection.DeleteOne(ctx, bson.M{"_id": existing.ID}); err != nil {
+			return false, err
+		}
+		s.cache.Delete(ctx, cacheKey)
+		return true, s.recordDeletion(ctx, deviceID, KindBookmark, existing.ID.Hex())
+	}
+
+	// 按ID更新的书签已在服务端被删除时不再重建
+	if !found && change.ID != "" {
+		return false, nil
+	}
+	if !found && change.NovelID == "" {
+		return false, errors.NewError(errors.ErrInvalidParameter)
+	}
+
+	version, err := s.nextSyncVersion(ctx)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	set := bson.M{
+		"position":        change.Position,
+		"note":            change.Note,
+		"updatedAt":       now,
+		"clientUpdatedAt": change.UpdatedAt,
+		"version":         version,
+	}
+	if found {
+		_, err = collection.UpdateOne(ctx, bson.M{"_id": existing.ID}, bson.M{"$set": set})
+	} else {
+		set["novelId"] = change.NovelID
+		set["volumeNumber"] = change.VolumeNumber
+		set["chapterNumber"] = change.ChapterNumber
+		_, err = collection.UpdateOne(ctx, filter,
+			bson.M{
+				"$set":         set,
+				"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "createdAt": now},
+			},
+			options.Update().SetUpsert(true),
+		)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	s.cache.Delete(ctx, cacheKey)
+	return true, nil
+}
+
+// writeHistory 写入阅读历史并更新同步版本号
+func (s *NovelService) writeHistory(ctx context.Context, deviceID, novelID string, lastRead, clientUpdatedAt time.Time) error {
+	version, err := s.nextSyncVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.GetCollection("read_history").UpdateOne(ctx,
+		bson.M{"deviceId": deviceID, "novelId": novelID},
+		bson.M{
+			"$set": bson.M{
+				"lastRead":        lastRead,
+				"clientUpdatedAt": clientUpdatedAt,
+				"version":         version,
+			},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+
+	s.cache.Delete(ctx, cache.ReadHistoryKey+deviceID)
+	return nil
+}
+
+// recordDeletion 记录删除，供其他设备同步
+func (s *NovelService) recordDeletion(ctx context.Context, deviceID, kind, key string) error {
+	version, err := s.nextSyncVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.GetCollection("tombstones").InsertOne(ctx, models.SyncDeletion{
+		DeviceID:  deviceID,
+		Kind:      kind,
+		Key:       key,
+		Version:   version,
+		DeletedAt: time.Now(),
+	})
+	return err
+}
+
+// nextSyncVersion 获取下一个全局递增的同步版本号
+func (s *NovelService) nextSyncVersion(ctx context.Context) (int64, error) {
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := s.db.GetCollection("counters").FindOneAndUpdate(ctx,
+		bson.M{"_id": "sync"},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	return counter.Seq, err
+}
+
+// newerThan 判断客户端修改时间是否晚于服务端记录，旧数据没有客户端时间时使用fallback
+func newerThan(incoming, clientUpdatedAt, fallback time.Time) bool {
+	if clientUpdatedAt.IsZero() {
+		clientUpdatedAt = fallback
+	}
+	return incoming.After(clientUpdatedAt)
+}
+
+// comparePosition 比较两个阅读位置的先后
+func comparePosition(volA, chA, posA, volB, chB, posB int) int {
+	switch {
+	case volA != volB:
+		return volA - volB
+	case chA != chB:
+		return chA - chB
+	default:
+		return posA - posB
+	}
+}
+
+// latest 返回两个时间中较晚的一个
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -20,6 +20,7 @@ import (
 	"lightnovel/pkg/cache"
 	"lightnovel/pkg/database"
 	"lightnovel/pkg/middleware"
+	"lightnovel/pkg/websocket"
 	"log"
 	"time"
 
@@ -62,11 +63,31 @@ func main() {
 	}
 	defer multiLevelCache.Close()
 
+	// 创建WebSocket中心，服务层和连接处理器共用
+	hub := websocket.NewHub()
+	if cfg.Socket.Relay {
+		if err := hub.EnableRelay(ctx, multiLevelCache.GetRedisClient(), "lightnovel:", cfg.Socket.NodeID); err != nil {
+			log.Fatalf("Failed to enable websocket relay: %v", err)
+		}
+		log.Printf("WebSocket relay enabled, node %s", hub.NodeID())
+	}
+	if cfg.Socket.EventLog > 0 {
+		hub.EnableEventLog(multiLevelCache.GetRedisClient(), "lightnovel:", cfg.Socket.EventLog)
+	}
+	hub.EnablePresence(ctx, multiLevelCache.GetRedisClient(), "lightnovel:")
+	go hub.Run()
+
 	// 创建服务和处理器
-	novelService := service.NewNovelService(db, multiLevelCache, cfg)
+	novelService := service.NewNovelService(db, multiLevelCache, hub, cfg)
+	go novelService.RunRecommendJob(ctx)
+	go novelService.RunRankingJob(ctx)
+	go novelService.RunReadCountJob(ctx)
+	go novelService.BackfillChapterIndex(ctx)
 	novelHandler := v1.NewNovelHandler(novelService)
+	adminHandler := v1.NewAdminHandler(novelService)
+	authHandler := v1.NewAuthHandler(novelService)
 	healthHandler := v1.NewHealthHandler()
-	wsHandler := v1.NewWebSocketHandler(cfg)
+	wsHandler := v1.NewWebSocketHandler(hub, novelService, cfg)
 
 	// 创建路由
 	r := gin.New()
@@ -77,6 +98,8 @@ func main() {
 	r.Use(middleware.SecurityHeaders())
 	r.Use(middleware.CORS())
 	r.Use(middleware.DeviceMiddleware(novelService))
+	r.Use(middleware.AuthMiddleware(novelService))
+	r.Use(middleware.RestrictionMiddleware(novelService))
 
 	// 创建限流器
 	rateLimiter := middleware.NewRateLimiter(
@@ -100,11 +123,22 @@ func main() {
 		// WebSocket连接
 		api.GET("/ws", wsHandler.HandleConnection)
 		api.GET("/ws/status", wsHandler.GetStatus)
+		api.GET("/events", wsHandler.HandleEvents)
 
 		// 健康检查
 		api.GET("/health", healthHandler.Check)
 		api.GET("/metrics", healthHandler.Metrics)
 
+		// 账号相关路由组
+		auth := api.Group("/auth")
+		{
+			auth.POST("/register", authHandler.Register)
+			auth.POST("/login", authHandler.Login)
+			auth.POST("/logout", middleware.RequireLogin(), authHandler.Logout)
+			auth.GET("/devices", middleware.RequireLogin(), authHandler.GetDevices)
+			auth.DELETE("/devices/:device_id", middleware.RequireLogin(), authHandler.UnlinkDevice)
+		}
+
 		// 小说相关路由组
 		novels := api.Group("/novels")
 		{
@@ -115,16 +149,28 @@ func main() {
 			// 限制数量的路由
 			novels.GET("/latest", middleware.ValidateLimit(1000, 1000), novelHandler.GetLatestNovels)
 			novels.GET("/popular", middleware.ValidateLimit(1000, 1000), novelHandler.GetPopularNovels)
+			novels.GET("/top-rated", middleware.ValidateLimit(100, 1000), novelHandler.GetTopRatedNovels)
+			novels.GET("/rankings", middleware.ValidateLimit(50, 100), novelHandler.GetRankings)
 
 			// 基于ID的路由
 			novels.GET("/:id", novelHandler.GetNovelByID)
+			novels.GET("/:id/search", middleware.ValidatePagination(), novelHandler.SearchChapterContent)
+			novels.GET("/:id/related", middleware.ValidateLimit(10, 20), novelHandler.GetRelatedNovels)
+			novels.GET("/:id/presence", wsHandler.GetPresence)
 			novels.GET("/:id/volumes", novelHandler.GetVolumesByNovelID)
 			novels.GET("/:id/volumes/:volume/chapters", novelHandler.GetChaptersByVolumeID)
 			novels.GET("/:id/volumes/:volume/chapters/:chapter", novelHandler.GetChapterByNumber)
 
+			// 评分与书评路由
+			novels.GET("/:id/reviews", middleware.ValidatePagination(), novelHandler.GetReviews)
+			novels.GET("/:id/rating", novelHandler.GetMyRating)
+			novels.PUT("/:id/rating", novelHandler.RateNovel)
+			novels.DELETE("/:id/rating", novelHandler.DeleteRating)
+
 			// 章节评论路由
 			novels.GET("/:id/volumes/:volume/chapters/:chapter/comments", novelHandler.GetComments)
 			novels.POST("/:id/volumes/:volume/chapters/:chapter/comments", novelHandler.CreateComment)
+			novels.GET("/:id/volumes/:volume/chapters/:chapter/comments/paragraphs", novelHandler.GetParagraphCommentCounts)
 		}
 
 		// 用户相关路由组
@@ -136,6 +182,16 @@ func main() {
 			user.DELETE("/favorites/:novel_id", novelHandler.RemoveFavorite)
 			user.GET("/favorites/:novel_id/check", novelHandler.IsFavorite)
 
+			// 关注和通知
+			user.GET("/follows", novelHandler.GetFollows)
+			user.POST("/follows/:novel_id", novelHandler.FollowNovel)
+			user.DELETE("/follows/:novel_id", novelHandler.UnfollowNovel)
+			user.GET("/notifications", middleware.ValidatePagination(), novelHandler.GetNotifications)
+			user.PUT("/notifications/read", novelHandler.MarkAllNotificationsRead)
+			user.PUT("/notifications/:id/read", novelHandler.MarkNotificationRead)
+			user.GET("/notifications/preferences", novelHandler.GetNotificationPrefs)
+			user.PUT("/notifications/preferences", novelHandler.UpdateNotificationPrefs)
+
 			// 书签相关
 			user.GET("/bookmarks", novelHandler.GetUserBookmarks)
 			user.POST("/bookmarks", novelHandler.CreateBookmark)
@@ -155,8 +211,34 @@ func main() {
 				reading.GET("/progress/:novel_id", novelHandler.GetReadProgress)
 				reading.PUT("/progress/:novel_id", novelHandler.UpdateReadProgress)
 				reading.DELETE("/progress/:novel_id", novelHandler.DeleteReadProgress)
+
+				// 阅读会话
+				reading.POST("/sessions", novelHandler.StartReadingSession)
+				reading.PUT("/sessions/:id", novelHandler.EndReadingSession)
 			}
 
+			// 阅读统计和书架
+			user.GET("/stats", novelHandler.GetReadingStats)
+			user.GET("/shelf", novelHandler.GetShelf)
+
+			// 书单
+			user.GET("/shelves", novelHandler.GetBookshelves)
+			user.POST("/shelves", novelHandler.CreateBookshelf)
+			user.PUT("/shelves/order", novelHandler.ReorderBookshelves)
+			user.GET("/shelves/:shelf_id", novelHandler.GetBookshelf)
+			user.PUT("/shelves/:shelf_id", novelHandler.UpdateBookshelf)
+			user.DELETE("/shelves/:shelf_id", novelHandler.DeleteBookshelf)
+			user.POST("/shelves/:shelf_id/novels", novelHandler.AddToBookshelf)
+			user.PUT("/shelves/:shelf_id/novels/:novel_id", novelHandler.UpdateShelfEntry)
+			user.DELETE("/shelves/:shelf_id/novels/:novel_id", novelHandler.RemoveFromBookshelf)
+			user.PUT("/shelves/:shelf_id/order", novelHandler.ReorderBookshelf)
+
+			// 多设备同步
+			user.POST("/sync", novelHandler.SyncUserData)
+
+			// 个性化推荐
+			user.GET("/recommendations", middleware.ValidateLimit(20, 100), novelHandler.GetRecommendations)
+
 			// 用户资料路由
 			user.GET("/profile", novelHandler.GetUserProfile)
 			user.PUT("/profile", novelHandler.UpdateUserProfile)
@@ -164,10 +246,42 @@ func main() {
 			user.POST("/upload/avatar", novelHandler.UploadAvatar)
 		}
 
+		// 公开分享的书单
+		api.GET("/shelves/shared/:code", novelHandler.GetSharedBookshelf)
+
 		// 评论相关路由
 		comments := api.Group("/comments")
 		{
 			comments.DELETE("/:comment_id", novelHandler.DeleteComment)
+			comments.GET("/:comment_id/replies", novelHandler.GetCommentReplies)
+			comments.POST("/:comment_id/like", novelHandler.LikeComment)
+			comments.DELETE("/:comment_id/like", novelHandler.UnlikeComment)
+			comments.POST("/:comment_id/report", novelHandler.ReportComment)
+		}
+
+		// 管理相关路由组
+		admin := api.Group("/admin", middleware.AdminAuth(cfg.Admin.Token))
+		{
+			admin.POST("/novels", adminHandler.CreateNovel)
+			admin.PUT("/novels/:id", adminHandler.UpdateNovel)
+			admin.DELETE("/novels/:id", adminHandler.DeleteNovel)
+
+			admin.POST("/novels/:id/volumes", adminHandler.CreateVolume)
+			admin.DELETE("/novels/:id/volumes/:volume", adminHandler.DeleteVolume)
+
+			admin.POST("/novels/:id/volumes/:volume/chapters", adminHandler.CreateChapter)
+			admin.PUT("/novels/:id/volumes/:volume/chapters/:chapter", adminHandler.UpdateChapter)
+			admin.DELETE("/novels/:id/volumes/:volume/chapters/:chapter", adminHandler.DeleteChapter)
+
+			admin.PUT("/comments/:comment_id/pin", adminHandler.PinComment)
+			admin.GET("/comments/queue", adminHandler.GetModerationQueue)
+			admin.POST("/comments/:comment_id/hide", adminHandler.HideComment)
+			admin.POST("/comments/:comment_id/restore", adminHandler.RestoreComment)
+			admin.DELETE("/comments/:comment_id", adminHandler.RemoveComment)
+
+			admin.PUT("/devices/:device_id/restriction", adminHandler.SetDeviceRestriction)
+
+			admin.POST("/notices", adminHandler.SendSystemNotice)
 		}
 	}
 
@@ -0,0 +1,124 @@
+// ****************************************************************************
+//
+// @file       anchor.go
+// @brief      段评锚点在章节正文修改后的重新定位，服务端和导入工具共用
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package anchor
+
+import (
+	"context"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"lightnovel/internal/models"
+)
+
+// Reanchor 章节正文修改后重新定位该章的段评，找不到原文的段评标记为失效，返回位置有变化的段评数
+func Reanchor(ctx context.Context, comments *mongo.Collection, novelID string, volumeNumber, chapterNumber int, content string) (int, error) {
+	cursor, err := comments.Find(ctx, bson.M{
+		"novelId":       novelID,
+		"volumeNumber":  volumeNumber,
+		"chapterNumber": chapterNumber,
+		"anchor":        bson.M{"$exists": true},
+	})
+	if err != nil {
+		return 0, err
+	}
+	var anchored []models.Comment
+	if err := cursor.All(ctx, &anchored); err != nil {
+		return 0, err
+	}
+	if len(anchored) == 0 {
+		return 0, nil
+	}
+
+	paragraphs := SplitParagraphs(content)
+	moved := 0
+	for _, comment := range anchored {
+		anchor := Relocate(paragraphs, *comment.Anchor)
+		if anchor == *comment.Anchor {
+			continue
+		}
+
+		_, err := comments.UpdateOne(ctx,
+			bson.M{"_id": comment.ID},
+			bson.M{"$set": bson.M{"anchor": anchor}},
+		)
+		if err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// Orphan 章节被删除后将该章的段评标记为失效，返回标记的段评数
+func Orphan(ctx context.Context, comments *mongo.Collection, novelID string, volumeNumber, chapterNumber int) (int64, error) {
+	result, err := comments.UpdateMany(ctx,
+		bson.M{
+			"novelId":         novelID,
+			"volumeNumber":    volumeNumber,
+			"chapterNumber":   chapterNumber,
+			"anchor":          bson.M{"$exists": true},
+			"anchor.orphaned": bson.M{"$ne": true},
+		},
+		bson.M{"$set": bson.M{"anchor.orphaned": true}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// Relocate 在新正文中查找锚点原文，取与原段落最近的匹配
+func Relocate(paragraphs []string, anchor models.CommentAnchor) models.CommentAnchor {
+	best, offset := -1, 0
+	for i, paragraph := range paragraphs {
+		idx := strings.Index(paragraph, anchor.Quote)
+		if idx < 0 {
+			continue
+		}
+		if best < 0 || abs(i-anchor.Paragraph) < abs(best-anchor.Paragraph) {
+			best, offset = i, len([]rune(paragraph[:idx]))
+		}
+	}
+
+	if best < 0 || anchor.Quote == "" {
+		anchor.Orphaned = true
+		return anchor
+	}
+
+	wholeParagraph := anchor.Start == anchor.End
+	anchor.Paragraph = best
+	anchor.Orphaned = false
+	if !wholeParagraph {
+		anchor.End = offset + len([]rune(anchor.Quote))
+		anchor.Start = offset
+	}
+	return anchor
+}
+
+// SplitParagraphs 将章节正文按换行拆分为段落，忽略空行
+func SplitParagraphs(content string) []string {
+	var paragraphs []string
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	return paragraphs
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
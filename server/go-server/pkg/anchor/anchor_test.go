@@ -0,0 +1,123 @@
+// ****************************************************************************
+//
+// @file       anchor_test.go
+// @brief      段评锚点重新定位和段落拆分的测试
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package anchor
+
+import (
+	"slices"
+	"testing"
+
+	"lightnovel/internal/models"
+)
+
+func TestSplitParagraphs(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"空正文", "", nil},
+		{"只有空行", "\n \n\t\n", nil},
+		{"单段", "第一段", []string{"第一段"}},
+		{"去掉首尾空白", "  第一段  \n\t第二段", []string{"第一段", "第二段"}},
+		{"忽略空行", "第一段\n\n\n第二段\n", []string{"第一段", "第二段"}},
+		{"Windows换行", "第一段\r\n第二段\r\n", []string{"第一段", "第二段"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitParagraphs(tt.content); !slices.Equal(got, tt.want) {
+				t.Errorf("SplitParagraphs(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelocate(t *testing.T) {
+	paragraphs := []string{"开头一段", "夜色很深。她推开门。", "中间一段", "她推开门，风吹了进来。"}
+
+	tests := []struct {
+		name   string
+		anchor models.CommentAnchor
+		want   models.CommentAnchor
+	}{
+		{
+			name:   "位置未变",
+			anchor: models.CommentAnchor{Paragraph: 1, Start: 5, End: 9, Quote: "她推开门"},
+			want:   models.CommentAnchor{Paragraph: 1, Start: 5, End: 9, Quote: "她推开门"},
+		},
+		{
+			name:   "段内偏移按字符计",
+			anchor: models.CommentAnchor{Paragraph: 3, Start: 0, End: 3, Quote: "风吹了"},
+			want:   models.CommentAnchor{Paragraph: 3, Start: 5, End: 8, Quote: "风吹了"},
+		},
+		{
+			name:   "多处匹配取离原段落最近的",
+			anchor: models.CommentAnchor{Paragraph: 3, Start: 0, End: 4, Quote: "她推开门"},
+			want:   models.CommentAnchor{Paragraph: 3, Start: 0, End: 4, Quote: "她推开门"},
+		},
+		{
+			name:   "距离相同时取靠前的段落",
+			anchor: models.CommentAnchor{Paragraph: 2, Start: 0, End: 4, Quote: "她推开门"},
+			want:   models.CommentAnchor{Paragraph: 1, Start: 5, End: 9, Quote: "她推开门"},
+		},
+		{
+			name:   "整段锚点只更新段落",
+			anchor: models.CommentAnchor{Paragraph: 0, Start: 0, End: 0, Quote: "中间一段"},
+			want:   models.CommentAnchor{Paragraph: 2, Start: 0, End: 0, Quote: "中间一段"},
+		},
+		{
+			name:   "找不到原文时标记失效",
+			anchor: models.CommentAnchor{Paragraph: 1, Start: 0, End: 2, Quote: "已删除"},
+			want:   models.CommentAnchor{Paragraph: 1, Start: 0, End: 2, Quote: "已删除", Orphaned: true},
+		},
+		{
+			name:   "空原文标记失效",
+			anchor: models.CommentAnchor{Paragraph: 1, Start: 0, End: 0},
+			want:   models.CommentAnchor{Paragraph: 1, Start: 0, End: 0, Orphaned: true},
+		},
+		{
+			name:   "重新找到后清除失效标记",
+			anchor: models.CommentAnchor{Paragraph: 0, Start: 0, End: 4, Quote: "中间一段", Orphaned: true},
+			want:   models.CommentAnchor{Paragraph: 2, Start: 0, End: 4, Quote: "中间一段"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Relocate(paragraphs, tt.anchor); got != tt.want {
+				t.Errorf("Relocate(%+v) = %+v, want %+v", tt.anchor, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelocateEmptyContent(t *testing.T) {
+	anchor := models.CommentAnchor{Paragraph: 0, Start: 0, End: 2, Quote: "开头"}
+	if got := Relocate(nil, anchor); !got.Orphaned {
+		t.Errorf("Relocate(nil, %+v) = %+v, want orphaned", anchor, got)
+	}
+}
+
+func TestAbs(t *testing.T) {
+	tests := []struct {
+		in, want int
+	}{
+		{0, 0},
+		{3, 3},
+		{-3, 3},
+	}
+
+	for _, tt := range tests {
+		if got := abs(tt.in); got != tt.want {
+			t.Errorf("abs(%d) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"strconv"
 	"strings"
 	"time"
 
@@ -31,9 +32,38 @@ type Cache interface {
 	DeleteByPattern(ctx context.Context, pattern string) error
 	MultiGet(ctx context.Context, keys []string, values []interface{}) error
 	MultiSet(ctx context.Context, items map[string]interface{}, expiration time.Duration) error
+	SortedSet
+	Counter
+	Shared
 	Close() error
 }
 
+// Shared 只存于 Redis 的键值，不经过各实例的本地缓存，删除后所有实例立即可见，用于登录会话等需要及时失效的数据
+type Shared interface {
+	GetShared(ctx context.Context, key string, value interface{}) error
+	SetShared(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+}
+
+// SortedSet 有序集合操作，用于排行榜等计数场景，只存于 Redis
+type SortedSet interface {
+	ZIncrBy(ctx context.Context, key, member string, incr float64, expiration time.Duration) error
+	ZAdd(ctx context.Context, key string, members []redis.Z, expiration time.Duration) error
+	ZUnionStore(ctx context.Context, dest string, keys []string, expiration time.Duration) error
+	ZRevRange(ctx context.Context, key string, start, stop int64) ([]redis.Z, error)
+	ZRem(ctx context.Context, keys []string, member string) error
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+// Counter 计数操作，用于在 Redis 中缓冲计数后批量写入数据库
+type Counter interface {
+	SetNX(ctx context.Context, key string, expiration time.Duration) (bool, error)
+	HIncrBy(ctx context.Context, key, field string, incr int64) error
+	HDrain(ctx context.Context, key string) (map[string]string, error)
+	HDel(ctx context.Context, key string, fields ...string) error
+	PFAdd(ctx context.Context, key string, members ...string) error
+	PFCount(ctx context.Context, key string) (int64, error)
+}
+
 type MultiLevelCache struct {
 	local  *bigcache.BigCache
 	redis  *redis.Client
@@ -267,6 +297,129 @@ func (c *MultiLevelCache) DeleteByPattern(ctx context.Context, pattern string) e
 	return nil
 }
 
+// ZIncrBy 增加有序集合中成员的分数并刷新集合的过期时间
+func (c *MultiLevelCache) ZIncrBy(ctx context.Context, key, member string, incr float64, expiration time.Duration) error {
+	pipe := c.redis.TxPipeline()
+	pipe.ZIncrBy(ctx, c.prefix+key, incr, member)
+	pipe.Expire(ctx, c.prefix+key, expiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ZAdd 写入有序集合成员的分数并设置集合的过期时间
+func (c *MultiLevelCache) ZAdd(ctx context.Context, key string, members []redis.Z, expiration time.Duration) error {
+	if len(members) == 0 {
+		return nil
+	}
+	pipe := c.redis.TxPipeline()
+	pipe.ZAdd(ctx, c.prefix+key, members...)
+	pipe.Expire(ctx, c.prefix+key, expiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ZUnionStore 将多个有序集合按成员求和合并到dest
+func (c *MultiLevelCache) ZUnionStore(ctx context.Context, dest string, keys []string, expiration time.Duration) error {
+	prefixedKeys := make([]string, len(keys))
+	for i, key := range keys {
+		prefixedKeys[i] = c.prefix + key
+	}
+
+	pipe := c.redis.TxPipeline()
+	pipe.ZUnionStore(ctx, c.prefix+dest, &redis.ZStore{Keys: prefixedKeys})
+	pipe.Expire(ctx, c.prefix+dest, expiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ZRevRange 按分数降序获取有序集合的成员，stop为-1时获取全部
+func (c *MultiLevelCache) ZRevRange(ctx context.Context, key string, start, stop int64) ([]redis.Z, error) {
+	return c.redis.ZRevRangeWithScores(ctx, c.prefix+key, start, stop).Result()
+}
+
+// Exists 判断 Redis 中是否存在该键
+func (c *MultiLevelCache) Exists(ctx context.Context, key string) (bool, error) {
+	n, err := c.redis.Exists(ctx, c.prefix+key).Result()
+	return n > 0, err
+}
+
+// GetShared 只从 Redis 获取缓存
+func (c *MultiLevelCache) GetShared(ctx context.Context, key string, value interface{}) error {
+	data, err := c.redis.Get(ctx, c.prefix+key).Bytes()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+// SetShared 只写入 Redis
+func (c *MultiLevelCache) SetShared(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.redis.Set(ctx, c.prefix+key, data, expiration).Err()
+}
+
+// ZRem 从多个有序集合中删除同一成员
+func (c *MultiLevelCache) ZRem(ctx context.Context, keys []string, member string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	pipe := c.redis.Pipeline()
+	for _, key := range keys {
+		pipe.ZRem(ctx, c.prefix+key, member)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SetNX 键不存在时设置标记，返回是否设置成功
+func (c *MultiLevelCache) SetNX(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	return c.redis.SetNX(ctx, c.prefix+key, 1, expiration).Result()
+}
+
+// HIncrBy 增加哈希表中字段的值
+func (c *MultiLevelCache) HIncrBy(ctx context.Context, key, field string, incr int64) error {
+	return c.redis.HIncrBy(ctx, c.prefix+key, field, incr).Err()
+}
+
+// HDrain 取出并删除整个哈希表，先重命名再读取，读取期间的新写入会进入新的哈希表
+func (c *MultiLevelCache) HDrain(ctx context.Context, key string) (map[string]string, error) {
+	drained := c.prefix + key + ":drain:" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := c.redis.Rename(ctx, c.prefix+key, drained).Err(); err != nil {
+		if strings.Contains(err.Error(), "no such key") {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+
+	values, err := c.redis.HGetAll(ctx, drained).Result()
+	if err != nil {
+		return nil, err
+	}
+	return values, c.redis.Del(ctx, drained).Err()
+}
+
+// HDel 删除哈希表中的字段
+func (c *MultiLevelCache) HDel(ctx context.Context, key string, fields ...string) error {
+	return c.redis.HDel(ctx, c.prefix+key, fields...).Err()
+}
+
+// PFAdd 向 HyperLogLog 添加成员
+func (c *MultiLevelCache) PFAdd(ctx context.Context, key string, members ...string) error {
+	args := make([]interface{}, len(members))
+	for i, member := range members {
+		args[i] = member
+	}
+	return c.redis.PFAdd(ctx, c.prefix+key, args...).Err()
+}
+
+// PFCount 获取 HyperLogLog 的基数估计
+func (c *MultiLevelCache) PFCount(ctx context.Context, key string) (int64, error) {
+	return c.redis.PFCount(ctx, c.prefix+key).Result()
+}
+
 func (c *MultiLevelCache) Close() error {
 	if err := c.local.Close(); err != nil {
 		return err
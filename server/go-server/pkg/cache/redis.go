@@ -26,15 +26,27 @@ const (
 	ChapterListKey   = "novel:chapters:" // 章节列表
 	ChapterKey       = "novel:chapter:"  // 章节内容
 	SearchKey        = "novel:search:"   // 搜索结果
+	ContentSearchKey = "novel:content:"  // 小说内正文搜索
 	LatestNovelsKey  = "novel:latest"    // 最新小说
 	PopularNovelsKey = "novel:popular"   // 热门小说
+	TopRatedKey      = "novel:toprated"  // 评分榜
+	RelatedKey       = "novel:related:"  // 相关小说
+	RankingKey       = "novel:rank:"     // 排行榜，每日计数为有序集合
 	DeviceKey        = "device:info:"    // 设备信息
 	BookmarkKey      = "user:bookmark:"  // 用户书签
 	FavoriteKey      = "user:favorite:"  // 用户收藏
 	ReadHistoryKey   = "read:history:"   // 阅读历史
 	ReadProgressKey  = "read:progress:"  // 阅读进度
+	ReadDedupKey     = "read:dedup:"     // 阅读去重标记
+	ReadPendingKey   = "read:pending"    // 待写入数据库的阅读量
+	ReadersKey       = "read:readers:"   // 小说独立读者数(HyperLogLog)
+	ReadingStatKey   = "read:stats:"     // 阅读统计
+	RecommendKey     = "user:recommend:" // 个性化推荐
 	UserKey          = "user:info:"      // 用户信息
+	SessionKey       = "auth:session:"   // 登录会话
 	CommentListKey   = "comment:list:"   // 评论列表
+	ReviewListKey    = "review:list:"    // 书评列表
+	PresenceKey      = "presence:"       // 在读记录，由WebSocket hub写入
 )
 
 // RedisCache Redis缓存服务
@@ -84,6 +84,14 @@ func (db *MongoDB) CreateIndexes(ctx context.Context) error {
 			Keys:    bson.D{{Key: "readCount", Value: -1}},
 			Options: options.Index().SetName("read_count"),
 		},
+		{
+			Keys:    bson.D{{Key: "tags", Value: 1}},
+			Options: options.Index().SetName("tags"),
+		},
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}},
+			Options: options.Index().SetName("status"),
+		},
 	}
 
 	// 卷集合索引
@@ -109,6 +117,25 @@ func (db *MongoDB) CreateIndexes(ctx context.Context) error {
 		},
 	}
 
+	// 章节正文索引
+	chapterIndexIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "novelId", Value: 1},
+				{Key: "volumeNumber", Value: 1},
+				{Key: "chapterNumber", Value: 1},
+			},
+			Options: options.Index().SetName("novel_chapter").SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "novelId", Value: 1},
+				{Key: "grams", Value: 1},
+			},
+			Options: options.Index().SetName("novel_grams"),
+		},
+	}
+
 	// 设备集合索引
 	deviceIndexes := []mongo.IndexModel{
 		{
@@ -119,6 +146,10 @@ func (db *MongoDB) CreateIndexes(ctx context.Context) error {
 			Keys:    bson.D{{Key: "lastSeen", Value: -1}},
 			Options: options.Index().SetName("last_seen"),
 		},
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}},
+			Options: options.Index().SetName("device_user"),
+		},
 	}
 
 	// 书签索引
@@ -134,6 +165,21 @@ func (db *MongoDB) CreateIndexes(ctx context.Context) error {
 			Keys:    bson.D{{Key: "createdAt", Value: -1}},
 			Options: options.Index().SetName("created_at"),
 		},
+		{
+			Keys: bson.D{
+				{Key: "deviceId", Value: 1},
+				{Key: "version", Value: 1},
+			},
+			Options: options.Index().SetName("device_version"),
+		},
+		{
+			Keys: bson.D{
+				{Key: "deviceId", Value: 1},
+				{Key: "clientId", Value: 1},
+			},
+			Options: options.Index().SetName("device_client_bookmark").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"clientId": bson.M{"$exists": true}}),
+		},
 	}
 
 	// 收藏索引
@@ -145,6 +191,54 @@ func (db *MongoDB) CreateIndexes(ctx context.Context) error {
 			},
 			Options: options.Index().SetName("device_novel_favorite").SetUnique(true),
 		},
+		{
+			Keys:    bson.D{{Key: "novelId", Value: 1}},
+			Options: options.Index().SetName("novel_id"),
+		},
+	}
+
+	// 关注索引
+	followIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "deviceId", Value: 1},
+				{Key: "novelId", Value: 1},
+			},
+			Options: options.Index().SetName("device_novel_follow").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "novelId", Value: 1}},
+			Options: options.Index().SetName("novel_id"),
+		},
+	}
+
+	// 通知索引，通知保留90天
+	notificationIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "deviceId", Value: 1},
+				{Key: "createdAt", Value: -1},
+			},
+			Options: options.Index().SetName("device_created"),
+		},
+		{
+			Keys: bson.D{
+				{Key: "deviceId", Value: 1},
+				{Key: "read", Value: 1},
+			},
+			Options: options.Index().SetName("device_read"),
+		},
+		{
+			Keys: bson.D{
+				{Key: "deviceId", Value: 1},
+				{Key: "delivered", Value: 1},
+			},
+			Options: options.Index().SetName("device_delivered"),
+		},
+		{
+			Keys:    bson.D{{Key: "createdAt", Value: 1}},
+			Options: options.Index().SetName("notification_ttl").SetExpireAfterSeconds(90 * 24 * 3600),
+		},
 	}
 
 	// 阅读历史索引
@@ -160,6 +254,13 @@ func (db *MongoDB) CreateIndexes(ctx context.Context) error {
 			Keys:    bson.D{{Key: "lastRead", Value: -1}},
 			Options: options.Index().SetName("last_read"),
 		},
+		{
+			Keys: bson.D{
+				{Key: "deviceId", Value: 1},
+				{Key: "version", Value: 1},
+			},
+			Options: options.Index().SetName("device_version"),
+		},
 	}
 
 	// 阅读进度索引
@@ -171,6 +272,28 @@ func (db *MongoDB) CreateIndexes(ctx context.Context) error {
 			},
 			Options: options.Index().SetName("device_novel_progress").SetUnique(true),
 		},
+		{
+			Keys: bson.D{
+				{Key: "deviceId", Value: 1},
+				{Key: "version", Value: 1},
+			},
+			Options: options.Index().SetName("device_version"),
+		},
+	}
+
+	// 删除记录索引，保留90天，游标更早的客户端需全量同步
+	tombstoneIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "deviceId", Value: 1},
+				{Key: "version", Value: 1},
+			},
+			Options: options.Index().SetName("device_version"),
+		},
+		{
+			Keys:    bson.D{{Key: "deletedAt", Value: 1}},
+			Options: options.Index().SetName("deletion_ttl").SetExpireAfterSeconds(90 * 24 * 3600),
+		},
 	}
 
 	// 用户集合索引
@@ -179,6 +302,23 @@ func (db *MongoDB) CreateIndexes(ctx context.Context) error {
 			Keys:    bson.D{{Key: "lastActiveAt", Value: -1}},
 			Options: options.Index().SetName("last_active"),
 		},
+		{
+			Keys: bson.D{{Key: "username", Value: 1}},
+			Options: options.Index().SetName("username").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"username": bson.M{"$exists": true}}),
+		},
+	}
+
+	// 会话集合索引
+	sessionIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}},
+			Options: options.Index().SetName("user_sessions"),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetName("session_ttl").SetExpireAfterSeconds(0),
+		},
 	}
 
 	// 评论集合索引
@@ -199,6 +339,134 @@ func (db *MongoDB) CreateIndexes(ctx context.Context) error {
 			Keys:    bson.D{{Key: "deviceId", Value: 1}},
 			Options: options.Index().SetName("user_comments"),
 		},
+		{
+			Keys: bson.D{
+				{Key: "parentId", Value: 1},
+				{Key: "createdAt", Value: 1},
+			},
+			Options: options.Index().SetName("comment_replies"),
+		},
+		{
+			Keys: bson.D{
+				{Key: "novelId", Value: 1},
+				{Key: "volumeNumber", Value: 1},
+				{Key: "chapterNumber", Value: 1},
+				{Key: "anchor.paragraph", Value: 1},
+			},
+			Options: options.Index().SetName("paragraph_comments"),
+		},
+		{
+			Keys: bson.D{
+				{Key: "status", Value: 1},
+				{Key: "reportCount", Value: -1},
+			},
+			Options: options.Index().SetName("moderation_queue"),
+		},
+	}
+
+	// 评分集合索引
+	reviewIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "novelId", Value: 1},
+				{Key: "deviceId", Value: 1},
+			},
+			Options: options.Index().SetName("novel_reviewer").SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "novelId", Value: 1},
+				{Key: "updatedAt", Value: -1},
+			},
+			Options: options.Index().SetName("novel_reviews"),
+		},
+	}
+
+	// 评论举报集合索引
+	reportIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "commentId", Value: 1},
+				{Key: "deviceId", Value: 1},
+			},
+			Options: options.Index().SetName("comment_reporter").SetUnique(true),
+		},
+	}
+
+	// 评论点赞集合索引
+	commentLikeIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "commentId", Value: 1},
+				{Key: "deviceId", Value: 1},
+			},
+			Options: options.Index().SetName("comment_device").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "deviceId", Value: 1}},
+			Options: options.Index().SetName("user_likes"),
+		},
+	}
+
+	// 书单集合索引，默认书单每种只有一个
+	bookshelfIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "deviceId", Value: 1},
+				{Key: "kind", Value: 1},
+			},
+			Options: options.Index().SetName("device_kind").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"kind": bson.M{"$exists": true}}),
+		},
+		{
+			Keys:    bson.D{{Key: "shareCode", Value: 1}},
+			Options: options.Index().SetName("share_code").SetUnique(true).SetSparse(true),
+		},
+	}
+
+	// 书单条目集合索引
+	shelfItemIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "shelfId", Value: 1},
+				{Key: "novelId", Value: 1},
+			},
+			Options: options.Index().SetName("shelf_novel").SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "shelfId", Value: 1},
+				{Key: "position", Value: 1},
+			},
+			Options: options.Index().SetName("shelf_position"),
+		},
+	}
+
+	// 阅读会话集合索引
+	readSessionIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "deviceId", Value: 1},
+				{Key: "day", Value: 1},
+			},
+			Options: options.Index().SetName("device_day"),
+		},
+	}
+
+	// 排行榜每日计数集合索引
+	rankingIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "board", Value: 1},
+				{Key: "day", Value: 1},
+				{Key: "novelId", Value: 1},
+			},
+			Options: options.Index().SetName("board_day_novel").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "day", Value: 1}},
+			Options: options.Index().SetName("ranking_day"),
+		},
 	}
 
 	// 创建索引
@@ -206,13 +474,25 @@ func (db *MongoDB) CreateIndexes(ctx context.Context) error {
 		"novels":        novelIndexes,
 		"volumes":       volumeIndexes,
 		"chapters":      chapterIndexes,
+		"chapter_index": chapterIndexIndexes,
 		"devices":       deviceIndexes,
 		"bookmarks":     bookmarkIndexes,
 		"favorites":     favoriteIndexes,
+		"follows":       followIndexes,
+		"notifications": notificationIndexes,
 		"read_history":  readHistoryIndexes,
 		"read_progress": readProgressIndexes,
+		"tombstones":    tombstoneIndexes,
 		"users":         userIndexes,
+		"sessions":      sessionIndexes,
 		"comments":      commentIndexes,
+		"comment_likes": commentLikeIndexes,
+		"reports":       reportIndexes,
+		"reviews":       reviewIndexes,
+		"rank_daily":    rankingIndexes,
+		"read_sessions": readSessionIndexes,
+		"bookshelves":   bookshelfIndexes,
+		"shelf_items":   shelfItemIndexes,
 	}
 
 	for collection, indexes := range collections {
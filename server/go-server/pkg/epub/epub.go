@@ -0,0 +1,515 @@
+// ****************************************************************************
+//
+// @file       epub.go
+// @brief      EPUB解析，提取元数据、卷章结构与图片
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package epub
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Image 章节或封面中的图片
+type Image struct {
+	Name      string // 在EPUB中的文件名
+	MediaType string
+	Data      []byte
+}
+
+// Chapter 章节
+type Chapter struct {
+	Number  int
+	Title   string
+	Content string
+	Images  []Image
+}
+
+// Volume 卷
+type Volume struct {
+	Number   int
+	Title    string
+	Chapters []Chapter
+}
+
+// Book 解析后的EPUB
+type Book struct {
+	Title       string
+	Author      string
+	Description string
+	Cover       *Image
+	Volumes     []Volume
+}
+
+// manifestItem OPF清单项
+type manifestItem struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+// opfPackage content.opf结构
+type opfPackage struct {
+	Metadata struct {
+		Titles       []string `xml:"title"`
+		Creators     []string `xml:"creator"`
+		Descriptions []string `xml:"description"`
+		Metas        []struct {
+			Name    string `xml:"name,attr"`
+			Content string `xml:"content,attr"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest struct {
+		Items []manifestItem `xml:"item"`
+	} `xml:"manifest"`
+	Spine struct {
+		Toc      string `xml:"toc,attr"`
+		ItemRefs []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+// tocEntry 目录项，Children非空时视为卷
+type tocEntry struct {
+	Title    string
+	Href     string
+	Children []tocEntry
+}
+
+// reader EPUB读取上下文
+type reader struct {
+	files    map[string]*zip.File
+	opfDir   string
+	opf      opfPackage
+	manifest map[string]manifestItem // id -> item
+	byPath   map[string]manifestItem // 完整路径 -> item
+	spine    []string                // 按阅读顺序排列的文档完整路径
+}
+
+// Open 打开并解析EPUB文件
+func Open(filename string) (*Book, error) {
+	zr, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	return Parse(&zr.Reader)
+}
+
+// Parse 从zip读取器中解析EPUB
+func Parse(zr *zip.Reader) (*Book, error) {
+	r := &reader{
+		files:    make(map[string]*zip.File),
+		manifest: make(map[string]manifestItem),
+		byPath:   make(map[string]manifestItem),
+	}
+	for _, f := range zr.File {
+		r.files[f.Name] = f
+	}
+
+	if err := r.loadPackage(); err != nil {
+		return nil, err
+	}
+
+	book := &Book{
+		Title:       firstNonEmpty(r.opf.Metadata.Titles),
+		Author:      firstNonEmpty(r.opf.Metadata.Creators),
+		Description: htmlToPlain(firstNonEmpty(r.opf.Metadata.Descriptions)),
+		Cover:       r.loadCover(),
+	}
+	if book.Author == "" {
+		book.Author = "未知作者"
+	}
+
+	volumes, err := r.buildVolumes(r.loadToc())
+	if err != nil {
+		return nil, err
+	}
+	book.Volumes = volumes
+
+	return book, nil
+}
+
+// loadPackage 通过container.xml定位并解析content.opf
+func (r *reader) loadPackage() error {
+	var container struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := r.decodeXML("META-INF/container.xml", &container); err != nil {
+		return fmt.Errorf("invalid epub container: %v", err)
+	}
+	if len(container.Rootfiles) == 0 {
+		return fmt.Errorf("invalid epub container: no rootfile")
+	}
+
+	opfPath := container.Rootfiles[0].FullPath
+	if err := r.decodeXML(opfPath, &r.opf); err != nil {
+		return fmt.Errorf("invalid epub package %s: %v", opfPath, err)
+	}
+	r.opfDir = path.Dir(opfPath)
+
+	for _, item := range r.opf.Manifest.Items {
+		r.manifest[item.ID] = item
+		r.byPath[r.resolve(r.opfDir, item.Href)] = item
+	}
+	for _, ref := range r.opf.Spine.ItemRefs {
+		if item, ok := r.manifest[ref.IDRef]; ok {
+			r.spine = append(r.spine, r.resolve(r.opfDir, item.Href))
+		}
+	}
+	if len(r.spine) == 0 {
+		return fmt.Errorf("invalid epub package %s: empty spine", opfPath)
+	}
+
+	return nil
+}
+
+// loadCover 读取封面图片，兼容EPUB2的meta和EPUB3的cover-image属性
+func (r *reader) loadCover() *Image {
+	var item manifestItem
+	var found bool
+	for _, it := range r.opf.Manifest.Items {
+		if hasProperty(it.Properties, "cover-image") {
+			item, found = it, true
+			break
+		}
+	}
+	if !found {
+		for _, meta := range r.opf.Metadata.Metas {
+			if meta.Name == "cover" {
+				item, found = r.manifest[meta.Content]
+				break
+			}
+		}
+	}
+	if !found || !strings.HasPrefix(item.MediaType, "image/") {
+		return nil
+	}
+
+	full := r.resolve(r.opfDir, item.Href)
+	data, err := r.readFile(full)
+	if err != nil {
+		return nil
+	}
+	return &Image{Name: path.Base(full), MediaType: item.MediaType, Data: data}
+}
+
+// loadToc 解析目录，优先使用EPUB3的nav文档，其次是EPUB2的NCX
+func (r *reader) loadToc() []tocEntry {
+	for _, item := range r.opf.Manifest.Items {
+		if hasProperty(item.Properties, "nav") {
+			full := r.resolve(r.opfDir, item.Href)
+			data, err := r.readFile(full)
+			if err == nil {
+				if entries := parseNav(data); len(entries) > 0 {
+					return r.resolveToc(entries, path.Dir(full))
+				}
+			}
+		}
+	}
+
+	ncx, ok := r.manifest[r.opf.Spine.Toc]
+	if !ok {
+		for _, item := range r.opf.Manifest.Items {
+			if item.MediaType == "application/x-dtbncx+xml" {
+				ncx, ok = item, true
+				break
+			}
+		}
+	}
+	if ok {
+		full := r.resolve(r.opfDir, ncx.Href)
+		var doc struct {
+			NavMap struct {
+				Points []ncxPoint `xml:"navPoint"`
+			} `xml:"navMap"`
+		}
+		if err := r.decodeXML(full, &doc); err == nil {
+			return r.resolveToc(convertNcx(doc.NavMap.Points), path.Dir(full))
+		}
+	}
+
+	return nil
+}
+
+// ncxPoint NCX目录节点
+type ncxPoint struct {
+	Label   string `xml:"navLabel>text"`
+	Content struct {
+		Src string `xml:"src,attr"`
+	} `xml:"content"`
+	Points []ncxPoint `xml:"navPoint"`
+}
+
+func convertNcx(points []ncxPoint) []tocEntry {
+	entries := make([]tocEntry, 0, len(points))
+	for _, p := range points {
+		entries = append(entries, tocEntry{
+			Title:    strings.TrimSpace(p.Label),
+			Href:     p.Content.Src,
+			Children: convertNcx(p.Points),
+		})
+	}
+	return entries
+}
+
+// resolveToc 将目录项中的相对链接解析为完整路径
+func (r *reader) resolveToc(entries []tocEntry, base string) []tocEntry {
+	for i := range entries {
+		if entries[i].Href != "" {
+			entries[i].Href = r.resolve(base, entries[i].Href)
+		}
+		entries[i].Children = r.resolveToc(entries[i].Children, base)
+	}
+	return entries
+}
+
+// buildVolumes 根据目录把spine中的文档划分为卷和章节
+func (r *reader) buildVolumes(toc []tocEntry) ([]Volume, error) {
+	spineIndex := make(map[string]int, len(r.spine))
+	for i, p := range r.spine {
+		spineIndex[p] = i
+	}
+
+	// 整理为"卷 -> 章节"两层结构：
+	// 顶层存在子节点时顶层即卷；否则整本书视为一卷
+	type group struct {
+		title   string
+		entries []tocEntry
+	}
+	var groups []group
+	nested := false
+	for _, e := range toc {
+		if len(e.Children) > 0 {
+			nested = true
+			break
+		}
+	}
+	if nested {
+		for _, e := range toc {
+			if len(e.Children) == 0 {
+				// 卷外的独立条目（如彩页、后记）并入上一卷，没有上一卷时单独成卷
+				if len(groups) == 0 {
+					groups = append(groups, group{title: e.Title})
+				}
+				groups[len(groups)-1].entries = append(groups[len(groups)-1].entries, e)
+				continue
+			}
+			entries := flattenToc(e.Children)
+			if e.Href != "" && (len(entries) == 0 || stripFragment(entries[0].Href) != stripFragment(e.Href)) {
+				// 卷本身指向的独立页面（通常是卷首插图）作为该卷的第一章
+				entries = append([]tocEntry{{Title: e.Title, Href: e.Href}}, entries...)
+			}
+			groups = append(groups, group{title: e.Title, entries: entries})
+		}
+	} else if len(toc) > 0 {
+		groups = append(groups, group{entries: toc})
+	}
+
+	// 计算每个章节在spine中的起点，章节内容覆盖到下一个章节起点之前
+	type start struct {
+		volume int
+		title  string
+		index  int
+	}
+	var starts []start
+	for gi, g := range groups {
+		for _, e := range g.entries {
+			file := stripFragment(e.Href)
+			idx, ok := spineIndex[file]
+			if !ok {
+				continue
+			}
+			if n := len(starts); n > 0 && starts[n-1].index >= idx {
+				// 同一文件内的多个锚点或乱序条目，只保留第一个
+				continue
+			}
+			starts = append(starts, start{volume: gi, title: e.Title, index: idx})
+		}
+	}
+
+	// 没有可用目录时，每个spine文档都作为一章
+	if len(starts) == 0 {
+		groups = []group{{}}
+		for i := range r.spine {
+			starts = append(starts, start{volume: 0, index: i})
+		}
+	}
+
+	volumes := make([]Volume, 0, len(groups))
+	volumeIndex := make(map[int]int)
+	for i, s := range starts {
+		end := len(r.spine)
+		if i+1 < len(starts) {
+			end = starts[i+1].index
+		}
+		// 目录之前的部分（封面、扉页等）并入第一章
+		from := s.index
+		if i == 0 {
+			from = 0
+		}
+
+		chapter, err := r.readChapter(r.spine[from:end])
+		if err != nil {
+			return nil, err
+		}
+		if chapter.Content == "" && len(chapter.Images) == 0 {
+			continue
+		}
+
+		vi, ok := volumeIndex[s.volume]
+		if !ok {
+			title := groups[s.volume].title
+			volumes = append(volumes, Volume{Number: len(volumes) + 1, Title: title})
+			vi = len(volumes) - 1
+			volumeIndex[s.volume] = vi
+		}
+
+		vol := &volumes[vi]
+		chapter.Number = len(vol.Chapters) + 1
+		chapter.Title = s.title
+		if chapter.Title == "" {
+			chapter.Title = fmt.Sprintf("第%d话", chapter.Number)
+		}
+		vol.Chapters = append(vol.Chapters, chapter)
+	}
+
+	for i := range volumes {
+		if volumes[i].Title == "" {
+			volumes[i].Title = fmt.Sprintf("第%d卷", volumes[i].Number)
+		}
+	}
+
+	return volumes, nil
+}
+
+// readChapter 读取若干个spine文档，合并为一章的文本和图片
+func (r *reader) readChapter(docs []string) (Chapter, error) {
+	var chapter Chapter
+	var parts []string
+	seen := make(map[string]bool)
+
+	for _, doc := range docs {
+		data, err := r.readFile(doc)
+		if err != nil {
+			return chapter, err
+		}
+
+		text, images := extractText(data)
+		if text != "" {
+			parts = append(parts, text)
+		}
+
+		for _, src := range images {
+			full := r.resolve(path.Dir(doc), src)
+			if seen[full] {
+				continue
+			}
+			seen[full] = true
+
+			data, err := r.readFile(full)
+			if err != nil {
+				continue
+			}
+			chapter.Images = append(chapter.Images, Image{
+				Name:      path.Base(full),
+				MediaType: r.byPath[full].MediaType,
+				Data:      data,
+			})
+		}
+	}
+
+	chapter.Content = strings.Join(parts, "\n")
+	return chapter, nil
+}
+
+func (r *reader) readFile(name string) ([]byte, error) {
+	f, ok := r.files[name]
+	if !ok {
+		return nil, fmt.Errorf("file %s not found in epub", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func (r *reader) decodeXML(name string, v interface{}) error {
+	data, err := r.readFile(name)
+	if err != nil {
+		return err
+	}
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	decoder.Strict = false
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder.Decode(v)
+}
+
+// resolve 将相对于base目录的href解析为zip内的完整路径
+func (r *reader) resolve(base, href string) string {
+	href = unescapePath(href)
+	fragment := ""
+	if i := strings.Index(href, "#"); i >= 0 {
+		href, fragment = href[:i], href[i:]
+	}
+	full := path.Clean(path.Join(base, href))
+	full = strings.TrimPrefix(full, "./")
+	return full + fragment
+}
+
+// 辅助函数：去掉路径中的锚点
+func stripFragment(href string) string {
+	if i := strings.Index(href, "#"); i >= 0 {
+		return href[:i]
+	}
+	return href
+}
+
+// 辅助函数：展开多级目录为一级章节列表
+func flattenToc(entries []tocEntry) []tocEntry {
+	var result []tocEntry
+	for _, e := range entries {
+		if e.Href != "" {
+			result = append(result, tocEntry{Title: e.Title, Href: e.Href})
+		}
+		result = append(result, flattenToc(e.Children)...)
+	}
+	return result
+}
+
+// 辅助函数：检查空格分隔的properties中是否包含指定属性
+func hasProperty(properties, name string) bool {
+	for _, p := range strings.Fields(properties) {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+func firstNonEmpty(values []string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,197 @@
+// ****************************************************************************
+//
+// @file       html.go
+// @brief      XHTML文档的文本、图片和目录提取
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package epub
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// 会产生换行的块级元素
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "li": true, "tr": true, "section": true,
+	"article": true, "blockquote": true, "pre": true, "hr": true, "dd": true, "dt": true,
+}
+
+// extractText 提取文档正文和图片链接，正文按行去除首尾空白并丢弃空行
+func extractText(data []byte) (string, []string) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	var images []string
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.ElementNode:
+			switch n.Data {
+			case "script", "style", "head":
+				return
+			case "img":
+				if src := attr(n, "src"); src != "" {
+					images = append(images, src)
+				}
+			case "image":
+				// SVG包裹的插图
+				if src := attr(n, "xlink:href", "href"); src != "" {
+					images = append(images, src)
+				}
+			}
+		case html.TextNode:
+			sb.WriteString(n.Data)
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+
+		if n.Type == html.ElementNode && blockElements[n.Data] {
+			sb.WriteByte('\n')
+		}
+	}
+	walk(doc)
+
+	return cleanLines(sb.String()), images
+}
+
+// parseNav 解析EPUB3的nav文档中的toc目录
+func parseNav(data []byte) []tocEntry {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+
+	var navs []*html.Node
+	var find func(n *html.Node)
+	find = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "nav" {
+			navs = append(navs, n)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(doc)
+	if len(navs) == 0 {
+		return nil
+	}
+
+	nav := navs[0]
+	for _, n := range navs {
+		if attr(n, "epub:type", "type") == "toc" {
+			nav = n
+			break
+		}
+	}
+
+	if ol := firstChildElement(nav, "ol"); ol != nil {
+		return parseNavList(ol)
+	}
+	return nil
+}
+
+func parseNavList(ol *html.Node) []tocEntry {
+	var entries []tocEntry
+	for li := ol.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.Data != "li" {
+			continue
+		}
+
+		var entry tocEntry
+		if a := firstChildElement(li, "a"); a != nil {
+			entry.Title = strings.TrimSpace(textContent(a))
+			entry.Href = attr(a, "href")
+		} else if span := firstChildElement(li, "span"); span != nil {
+			entry.Title = strings.TrimSpace(textContent(span))
+		}
+		if sub := firstChildElement(li, "ol"); sub != nil {
+			entry.Children = parseNavList(sub)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// htmlToPlain 将可能包含HTML标签的元数据转为纯文本
+func htmlToPlain(s string) string {
+	if !strings.Contains(s, "<") {
+		return strings.TrimSpace(s)
+	}
+	text, _ := extractText([]byte(s))
+	return text
+}
+
+// unescapePath 解码href中的百分号转义
+func unescapePath(href string) string {
+	if decoded, err := url.PathUnescape(href); err == nil {
+		return decoded
+	}
+	return href
+}
+
+func cleanLines(text string) string {
+	lines := strings.Split(text, "\n")
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			result = append(result, line)
+		}
+	}
+	return strings.Join(result, "\n")
+}
+
+func attr(n *html.Node, keys ...string) string {
+	for _, key := range keys {
+		for _, a := range n.Attr {
+			name := a.Key
+			if a.Namespace != "" {
+				name = a.Namespace + ":" + a.Key
+			}
+			if name == key || a.Key == key {
+				return a.Val
+			}
+		}
+	}
+	return ""
+}
+
+func firstChildElement(n *html.Node, tag string) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == tag {
+			return c
+		}
+	}
+	return nil
+}
+
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
@@ -31,6 +31,12 @@ const (
 	ErrInvalidParameter
 	ErrCacheOperationFailed
 	ErrDatabaseOperationFailed
+	ErrUnauthorized
+	ErrForbidden
+	ErrInvalidCredentials
+	ErrDeviceBanned
+	ErrDeviceMuted
+	ErrSensitiveContent
 )
 
 // 错误码对应的消息
@@ -48,6 +54,12 @@ var errorMessages = map[ErrorCode]string{
 	ErrInvalidParameter:        "无效的参数",
 	ErrCacheOperationFailed:    "缓存操作失败",
 	ErrDatabaseOperationFailed: "数据库操作失败",
+	ErrUnauthorized:            "未授权的访问",
+	ErrForbidden:               "没有操作权限",
+	ErrInvalidCredentials:      "用户名或密码错误",
+	ErrDeviceBanned:            "设备已被封禁",
+	ErrDeviceMuted:             "设备已被禁言",
+	ErrSensitiveContent:        "内容包含敏感词",
 }
 
 // BusinessError 业务错误类型
@@ -0,0 +1,193 @@
+// ****************************************************************************
+//
+// @file       filter.go
+// @brief      基于 Aho-Corasick 自动机的敏感词过滤
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package filter
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Match 一次敏感词命中，位置按字符计
+type Match struct {
+	Start int    // 起始位置
+	End   int    // 结束位置(不含)
+	Word  string // 命中的敏感词
+}
+
+type node struct {
+	children map[rune]int
+	fail     int
+	length   int // 以该节点结尾的最长敏感词长度，0表示不是词尾
+	output   int // 沿失败链最近的词尾节点，-1表示没有
+}
+
+// Filter 敏感词过滤器，构建后只读，可并发使用
+type Filter struct {
+	nodes []node
+	words map[int]string
+}
+
+// New 根据词表构建过滤器，忽略大小写和空白词
+func New(words []string) *Filter {
+	f := &Filter{
+		nodes: []node{{children: map[rune]int{}, output: -1}},
+		words: make(map[int]string),
+	}
+
+	for _, word := range words {
+		word = strings.TrimSpace(word)
+		if word != "" {
+			f.insert(word)
+		}
+	}
+	f.build()
+	return f
+}
+
+// LoadWords 从文件读取词表，每行一个词，#开头的行为注释
+func LoadWords(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	return words, scanner.Err()
+}
+
+// Empty 判断词表是否为空
+func (f *Filter) Empty() bool {
+	return len(f.words) == 0
+}
+
+// FindAll 查找文本中的全部敏感词，重叠的命中会合并为一段
+func (f *Filter) FindAll(text string) []Match {
+	if f.Empty() {
+		return nil
+	}
+
+	src := []rune(text)
+	var matches []Match
+	state := 0
+	for i, r := range src {
+		state = f.next(state, unicode.ToLower(r))
+		for out := f.nodes[state].output; out >= 0; out = f.nodes[f.nodes[out].fail].output {
+			start := i + 1 - f.nodes[out].length
+
+			// 与之前的命中重叠时合并
+			for len(matches) > 0 && start < matches[len(matches)-1].End {
+				start = min(start, matches[len(matches)-1].Start)
+				matches = matches[:len(matches)-1]
+			}
+			matches = append(matches, Match{Start: start, End: i + 1, Word: string(src[start : i+1])})
+		}
+	}
+
+	return matches
+}
+
+// Contains 判断文本是否包含敏感词
+func (f *Filter) Contains(text string) bool {
+	return len(f.FindAll(text)) > 0
+}
+
+// Replace 将文本中的敏感词逐字替换为mask
+func (f *Filter) Replace(text string, mask rune) string {
+	matches := f.FindAll(text)
+	if len(matches) == 0 {
+		return text
+	}
+
+	src := []rune(text)
+	for _, m := range matches {
+		for i := m.Start; i < m.End; i++ {
+			src[i] = mask
+		}
+	}
+	return string(src)
+}
+
+// insert 向字典树插入一个词
+func (f *Filter) insert(word string) {
+	state := 0
+	length := 0
+	for _, r := range strings.ToLower(word) {
+		next, ok := f.nodes[state].children[r]
+		if !ok {
+			f.nodes = append(f.nodes, node{children: map[rune]int{}, output: -1})
+			next = len(f.nodes) - 1
+			f.nodes[state].children[r] = next
+		}
+		state = next
+		length++
+	}
+	f.nodes[state].length = length
+	f.words[state] = word
+}
+
+// build 按层构建失败指针和输出链
+func (f *Filter) build() {
+	queue := make([]int, 0, len(f.nodes))
+	for _, child := range f.nodes[0].children {
+		f.nodes[child].fail = 0
+		queue = append(queue, child)
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if f.nodes[current].length > 0 {
+			f.nodes[current].output = current
+		} else {
+			f.nodes[current].output = f.nodes[f.nodes[current].fail].output
+		}
+
+		for r, child := range f.nodes[current].children {
+			fail := f.nodes[current].fail
+			for fail > 0 {
+				if _, ok := f.nodes[fail].children[r]; ok {
+					break
+				}
+				fail = f.nodes[fail].fail
+			}
+			if next, ok := f.nodes[fail].children[r]; ok && next != child {
+				f.nodes[child].fail = next
+			} else {
+				f.nodes[child].fail = 0
+			}
+			queue = append(queue, child)
+		}
+	}
+}
+
+// next 状态转移，无法匹配时沿失败指针回退
+func (f *Filter) next(state int, r rune) int {
+	for {
+		if next, ok := f.nodes[state].children[r]; ok {
+			return next
+		}
+		if state == 0 {
+			return 0
+		}
+		state = f.nodes[state].fail
+	}
+}
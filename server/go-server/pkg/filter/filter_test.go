@@ -0,0 +1,108 @@
+// ****************************************************************************
+//
+// @file       filter_test.go
+// @brief      敏感词过滤器的测试
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFindAll(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		text  string
+		want  []Match
+	}{
+		{"空词表", nil, "任意文本", nil},
+		{"只有空白词", []string{" ", ""}, "任意文本", nil},
+		{"未命中", []string{"坏词"}, "正常内容", nil},
+		{"单个命中按字符计位置", []string{"坏词"}, "这是坏词吗", []Match{{Start: 2, End: 4, Word: "坏词"}}},
+		{"多个命中", []string{"坏", "词"}, "坏的词", []Match{{Start: 0, End: 1, Word: "坏"}, {Start: 2, End: 3, Word: "词"}}},
+		{"重复命中", []string{"ab"}, "abab", []Match{{Start: 0, End: 2, Word: "ab"}, {Start: 2, End: 4, Word: "ab"}}},
+		{"包含关系取较长的范围", []string{"abc", "b"}, "xabcx", []Match{{Start: 1, End: 4, Word: "abc"}}},
+		{"重叠命中合并", []string{"abc", "cde"}, "abcde", []Match{{Start: 0, End: 5, Word: "abcde"}}},
+		{"失败指针跳转", []string{"abcd", "bce"}, "abce", []Match{{Start: 1, End: 4, Word: "bce"}}},
+		{"忽略大小写并保留原文", []string{"Bad"}, "so BAD", []Match{{Start: 3, End: 6, Word: "BAD"}}},
+		{"去掉词两端空白", []string{" 坏词 "}, "坏词", []Match{{Start: 0, End: 2, Word: "坏词"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.words).FindAll(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("FindAll(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplace(t *testing.T) {
+	f := New([]string{"坏词", "abc", "cde"})
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"未命中原样返回", "正常内容", "正常内容"},
+		{"逐字替换", "这是坏词吗", "这是**吗"},
+		{"重叠命中整段替换", "xabcdex", "x*****x"},
+		{"空文本", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.Replace(tt.text, '*'); got != tt.want {
+				t.Errorf("Replace(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContains(t *testing.T) {
+	f := New([]string{"坏词"})
+
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"这是坏词", true},
+		{"这是好词", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := f.Contains(tt.text); got != tt.want {
+			t.Errorf("Contains(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLoadWords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(path, []byte("# 注释\n坏词\n\n  另一个词  \n#另一条注释\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	words, err := LoadWords(path)
+	if err != nil {
+		t.Fatalf("LoadWords: %v", err)
+	}
+	if want := []string{"坏词", "另一个词"}; !slices.Equal(words, want) {
+		t.Errorf("LoadWords = %q, want %q", words, want)
+	}
+
+	if _, err := LoadWords(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("LoadWords on missing file: want error")
+	}
+}
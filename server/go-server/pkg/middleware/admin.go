@@ -0,0 +1,41 @@
+// ****************************************************************************
+//
+// @file       admin.go
+// @brief      管理接口鉴权中间件
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"lightnovel/pkg/errors"
+	"lightnovel/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth 校验 X-Admin-Token 中的管理令牌，Authorization 头留给用户登录令牌
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 未配置令牌时禁用全部管理接口
+		if token == "" {
+			response.Error(c, errors.NewError(errors.ErrForbidden))
+			c.Abort()
+			return
+		}
+
+		provided := c.GetHeader("X-Admin-Token")
+		if provided == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			response.Error(c, errors.NewError(errors.ErrUnauthorized))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
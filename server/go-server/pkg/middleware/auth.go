@@ -0,0 +1,68 @@
+// ****************************************************************************
+//
+// @file       auth.go
+// @brief      登录令牌中间件
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package middleware
+
+import (
+	"strings"
+
+	"lightnovel/internal/service"
+	"lightnovel/pkg/errors"
+	"lightnovel/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthMiddleware 解析 Authorization: Bearer 登录令牌，需放在 DeviceMiddleware 之后
+//
+// 登录后 deviceID 被替换为账号ID，各接口的用户数据随之归属账号；
+// 物理设备仍可通过 c.Get("device") 获取。未携带令牌时保持匿名设备身份。
+func AuthMiddleware(novelService *service.NovelService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := BearerToken(c)
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		session, err := novelService.ValidateToken(c.Request.Context(), token)
+		if err != nil {
+			response.Error(c, err)
+			c.Abort()
+			return
+		}
+
+		c.Set("userID", session.UserID)
+		c.Set("deviceID", session.UserID)
+
+		c.Next()
+	}
+}
+
+// RequireLogin 要求请求已登录
+func RequireLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("userID") == "" {
+			response.Error(c, errors.NewError(errors.ErrUnauthorized))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// BearerToken 读取 Authorization 头中的令牌
+func BearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
@@ -11,8 +11,13 @@
 package middleware
 
 import (
+	"net/http"
+	"strings"
+	"time"
+
 	"lightnovel/internal/models"
 	"lightnovel/internal/service"
+	"lightnovel/pkg/errors"
 	"lightnovel/pkg/response"
 
 	"github.com/gin-gonic/gin"
@@ -45,9 +50,65 @@ func DeviceMiddleware(novelService *service.NovelService) gin.HandlerFunc {
 
 		c.Set("deviceID", device.ID)
 		c.Set("device", device)
+		// 按IP找到的设备可能属于同一出口IP下的其他人，不能作为数据归属的依据
+		c.Set("explicitDevice", deviceID != "")
 
 		c.Header("X-Device-ID", device.ID)
 
 		c.Next()
 	}
 }
+
+// RestrictionMiddleware 封禁的设备或账号拒绝全部请求，禁言期间不能发表评论，需放在 AuthMiddleware 之后
+//
+// 登录后同时检查物理设备和账号，避免换一台设备或以账号身份绕过限制
+func RestrictionMiddleware(novelService *service.NovelService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subject := "设备"
+		var banned bool
+		var banReason string
+		var mutedUntil *time.Time
+		if value, ok := c.Get("device"); ok {
+			if device, ok := value.(*models.Device); ok {
+				banned, banReason, mutedUntil = device.Banned, device.BanReason, device.MutedUntil
+			}
+		}
+
+		if userID := c.GetString("userID"); userID != "" && !banned {
+			user, err := novelService.GetUserProfile(c.Request.Context(), userID)
+			if err != nil {
+				response.Error(c, err)
+				c.Abort()
+				return
+			}
+			if user.Banned {
+				subject, banned, banReason = "账号", true, user.BanReason
+			}
+			if later(user.MutedUntil, mutedUntil) {
+				mutedUntil = user.MutedUntil
+			}
+		}
+
+		if banned {
+			err := errors.NewErrorWithMessage(errors.ErrDeviceBanned, subject+"已被封禁")
+			if banReason != "" {
+				err = errors.NewErrorWithMessage(errors.ErrDeviceBanned, subject+"已被封禁: "+banReason)
+			}
+			response.Error(c, err)
+			c.Abort()
+			return
+		}
+		if mutedUntil != nil && mutedUntil.After(time.Now()) && c.Request.Method == http.MethodPost && strings.HasSuffix(c.FullPath(), "/comments") {
+			response.Error(c, errors.NewErrorWithMessage(errors.ErrDeviceMuted, "已被禁言至 "+mutedUntil.Format("2006-01-02 15:04")))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// later 判断禁言截止时间a是否晚于b
+func later(a, b *time.Time) bool {
+	return a != nil && (b == nil || a.After(*b))
+}
@@ -223,13 +223,13 @@ func CORS() gin.HandlerFunc {
 		if gin.Mode() != gin.ReleaseMode {
 			c.Header("Access-Control-Allow-Origin", "*")
 			c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-			c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Device-ID, Accept, X-Requested-With")
+			c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Device-ID, X-Admin-Token, Accept, X-Requested-With")
 			c.Header("Access-Control-Expose-Headers", "Content-Length, Content-Type, X-Device-ID")
 			c.Header("Access-Control-Max-Age", "86400")
 		} else {
 			c.Header("Access-Control-Allow-Origin", "*")
 			c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-			c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Device-ID, Accept, X-Requested-With")
+			c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Device-ID, X-Admin-Token, Accept, X-Requested-With")
 			c.Header("Access-Control-Expose-Headers", "Content-Length, Content-Type, X-Device-ID")
 			c.Header("Access-Control-Max-Age", "86400")
 		}
@@ -0,0 +1,73 @@
+// ****************************************************************************
+//
+// @file       textindex.go
+// @brief      章节正文的二元组(bigram)索引，在章节写入时更新，服务端和导入工具共用
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package textindex
+
+import (
+	"context"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// gramSize n-gram长度，二元组可以覆盖中文词语
+const gramSize = 2
+
+// Update 写入章节正文的n-gram索引，sourceUpdatedAt为章节的更新时间，用于判断索引是否过期
+func Update(ctx context.Context, index *mongo.Collection, novelID string, volumeNumber, chapterNumber int, content string, sourceUpdatedAt time.Time) error {
+	_, err := index.UpdateOne(ctx,
+		bson.M{"novelId": novelID, "volumeNumber": volumeNumber, "chapterNumber": chapterNumber},
+		bson.M{"$set": bson.M{
+			"grams":           Grams(content),
+			"sourceUpdatedAt": sourceUpdatedAt,
+			"updatedAt":       time.Now(),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	// 并发写入同一章节的索引时忽略唯一键冲突
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	return nil
+}
+
+// Remove 删除章节的索引
+func Remove(ctx context.Context, index *mongo.Collection, novelID string, volumeNumber, chapterNumber int) error {
+	_, err := index.DeleteOne(ctx, bson.M{"novelId": novelID, "volumeNumber": volumeNumber, "chapterNumber": chapterNumber})
+	return err
+}
+
+// Grams 将文本转为小写并去掉空白后切分为去重的n-gram
+func Grams(text string) []string {
+	var runes []rune
+	for _, r := range text {
+		if !unicode.IsSpace(r) {
+			runes = append(runes, unicode.ToLower(r))
+		}
+	}
+	if len(runes) < gramSize {
+		return nil
+	}
+
+	seen := make(map[string]struct{}, len(runes))
+	grams := make([]string, 0, len(runes))
+	for i := 0; i+gramSize <= len(runes); i++ {
+		gram := string(runes[i : i+gramSize])
+		if _, ok := seen[gram]; ok {
+			continue
+		}
+		seen[gram] = struct{}{}
+		grams = append(grams, gram)
+	}
+	return grams
+}
@@ -11,6 +11,7 @@
 package websocket
 
 import (
+	"encoding/json"
 	"log"
 	"sync"
 	"time"
@@ -32,13 +33,23 @@ const (
 	maxMessageSize = 512
 )
 
-// Client WebSocket客户端连接
+// frame 发送队列中的一条消息，id为事件日志中的ID，只有SSE连接使用
+type frame struct {
+	id   string
+	data []byte
+}
+
+// Client 客户端连接，WebSocket连接和SSE连接共用，SSE连接的conn为nil
 type Client struct {
 	hub      *Hub
 	conn     *websocket.Conn
-	send     chan []byte
+	send     chan frame
 	DeviceID string // 设备ID，用于标识客户端
 
+	// 订阅的主题和正在阅读的章节主题，由hub的锁保护
+	topics  map[string]bool
+	reading string
+
 	// 心跳相关
 	lastPing time.Time
 	closed   bool
@@ -50,7 +61,8 @@ func NewClient(hub *Hub, conn *websocket.Conn) *Client {
 	return &Client{
 		hub:      hub,
 		conn:     conn,
-		send:     make(chan []byte, 256),
+		send:     make(chan frame, 256),
+		topics:   make(map[string]bool),
 		lastPing: time.Now(),
 	}
 }
@@ -65,9 +77,7 @@ func (c *Client) ReadPump() {
 	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
-		c.mu.Lock()
-		c.lastPing = time.Now()
-		c.mu.Unlock()
+		c.touch()
 		c.conn.SetReadDeadline(time.Now().Add(pongWait))
 		return nil
 	})
@@ -80,8 +90,66 @@ func (c *Client) ReadPump() {
 			}
 			break
 		}
-		// 处理接收到的消息
-		c.hub.Broadcast <- message
+		// 处理接收到的消息，不合法的消息只回复错误，不影响连接
+		c.handleMessage(message)
+	}
+}
+
+// handleMessage 处理客户端发来的一条消息
+func (c *Client) handleMessage(data []byte) {
+	msg, perr := parseClientMessage(data)
+	if perr != nil {
+		c.reply(ServerReply{Type: ReplyError, Code: perr.Code, Message: perr.Message})
+		return
+	}
+
+	switch msg.Type {
+	case MsgSubscribe:
+		if !c.hub.subscribe(c, msg.Topic) {
+			c.reply(ServerReply{Type: ReplyError, Topic: msg.Topic, Code: ErrCodeTooManyTopics, Message: "订阅的主题数已达上限"})
+			return
+		}
+		c.reply(ServerReply{Type: ReplySubscribed, Topic: msg.Topic})
+	case MsgUnsubscribe:
+		c.hub.unsubscribe(c, msg.Topic)
+		c.reply(ServerReply{Type: ReplyUnsubscribed, Topic: msg.Topic})
+	case MsgAck:
+		if c.hub.OnAck != nil {
+			c.hub.OnAck(c.DeviceID, msg.IDs)
+		}
+		c.reply(ServerReply{Type: ReplyAcked})
+	case MsgPresence:
+		c.hub.setReading(c, msg.Topic)
+		c.reply(ServerReply{Type: ReplyPresence, Topic: msg.Topic})
+	case MsgPing:
+		c.touch()
+		c.reply(ServerReply{Type: ReplyPong})
+	}
+}
+
+// reply 回复客户端，发送队列已满时丢弃
+func (c *Client) reply(r ServerReply) {
+	r.Time = time.Now()
+	message, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	c.trySend(frame{data: message})
+}
+
+// trySend 非阻塞地将消息放入发送队列，连接已关闭或队列已满时返回false
+func (c *Client) trySend(message frame) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return false
+	}
+	select {
+	case c.send <- message:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -102,20 +170,8 @@ func (c *Client) WritePump() {
 				return
 			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			w.Write(message)
-
-			// 添加队列中的其他消息
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
-			}
-
-			if err := w.Close(); err != nil {
+			// 每条消息单独作为一个WebSocket帧发送，客户端按帧解析JSON
+			if err := c.conn.WriteMessage(websocket.TextMessage, message.data); err != nil {
 				return
 			}
 		case <-ticker.C:
@@ -127,6 +183,13 @@ func (c *Client) WritePump() {
 	}
 }
 
+// touch 更新最近一次活动时间
+func (c *Client) touch() {
+	c.mu.Lock()
+	c.lastPing = time.Now()
+	c.mu.Unlock()
+}
+
 // IsAlive 检查客户端是否存活
 func (c *Client) IsAlive() bool {
 	c.mu.RLock()
@@ -142,6 +205,8 @@ func (c *Client) Close() {
 	if !c.closed {
 		c.closed = true
 		close(c.send)
-		c.conn.Close()
+		if c.conn != nil {
+			c.conn.Close()
+		}
 	}
 }
@@ -0,0 +1,122 @@
+// ****************************************************************************
+//
+// @file       event.go
+// @brief      推送给客户端的事件类型与发布接口
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package websocket
+
+import "time"
+
+// 事件类型
+const (
+	EventNovelUpdate  = "novel_update"
+	EventCommentReply = "comment_reply"
+	EventSystemNotice = "system_notice"
+	EventPresence     = "presence"
+
+	// 章节主题上的评论事件
+	EventCommentCreated = "comment_created"
+	EventCommentDeleted = "comment_deleted"
+	EventCommentUpdated = "comment_updated"
+)
+
+// Event 推送给客户端的事件
+type Event interface {
+	EventType() string
+}
+
+// NovelUpdate 小说更新事件
+type NovelUpdate struct {
+	NovelID     string `json:"novelId"`
+	Title       string `json:"title"`
+	UpdateType  string `json:"updateType"` // "new_chapter", "new_volume", "content_update"
+	Description string `json:"description"`
+}
+
+func (NovelUpdate) EventType() string { return EventNovelUpdate }
+
+// CommentReply 评论被回复事件
+type CommentReply struct {
+	CommentID     string `json:"commentId"`
+	ParentID      string `json:"parentId"`
+	NovelID       string `json:"novelId"`
+	VolumeNumber  int    `json:"volumeNumber"`
+	ChapterNumber int    `json:"chapterNumber"`
+	Content       string `json:"content"`
+}
+
+func (CommentReply) EventType() string { return EventCommentReply }
+
+// SystemNotice 系统通知事件
+type SystemNotice struct {
+	Level   string `json:"level"`   // "info", "warning", "error"
+	Content string `json:"content"` // 通知内容
+}
+
+func (SystemNotice) EventType() string { return EventSystemNotice }
+
+// CommentCounts 评论的回复数和点赞数，单独推送时为comment_updated事件
+type CommentCounts struct {
+	CommentID  string `json:"commentId"`
+	ReplyCount int    `json:"replyCount"`
+	LikeCount  int    `json:"likeCount"`
+}
+
+func (CommentCounts) EventType() string { return EventCommentUpdated }
+
+// CommentCreated 新评论事件，回复时Root为顶层评论的最新计数
+type CommentCreated struct {
+	Comment interface{}    `json:"comment"` // 与评论列表中的评论格式相同
+	Root    *CommentCounts `json:"root,omitempty"`
+}
+
+func (CommentCreated) EventType() string { return EventCommentCreated }
+
+// CommentDeleted 评论删除事件，删除顶层评论时其回复一并删除，删除回复时Root为顶层评论的最新计数
+type CommentDeleted struct {
+	CommentID string         `json:"commentId"`
+	ParentID  string         `json:"parentId,omitempty"`
+	Root      *CommentCounts `json:"root,omitempty"`
+}
+
+func (CommentDeleted) EventType() string { return EventCommentDeleted }
+
+// PresenceUpdate 在读人数事件，定期推送给小说和章节主题的订阅者，章节主题才有卷号和章节号
+type PresenceUpdate struct {
+	NovelID       string `json:"novelId"`
+	VolumeNumber  int    `json:"volumeNumber,omitempty"`
+	ChapterNumber int    `json:"chapterNumber,omitempty"`
+	Readers       int    `json:"readers"`
+}
+
+func (PresenceUpdate) EventType() string { return EventPresence }
+
+// Message 发送给客户端的消息
+type Message struct {
+	ID   string      `json:"id,omitempty"` // 通知的推送ID，可用于确认和去重
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+	Time time.Time   `json:"time"`
+}
+
+// NewMessage 将事件包装为消息，id为对应的通知ID，不需要确认的事件为空
+func NewMessage(id string, event Event) Message {
+	return Message{ID: id, Type: event.EventType(), Data: event, Time: time.Now()}
+}
+
+// Publisher 向客户端推送消息。推送不会阻塞调用方，队列已满时丢弃消息并返回false
+type Publisher interface {
+	// Broadcast 推送给所有连接
+	Broadcast(msg Message) bool
+	// SendToDevices 推送给指定设备的所有连接
+	SendToDevices(deviceIDs []string, msg Message) bool
+	// PublishToTopic 推送给订阅了主题的连接
+	PublishToTopic(topic string, msg Message) bool
+	// OnlineDevices 返回指定设备中有连接的设备
+	OnlineDevices(deviceIDs []string) []string
+}
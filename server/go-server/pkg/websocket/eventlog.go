@@ -0,0 +1,176 @@
+// ****************************************************************************
+//
+// @file       eventlog.go
+// @brief      基于Redis Stream的短期事件日志，供SSE连接断线续传
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// 读写事件日志的超时时间
+	eventLogTimeout = time.Second
+
+	// 断线续传时最多补发的事件数
+	maxReplayEvents = 500
+)
+
+// Redis Stream的条目ID，<毫秒时间戳>-<序号>
+var eventIDPattern = regexp.MustCompile(`^[0-9]+-[0-9]+$`)
+
+// transientEvents 只反映最新状态的事件，断线后重新获取即可，不写入事件日志
+var transientEvents = map[string]bool{
+	EventCommentUpdated: true,
+	EventPresence:       true,
+}
+
+// resumable 判断该类型的消息是否写入事件日志供断线续传
+func resumable(eventType string) bool {
+	return !transientEvents[eventType]
+}
+
+// logEntry 事件日志中的一条消息，DeviceIDs和Topic都为空时为广播
+type logEntry struct {
+	ID        string          `json:"-"`                   // 事件ID，读取时由Stream给出
+	DeviceIDs []string        `json:"deviceIds,omitempty"` // 定向消息的接收设备
+	Topic     string          `json:"topic,omitempty"`     // 主题消息的主题
+	Data      json.RawMessage `json:"data"`
+}
+
+// visibleTo 判断消息是否应发给该设备，与hub的投递规则一致
+func (e *logEntry) visibleTo(deviceID string, topics map[string]bool) bool {
+	switch {
+	case len(e.DeviceIDs) > 0:
+		for _, id := range e.DeviceIDs {
+			if id == deviceID {
+				return true
+			}
+		}
+		return false
+	case e.Topic != "":
+		return topics[e.Topic]
+	default:
+		return true
+	}
+}
+
+// eventLog 事件日志，只记录广播和主题消息，保留最近maxLen条，由发出消息的实例写入
+type eventLog struct {
+	client redis.UniversalClient
+	key    string
+	maxLen int64
+}
+
+// EnableEventLog 启用事件日志，需在Run之前调用。maxLen为保留的事件数
+func (h *Hub) EnableEventLog(client redis.UniversalClient, prefix string, maxLen int64) {
+	h.events = &eventLog{
+		client: client,
+		key:    prefix + "ws:events",
+		maxLen: maxLen,
+	}
+}
+
+// appendAll 用一次往返写入需要记录的消息，返回与batch对应的事件ID，未启用、不需记录或写入失败的为空
+func (l *eventLog) appendAll(batch []outgoing) []string {
+	ids := make([]string, len(batch))
+	if l == nil {
+		return ids
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), eventLogTimeout)
+	defer cancel()
+
+	pipe := l.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(batch))
+	for i := range batch {
+		if !batch[i].logged {
+			continue
+		}
+		payload, err := json.Marshal(batch[i].entry)
+		if err != nil {
+			continue
+		}
+		cmds[i] = pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: l.key,
+			MaxLen: l.maxLen,
+			Approx: true,
+			Values: map[string]interface{}{"entry": payload},
+		})
+	}
+	if pipe.Len() == 0 {
+		return ids
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to append event log: %v", err)
+	}
+
+	for i, cmd := range cmds {
+		if cmd != nil && cmd.Err() == nil {
+			ids[i] = cmd.Val()
+		}
+	}
+	return ids
+}
+
+// since 读取lastID之后的消息，最多maxReplayEvents条
+func (l *eventLog) since(ctx context.Context, lastID string) ([]logEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, eventLogTimeout)
+	defer cancel()
+
+	messages, err := l.client.XRangeN(ctx, l.key, "("+lastID, "+", maxReplayEvents).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]logEntry, 0, len(messages))
+	for _, msg := range messages {
+		raw, ok := msg.Values["entry"].(string)
+		if !ok {
+			continue
+		}
+		var entry logEntry
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+			continue
+		}
+		entry.ID = msg.ID
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// ValidEventID 检查事件ID格式是否合法
+func ValidEventID(id string) bool {
+	return eventIDPattern.MatchString(id)
+}
+
+// eventIDAfter 判断事件ID a是否在b之后
+func eventIDAfter(a, b string) bool {
+	aMs, aSeq := splitEventID(a)
+	bMs, bSeq := splitEventID(b)
+	if aMs != bMs {
+		return aMs > bMs
+	}
+	return aSeq > bSeq
+}
+
+func splitEventID(id string) (uint64, uint64) {
+	ms, seq, _ := strings.Cut(id, "-")
+	msValue, _ := strconv.ParseUint(ms, 10, 64)
+	seqValue, _ := strconv.ParseUint(seq, 10, 64)
+	return msValue, seqValue
+}
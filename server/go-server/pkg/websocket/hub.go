@@ -10,17 +10,80 @@
 package websocket
 
 import (
+	"encoding/json"
+	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// Hub 维护活动的WebSocket连接集合
+const (
+	// 各消息队列的长度，队列满时新消息被丢弃
+	hubQueueSize = 1024
+
+	// 每次合并写入事件日志和转发的最大消息数
+	outboxBatch = 100
+)
+
+// directMessage 只发送给指定设备的消息
+type directMessage struct {
+	deviceIDs []string
+	msg       frame
+}
+
+// topicMessage 发送给订阅了某个主题的连接的消息
+type topicMessage struct {
+	topic string
+	msg   frame
+}
+
+// outgoing 等待写入事件日志和转发到其他实例的消息，写入后再放入本地队列
+type outgoing struct {
+	entry  logEntry
+	logged bool // 是否写入事件日志
+}
+
+// HubStats Hub的运行统计
+type HubStats struct {
+	Connections  int   `json:"connections"`
+	Devices      int   `json:"devices"`
+	Topics       int   `json:"topics"`
+	QueueLength  int   `json:"queueLength"`  // 等待投递的消息数
+	MessagesSent int64 `json:"messagesSent"` // 已放入连接发送队列的消息数
+	Dropped      int64 `json:"dropped"`      // 因队列已满被丢弃的消息数
+	SlowClients  int64 `json:"slowClients"`  // 因发送队列已满被断开的连接数
+}
+
+// Hub 维护活动的WebSocket连接集合，实现Publisher
 type Hub struct {
 	// 注册的客户端
 	clients map[*Client]bool
 
-	// 广播消息通道
-	Broadcast chan []byte
+	// 按设备ID索引的客户端，同一设备可能有多个连接
+	devices map[string]map[*Client]bool
+
+	// 按主题索引的订阅者
+	topics map[string]map[*Client]bool
+
+	// 广播、定向和主题消息队列
+	broadcast chan frame
+	direct    chan *directMessage
+	topicMsgs chan *topicMessage
+
+	// 启用转发或事件日志时，消息先放入该队列，由runOutbox写入Redis后再投递，发布方不等待Redis
+	outbox chan outgoing
+
+	// 客户端确认收到通知时的回调，参数为设备ID和通知ID
+	OnAck func(deviceID string, ids []string)
+
+	// 跨实例转发，未启用时为nil
+	relay *relay
+
+	// 事件日志，供SSE连接断线续传，未启用时为nil
+	events *eventLog
+
+	// 在读人数统计，未启用时为nil
+	presence *presence
 
 	// 注册请求
 	Register chan *Client
@@ -28,71 +91,322 @@ type Hub struct {
 	// 注销请求
 	Unregister chan *Client
 
-	// 互斥锁保护clients map
+	// 互斥锁保护clients、devices、topics以及各客户端订阅的主题
 	mu sync.RWMutex
 
 	// 统计信息
-	messagesSent int64
+	messagesSent atomic.Int64
+	dropped      atomic.Int64
+	slowClients  atomic.Int64
 	startTime    time.Time
 }
 
+var _ Publisher = (*Hub)(nil)
+
 // NewHub 创建一个新的Hub
 func NewHub() *Hub {
 	return &Hub{
-		Broadcast:  make(chan []byte),
+		broadcast:  make(chan frame, hubQueueSize),
+		direct:     make(chan *directMessage, hubQueueSize),
+		topicMsgs:  make(chan *topicMessage, hubQueueSize),
+		outbox:     make(chan outgoing, hubQueueSize),
 		Register:   make(chan *Client),
 		Unregister: make(chan *Client),
 		clients:    make(map[*Client]bool),
+		devices:    make(map[string]map[*Client]bool),
+		topics:     make(map[string]map[*Client]bool),
 		startTime:  time.Now(),
 	}
 }
 
 // Run 启动Hub的消息处理
 func (h *Hub) Run() {
+	if h.relay != nil || h.events != nil {
+		go h.runOutbox()
+	}
+
 	for {
 		select {
 		case client := <-h.Register:
 			h.mu.Lock()
 			h.clients[client] = true
+			if h.devices[client.DeviceID] == nil {
+				h.devices[client.DeviceID] = make(map[*Client]bool)
+			}
+			h.devices[client.DeviceID][client] = true
 			h.mu.Unlock()
+			if h.relay != nil {
+				go h.relay.markOnline([]string{client.DeviceID})
+			}
 
 		case client := <-h.Unregister:
 			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-			}
+			h.removeClient(client)
 			h.mu.Unlock()
 
-		case message := <-h.Broadcast:
-			h.mu.RLock()
+		case message := <-h.broadcast:
+			h.mu.Lock()
 			for client := range h.clients {
-				if client.IsAlive() {
-					select {
-					case client.send <- message:
-						h.messagesSent++
-					default:
-						h.mu.RUnlock()
-						h.mu.Lock()
-						delete(h.clients, client)
-						close(client.send)
-						h.mu.Unlock()
-						h.mu.RLock()
-					}
-				} else {
-					h.mu.RUnlock()
-					h.mu.Lock()
-					delete(h.clients, client)
-					close(client.send)
-					h.mu.Unlock()
-					h.mu.RLock()
+				h.deliver(client, message)
+			}
+			h.mu.Unlock()
+
+		case message := <-h.direct:
+			h.mu.Lock()
+			for _, deviceID := range message.deviceIDs {
+				for client := range h.devices[deviceID] {
+					h.deliver(client, message.msg)
 				}
 			}
-			h.mu.RUnlock()
+			h.mu.Unlock()
+
+		case message := <-h.topicMsgs:
+			h.mu.Lock()
+			for client := range h.topics[message.topic] {
+				h.deliver(client, message.msg)
+			}
+			h.mu.Unlock()
+		}
+	}
+}
+
+// Broadcast 向所有连接发送消息，启用转发时同时发给其他实例
+func (h *Hub) Broadcast(msg Message) bool {
+	data, ok := h.encode(msg)
+	if !ok {
+		return false
+	}
+	return h.publish(logEntry{Data: data}, resumable(msg.Type))
+}
+
+// SendToDevices 向指定设备的所有连接发送消息，不在线的设备忽略，启用转发时同时发给其他实例。
+// 定向消息都是通知，由通知列表补发，不写入事件日志
+func (h *Hub) SendToDevices(deviceIDs []string, msg Message) bool {
+	if len(deviceIDs) == 0 {
+		return true
+	}
+	data, ok := h.encode(msg)
+	if !ok {
+		return false
+	}
+	return h.publish(logEntry{DeviceIDs: deviceIDs, Data: data}, false)
+}
+
+// PublishToTopic 向订阅了主题的所有连接发送消息，启用转发时同时发给其他实例
+func (h *Hub) PublishToTopic(topic string, msg Message) bool {
+	data, ok := h.encode(msg)
+	if !ok {
+		return false
+	}
+	return h.publish(logEntry{Topic: topic, Data: data}, resumable(msg.Type))
+}
+
+// publish 不需要写入Redis的消息直接放入本地队列，否则放入outbox，队列已满时丢弃
+func (h *Hub) publish(entry logEntry, logged bool) bool {
+	logged = logged && h.events != nil
+	if h.relay == nil && !logged {
+		return h.enqueue(entry, "")
+	}
+
+	select {
+	case h.outbox <- outgoing{entry: entry, logged: logged}:
+		return true
+	default:
+		h.dropped.Add(1)
+		return false
+	}
+}
+
+// runOutbox 按顺序将outbox中的消息成批写入事件日志并转发，再带上事件ID放入本地队列
+func (h *Hub) runOutbox() {
+	batch := make([]outgoing, 0, outboxBatch)
+	for first := range h.outbox {
+		batch = append(batch[:0], first)
+	drain:
+		for len(batch) < outboxBatch {
+			select {
+			case next := <-h.outbox:
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+
+		ids := h.events.appendAll(batch)
+		if h.relay != nil {
+			h.relay.publishAll(batch, ids)
+		}
+		for i := range batch {
+			h.enqueue(batch[i].entry, ids[i])
 		}
 	}
 }
 
+// enqueue 按消息的接收范围放入对应的本地队列
+func (h *Hub) enqueue(entry logEntry, id string) bool {
+	f := frame{id: id, data: entry.Data}
+	switch {
+	case len(entry.DeviceIDs) > 0:
+		return h.enqueueDirect(entry.DeviceIDs, f)
+	case entry.Topic != "":
+		return h.enqueueTopic(entry.Topic, f)
+	default:
+		return h.enqueueBroadcast(f)
+	}
+}
+
+// OnlineDevices 返回指定设备中有连接的设备，启用转发时包含连接在其他实例的设备。
+// 查询其他实例失败时按全部在线返回，由各实例投递时忽略没有连接的设备
+func (h *Hub) OnlineDevices(deviceIDs []string) []string {
+	h.mu.RLock()
+	online := make([]string, 0, len(deviceIDs))
+	var remote []string
+	for _, deviceID := range deviceIDs {
+		if len(h.devices[deviceID]) > 0 {
+			online = append(online, deviceID)
+		} else {
+			remote = append(remote, deviceID)
+		}
+	}
+	h.mu.RUnlock()
+
+	if h.relay == nil || len(remote) == 0 {
+		return online
+	}
+	elsewhere, err := h.relay.online(remote)
+	if err != nil {
+		log.Printf("Failed to load online devices: %v", err)
+		return deviceIDs
+	}
+	return append(online, elsewhere...)
+}
+
+// encode 序列化消息
+func (h *Hub) encode(msg Message) ([]byte, bool) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Failed to marshal %s message: %v", msg.Type, err)
+		return nil, false
+	}
+	return data, true
+}
+
+// enqueueBroadcast 将广播消息放入队列，队列已满时丢弃
+func (h *Hub) enqueueBroadcast(msg frame) bool {
+	select {
+	case h.broadcast <- msg:
+		return true
+	default:
+		h.dropped.Add(1)
+		return false
+	}
+}
+
+// enqueueDirect 将定向消息放入队列，队列已满时丢弃
+func (h *Hub) enqueueDirect(deviceIDs []string, msg frame) bool {
+	select {
+	case h.direct <- &directMessage{deviceIDs: deviceIDs, msg: msg}:
+		return true
+	default:
+		h.dropped.Add(1)
+		return false
+	}
+}
+
+// enqueueTopic 将主题消息放入队列，队列已满时丢弃
+func (h *Hub) enqueueTopic(topic string, msg frame) bool {
+	select {
+	case h.topicMsgs <- &topicMessage{topic: topic, msg: msg}:
+		return true
+	default:
+		h.dropped.Add(1)
+		return false
+	}
+}
+
+// subscribe 订阅主题，超过每个连接的订阅上限时返回false
+func (h *Hub) subscribe(client *Client, topic string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if client.topics[topic] {
+		return true
+	}
+	if len(client.topics) >= maxTopicsPerClient {
+		return false
+	}
+
+	client.topics[topic] = true
+	if h.topics[topic] == nil {
+		h.topics[topic] = make(map[*Client]bool)
+		if h.relay != nil {
+			h.relay.topicsChanged()
+		}
+	}
+	h.topics[topic][client] = true
+	return true
+}
+
+// unsubscribe 取消订阅主题
+func (h *Hub) unsubscribe(client *Client, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leaveTopic(client, topic)
+}
+
+// leaveTopic 将客户端移出主题，调用方需持有写锁
+func (h *Hub) leaveTopic(client *Client, topic string) {
+	delete(client.topics, topic)
+	if subscribers := h.topics[topic]; subscribers != nil {
+		delete(subscribers, client)
+		if len(subscribers) == 0 {
+			delete(h.topics, topic)
+			if h.relay != nil {
+				h.relay.topicsChanged()
+			}
+		}
+	}
+}
+
+// deliver 向客户端发送消息，客户端已断开或发送队列已满时移除，调用方需持有写锁
+func (h *Hub) deliver(client *Client, message frame) {
+	if !client.IsAlive() {
+		h.removeClient(client)
+		return
+	}
+	if !client.trySend(message) {
+		h.slowClients.Add(1)
+		h.removeClient(client)
+		return
+	}
+	h.messagesSent.Add(1)
+}
+
+// removeClient 移除并关闭客户端，调用方需持有写锁
+func (h *Hub) removeClient(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+
+	delete(h.clients, client)
+	for topic := range client.topics {
+		h.leaveTopic(client, topic)
+	}
+	if conns := h.devices[client.DeviceID]; conns != nil {
+		delete(conns, client)
+		if len(conns) == 0 {
+			delete(h.devices, client.DeviceID)
+		}
+	}
+	if reading := client.reading; reading != "" {
+		client.reading = ""
+		if h.presence != nil && !h.deviceReading(client.DeviceID, reading) {
+			go h.presence.leave(client.DeviceID, reading)
+		}
+	}
+	client.Close()
+}
+
 // GetActiveConnections 获取当前活动连接数
 func (h *Hub) GetActiveConnections() int {
 	h.mu.RLock()
@@ -100,11 +414,38 @@ func (h *Hub) GetActiveConnections() int {
 	return len(h.clients)
 }
 
+// GetOnlineDevices 获取当前在线的设备数
+func (h *Hub) GetOnlineDevices() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.devices)
+}
+
+// GetTopicCount 获取当前有订阅者的主题数
+func (h *Hub) GetTopicCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.topics)
+}
+
 // GetMessagesSent 获取已发送消息数量
 func (h *Hub) GetMessagesSent() int64 {
+	return h.messagesSent.Load()
+}
+
+// Stats 获取运行统计
+func (h *Hub) Stats() HubStats {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	return h.messagesSent
+	return HubStats{
+		Connections:  len(h.clients),
+		Devices:      len(h.devices),
+		Topics:       len(h.topics),
+		QueueLength:  len(h.broadcast) + len(h.direct) + len(h.topicMsgs) + len(h.outbox),
+		MessagesSent: h.messagesSent.Load(),
+		Dropped:      h.dropped.Load(),
+		SlowClients:  h.slowClients.Load(),
+	}
 }
 
 // GetStartTime 获取服务启动时间
@@ -0,0 +1,281 @@
+// ****************************************************************************
+//
+// @file       presence.go
+// @brief      正在阅读的人数统计，存储在Redis中供多个实例共享
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// 超过该时间未刷新的阅读记录视为已离开
+	presenceTTL = 90 * time.Second
+
+	// 刷新本实例的阅读记录并推送在读人数的间隔，必须小于presenceTTL
+	presenceInterval = 20 * time.Second
+
+	// 读写阅读记录的超时时间
+	presenceTimeout = 2 * time.Second
+)
+
+// ChapterPresence 章节的在读人数
+type ChapterPresence struct {
+	VolumeNumber  int `json:"volumeNumber"`
+	ChapterNumber int `json:"chapterNumber"`
+	Readers       int `json:"readers"`
+}
+
+// NovelPresence 小说的在读人数，同一设备只计一次
+type NovelPresence struct {
+	NovelID  string            `json:"novelId"`
+	Readers  int               `json:"readers"`
+	Chapters []ChapterPresence `json:"chapters"`
+}
+
+// chapter 获取章节的在读人数
+func (p *NovelPresence) chapter(volumeNumber, chapterNumber int) int {
+	for _, c := range p.Chapters {
+		if c.VolumeNumber == volumeNumber && c.ChapterNumber == chapterNumber {
+			return c.Readers
+		}
+	}
+	return 0
+}
+
+// reader 正在阅读某一章节的设备
+type reader struct {
+	deviceID string
+	topic    string
+}
+
+// presence 阅读记录。每部小说一个有序集合，成员为<卷号>:<章节号>:<设备ID>，分数为最近一次刷新的时间
+type presence struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// EnablePresence 启用在读人数统计，需在Run之前调用
+func (h *Hub) EnablePresence(ctx context.Context, client redis.UniversalClient, prefix string) {
+	h.presence = &presence{
+		client: client,
+		prefix: prefix + "presence:",
+	}
+	go h.runPresence(ctx)
+}
+
+// Presence 获取小说及其各章节的在读人数，未启用时返回空结果
+func (h *Hub) Presence(ctx context.Context, novelID string) (*NovelPresence, error) {
+	if h.presence == nil {
+		return &NovelPresence{NovelID: novelID, Chapters: []ChapterPresence{}}, nil
+	}
+	return h.presence.novel(ctx, novelID)
+}
+
+// setReading 更新连接正在阅读的章节，topic为空表示停止阅读
+func (h *Hub) setReading(client *Client, topic string) {
+	h.mu.Lock()
+	previous := client.reading
+	client.reading = topic
+	left := previous != "" && previous != topic && !h.deviceReading(client.DeviceID, previous)
+	h.mu.Unlock()
+
+	if h.presence == nil {
+		return
+	}
+	if left {
+		h.presence.leave(client.DeviceID, previous)
+	}
+	if topic != "" {
+		h.presence.touch([]reader{{deviceID: client.DeviceID, topic: topic}})
+	}
+}
+
+// deviceReading 判断设备是否还有连接在阅读该章节，调用方需持有锁
+func (h *Hub) deviceReading(deviceID, topic string) bool {
+	for client := range h.devices[deviceID] {
+		if client.reading == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// runPresence 定期刷新本实例的阅读记录，并向订阅了相关主题的本地连接推送在读人数
+func (h *Hub) runPresence(ctx context.Context) {
+	ticker := time.NewTicker(presenceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			readers, topics := h.presenceTargets()
+			h.presence.touch(readers)
+			h.pushPresence(ctx, topics)
+		}
+	}
+}
+
+// presenceTargets 收集本实例正在阅读的设备和有订阅者的主题
+func (h *Hub) presenceTargets() ([]reader, []string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	readers := make([]reader, 0)
+	for client := range h.clients {
+		if client.reading != "" {
+			readers = append(readers, reader{deviceID: client.DeviceID, topic: client.reading})
+		}
+	}
+	topics := make([]string, 0, len(h.topics))
+	for topic := range h.topics {
+		topics = append(topics, topic)
+	}
+	return readers, topics
+}
+
+// pushPresence 向本地订阅者推送在读人数，各实例只推送给自己的连接，不经过转发和事件日志
+func (h *Hub) pushPresence(ctx context.Context, topics []string) {
+	novels := make(map[string]*NovelPresence)
+	for _, topic := range topics {
+		novelID, volumeNumber, chapterNumber, ok := parseTopic(topic)
+		if !ok {
+			continue
+		}
+
+		np, loaded := novels[novelID]
+		if !loaded {
+			var err error
+			if np, err = h.presence.novel(ctx, novelID); err != nil {
+				log.Printf("Failed to load presence of novel %s: %v", novelID, err)
+				continue
+			}
+			novels[novelID] = np
+		}
+
+		event := PresenceUpdate{NovelID: novelID, Readers: np.Readers}
+		if volumeNumber > 0 {
+			event.VolumeNumber = volumeNumber
+			event.ChapterNumber = chapterNumber
+			event.Readers = np.chapter(volumeNumber, chapterNumber)
+		}
+		if data, ok := h.encode(NewMessage("", event)); ok {
+			h.enqueueTopic(topic, frame{data: data})
+		}
+	}
+}
+
+// touch 刷新设备的阅读记录
+func (p *presence) touch(readers []reader) {
+	if len(readers) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), presenceTimeout)
+	defer cancel()
+
+	now := float64(time.Now().Unix())
+	pipe := p.client.Pipeline()
+	for _, r := range readers {
+		key, member, ok := p.member(r)
+		if !ok {
+			continue
+		}
+		pipe.ZAdd(ctx, key, redis.Z{Score: now, Member: member})
+		pipe.Expire(ctx, key, presenceTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to refresh presence: %v", err)
+	}
+}
+
+// leave 删除设备在某一章节的阅读记录
+func (p *presence) leave(deviceID, topic string) {
+	key, member, ok := p.member(reader{deviceID: deviceID, topic: topic})
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), presenceTimeout)
+	defer cancel()
+	if err := p.client.ZRem(ctx, key, member).Err(); err != nil {
+		log.Printf("Failed to remove presence of %s: %v", deviceID, err)
+	}
+}
+
+// novel 统计小说的在读人数，顺带清理过期的记录
+func (p *presence) novel(ctx context.Context, novelID string) (*NovelPresence, error) {
+	ctx, cancel := context.WithTimeout(ctx, presenceTimeout)
+	defer cancel()
+
+	key := p.prefix + novelID
+	expired := strconv.FormatInt(time.Now().Add(-presenceTTL).Unix(), 10)
+	pipe := p.client.Pipeline()
+	pipe.ZRemRangeByScore(ctx, key, "-inf", "("+expired)
+	members := pipe.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: expired, Max: "+inf"})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	devices := make(map[string]bool)
+	chapters := make(map[[2]int]int)
+	for _, member := range members.Val() {
+		parts := strings.SplitN(member, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		volumeNumber, err1 := strconv.Atoi(parts[0])
+		chapterNumber, err2 := strconv.Atoi(parts[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		devices[parts[2]] = true
+		chapters[[2]int{volumeNumber, chapterNumber}]++
+	}
+
+	result := &NovelPresence{
+		NovelID:  novelID,
+		Readers:  len(devices),
+		Chapters: make([]ChapterPresence, 0, len(chapters)),
+	}
+	for chapter, readers := range chapters {
+		result.Chapters = append(result.Chapters, ChapterPresence{
+			VolumeNumber:  chapter[0],
+			ChapterNumber: chapter[1],
+			Readers:       readers,
+		})
+	}
+	sort.Slice(result.Chapters, func(i, j int) bool {
+		a, b := result.Chapters[i], result.Chapters[j]
+		if a.VolumeNumber != b.VolumeNumber {
+			return a.VolumeNumber < b.VolumeNumber
+		}
+		return a.ChapterNumber < b.ChapterNumber
+	})
+	return result, nil
+}
+
+// member 获取阅读记录所在的键和成员
+func (p *presence) member(r reader) (string, string, bool) {
+	novelID, volumeNumber, chapterNumber, ok := parseTopic(r.topic)
+	if !ok || volumeNumber == 0 {
+		return "", "", false
+	}
+	return p.prefix + novelID, fmt.Sprintf("%d:%d:%s", volumeNumber, chapterNumber, r.deviceID), true
+}
@@ -0,0 +1,179 @@
+// ****************************************************************************
+//
+// @file       protocol.go
+// @brief      WebSocket客户端消息协议与订阅主题
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 客户端消息类型
+const (
+	MsgSubscribe   = "subscribe"   // 订阅主题
+	MsgUnsubscribe = "unsubscribe" // 取消订阅
+	MsgAck         = "ack"         // 确认收到通知
+	MsgPing        = "ping"        // 应用层心跳
+	MsgPresence    = "presence"    // 告知正在阅读的章节
+)
+
+// 服务端回复类型
+const (
+	ReplySubscribed   = "subscribed"
+	ReplyUnsubscribed = "unsubscribed"
+	ReplyAcked        = "acked"
+	ReplyPong         = "pong"
+	ReplyPresence     = "presence_updated"
+	ReplyError        = "error"
+)
+
+// 协议错误码
+const (
+	ErrCodeInvalidMessage = "invalid_message" // 不是合法的JSON或缺少字段
+	ErrCodeUnknownType    = "unknown_type"    // 不支持的消息类型
+	ErrCodeInvalidTopic   = "invalid_topic"   // 主题格式错误
+	ErrCodeTooManyTopics  = "too_many_topics" // 订阅的主题数超过上限
+)
+
+const (
+	// 每个连接最多订阅的主题数
+	maxTopicsPerClient = 20
+
+	// 单条确认消息最多包含的通知数，受maxMessageSize限制
+	maxAckIDs = 16
+)
+
+var (
+	// novel:<小说ID>
+	novelTopicPattern = regexp.MustCompile(`^novel:[0-9a-f]{24}$`)
+	// chapter:<小说ID>:<卷号>:<章节号>
+	chapterTopicPattern = regexp.MustCompile(`^chapter:[0-9a-f]{24}:[1-9][0-9]{0,4}:[1-9][0-9]{0,4}$`)
+)
+
+// ClientMessage 客户端发送的消息
+type ClientMessage struct {
+	Type  string   `json:"type"`
+	Topic string   `json:"topic,omitempty"` // subscribe、unsubscribe使用，presence时为正在阅读的章节主题，为空表示停止阅读
+	IDs   []string `json:"ids,omitempty"`   // ack使用，确认的通知ID
+}
+
+// ServerReply 服务端对客户端消息的回复
+type ServerReply struct {
+	Type    string    `json:"type"`
+	Topic   string    `json:"topic,omitempty"`
+	Code    string    `json:"code,omitempty"`    // 错误码，仅error回复有
+	Message string    `json:"message,omitempty"` // 错误说明
+	Time    time.Time `json:"time"`
+}
+
+// NovelTopic 小说主题，推送小说级别的事件
+func NovelTopic(novelID string) string {
+	return "novel:" + novelID
+}
+
+// ChapterTopic 章节主题，推送章节级别的事件
+func ChapterTopic(novelID string, volumeNumber, chapterNumber int) string {
+	return fmt.Sprintf("chapter:%s:%d:%d", novelID, volumeNumber, chapterNumber)
+}
+
+// parseTopic 解析主题中的小说ID和章节，小说主题的卷号和章节号为0
+func parseTopic(topic string) (novelID string, volumeNumber, chapterNumber int, ok bool) {
+	switch {
+	case novelTopicPattern.MatchString(topic):
+		return strings.TrimPrefix(topic, "novel:"), 0, 0, true
+	case chapterTopicPattern.MatchString(topic):
+		parts := strings.Split(topic, ":")
+		volumeNumber, _ = strconv.Atoi(parts[2])
+		chapterNumber, _ = strconv.Atoi(parts[3])
+		return parts[1], volumeNumber, chapterNumber, true
+	default:
+		return "", 0, 0, false
+	}
+}
+
+// ValidTopic 检查主题格式是否合法
+func ValidTopic(topic string) bool {
+	return novelTopicPattern.MatchString(topic) || chapterTopicPattern.MatchString(topic)
+}
+
+// ParseTopics 解析逗号分隔的主题列表，供SSE连接使用，规则与subscribe消息一致
+func ParseTopics(raw string) ([]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	seen := make(map[string]bool)
+	topics := make([]string, 0)
+	for _, topic := range strings.Split(raw, ",") {
+		topic = strings.TrimSpace(topic)
+		if !ValidTopic(topic) {
+			return nil, fmt.Errorf("主题%q格式错误", topic)
+		}
+		if !seen[topic] {
+			seen[topic] = true
+			topics = append(topics, topic)
+		}
+	}
+	if len(topics) > maxTopicsPerClient {
+		return nil, fmt.Errorf("最多订阅%d个主题", maxTopicsPerClient)
+	}
+	return topics, nil
+}
+
+// protocolError 客户端消息不合法时返回给客户端的错误
+type protocolError struct {
+	Code    string
+	Message string
+}
+
+func (e *protocolError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// parseClientMessage 解析并校验客户端消息
+func parseClientMessage(data []byte) (*ClientMessage, *protocolError) {
+	if len(data) > maxMessageSize {
+		return nil, &protocolError{ErrCodeInvalidMessage, "消息过长"}
+	}
+
+	var msg ClientMessage
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&msg); err != nil {
+		return nil, &protocolError{ErrCodeInvalidMessage, "消息格式错误"}
+	}
+
+	switch msg.Type {
+	case MsgSubscribe, MsgUnsubscribe:
+		if !ValidTopic(msg.Topic) {
+			return nil, &protocolError{ErrCodeInvalidTopic, "主题格式应为novel:<小说ID>或chapter:<小说ID>:<卷号>:<章节号>"}
+		}
+	case MsgAck:
+		if len(msg.IDs) == 0 || len(msg.IDs) > maxAckIDs {
+			return nil, &protocolError{ErrCodeInvalidMessage, fmt.Sprintf("ids应包含1到%d个通知ID", maxAckIDs)}
+		}
+	case MsgPresence:
+		if msg.Topic != "" && !chapterTopicPattern.MatchString(msg.Topic) {
+			return nil, &protocolError{ErrCodeInvalidTopic, "正在阅读的章节应为chapter:<小说ID>:<卷号>:<章节号>"}
+		}
+	case MsgPing:
+	case "":
+		return nil, &protocolError{ErrCodeInvalidMessage, "缺少消息类型"}
+	default:
+		return nil, &protocolError{ErrCodeUnknownType, fmt.Sprintf("不支持的消息类型%q", msg.Type)}
+	}
+
+	return &msg, nil
+}
@@ -0,0 +1,297 @@
+// ****************************************************************************
+//
+// @file       relay.go
+// @brief      通过Redis发布订阅在多个实例间转发WebSocket消息
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// relayEnvelope 在实例间转发的消息，Node为发出消息的实例，用于忽略自己发出的消息
+type relayEnvelope struct {
+	Node      string   `json:"node"`
+	DeviceIDs []string `json:"deviceIds,omitempty"` // 定向消息的接收设备
+	EventID   string   `json:"eventId,omitempty"`   // 事件日志中的ID
+	Data      []byte   `json:"data"`
+}
+
+const (
+	// 发布一条转发消息的超时时间
+	relayTimeout = 2 * time.Second
+
+	// 订阅主题频道失败后重试的间隔
+	relayRetry = time.Second
+
+	// 超过该时间未刷新的在线记录视为已断开
+	onlineTTL = 90 * time.Second
+
+	// 刷新本实例在线设备的间隔，必须小于onlineTTL
+	onlineInterval = 30 * time.Second
+)
+
+// relay Redis转发器。广播和定向消息各用一个频道，主题消息每个主题一个频道，只订阅本实例有订阅者的主题
+type relay struct {
+	client redis.UniversalClient
+	pubsub *redis.PubSub
+	nodeID string
+	prefix string
+
+	// 本地主题有变化时的通知，不阻塞持有hub锁的调用方，由syncTopics按hub的当前主题重新同步
+	changed chan struct{}
+
+	// 已订阅的主题，只由syncTopics访问
+	subscribed map[string]bool
+}
+
+// EnableRelay 启用跨实例转发，需在Run之前调用。nodeID为空时随机生成
+func (h *Hub) EnableRelay(ctx context.Context, client redis.UniversalClient, prefix, nodeID string) error {
+	if nodeID == "" {
+		nodeID = uuid.NewString()
+	}
+
+	r := &relay{
+		client:     client,
+		nodeID:     nodeID,
+		prefix:     prefix + "ws:",
+		changed:    make(chan struct{}, 1),
+		subscribed: make(map[string]bool),
+	}
+	r.pubsub = client.Subscribe(ctx, r.broadcastChannel(), r.directChannel())
+	if _, err := r.pubsub.Receive(ctx); err != nil {
+		r.pubsub.Close()
+		return err
+	}
+
+	h.relay = r
+	go r.receive(ctx, h)
+	go r.syncTopics(ctx, h)
+	go r.refreshOnline(ctx, h)
+	return nil
+}
+
+// NodeID 获取本实例的ID，未启用转发时为空
+func (h *Hub) NodeID() string {
+	if h.relay == nil {
+		return ""
+	}
+	return h.relay.nodeID
+}
+
+// publishAll 用一次往返将消息发布到其他实例，ids为各消息在事件日志中的ID
+func (r *relay) publishAll(batch []outgoing, ids []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), relayTimeout)
+	defer cancel()
+
+	pipe := r.client.Pipeline()
+	for i := range batch {
+		entry := &batch[i].entry
+		payload, err := json.Marshal(relayEnvelope{Node: r.nodeID, DeviceIDs: entry.DeviceIDs, EventID: ids[i], Data: entry.Data})
+		if err != nil {
+			continue
+		}
+		pipe.Publish(ctx, r.channel(entry), payload)
+	}
+	if pipe.Len() == 0 {
+		return
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to relay websocket messages: %v", err)
+	}
+}
+
+// receive 将其他实例发布的消息交给本地hub投递
+func (r *relay) receive(ctx context.Context, h *Hub) {
+	defer r.pubsub.Close()
+
+	messages := r.pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			var envelope relayEnvelope
+			if err := json.Unmarshal([]byte(msg.Payload), &envelope); err != nil {
+				log.Printf("Failed to decode relayed websocket message: %v", err)
+				continue
+			}
+			if envelope.Node == r.nodeID {
+				continue
+			}
+
+			f := frame{id: envelope.EventID, data: envelope.Data}
+			switch {
+			case msg.Channel == r.broadcastChannel():
+				h.enqueueBroadcast(f)
+			case msg.Channel == r.directChannel():
+				h.enqueueDirect(envelope.DeviceIDs, f)
+			case strings.HasPrefix(msg.Channel, r.topicPrefix()):
+				h.enqueueTopic(strings.TrimPrefix(msg.Channel, r.topicPrefix()), f)
+			}
+		}
+	}
+}
+
+// topicsChanged 通知本地主题有变化，已有未处理的通知时直接返回，可在持有hub锁时调用
+func (r *relay) topicsChanged() {
+	select {
+	case r.changed <- struct{}{}:
+	default:
+	}
+}
+
+// syncTopics 本地主题变化后，按hub当前有订阅者的主题订阅或取消订阅主题频道
+func (r *relay) syncTopics(ctx context.Context, h *Hub) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.changed:
+		}
+
+		h.mu.RLock()
+		current := make(map[string]bool, len(h.topics))
+		for topic := range h.topics {
+			current[topic] = true
+		}
+		h.mu.RUnlock()
+
+		var subscribe, unsubscribe []string
+		for topic := range current {
+			if !r.subscribed[topic] {
+				subscribe = append(subscribe, r.topicChannel(topic))
+			}
+		}
+		for topic := range r.subscribed {
+			if !current[topic] {
+				unsubscribe = append(unsubscribe, r.topicChannel(topic))
+			}
+		}
+
+		if len(subscribe) > 0 {
+			if err := r.pubsub.Subscribe(ctx, subscribe...); err != nil {
+				log.Printf("Failed to subscribe relay topics: %v", err)
+				time.AfterFunc(relayRetry, r.topicsChanged)
+				continue
+			}
+		}
+		if len(unsubscribe) > 0 {
+			if err := r.pubsub.Unsubscribe(ctx, unsubscribe...); err != nil {
+				log.Printf("Failed to unsubscribe relay topics: %v", err)
+				time.AfterFunc(relayRetry, r.topicsChanged)
+				continue
+			}
+		}
+		r.subscribed = current
+	}
+}
+
+// refreshOnline 定期刷新本实例有连接的设备，供各实例判断设备是否在线
+func (r *relay) refreshOnline(ctx context.Context, h *Hub) {
+	ticker := time.NewTicker(onlineInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.mu.RLock()
+			deviceIDs := make([]string, 0, len(h.devices))
+			for deviceID := range h.devices {
+				deviceIDs = append(deviceIDs, deviceID)
+			}
+			h.mu.RUnlock()
+			r.markOnline(deviceIDs)
+		}
+	}
+}
+
+// markOnline 刷新设备的在线记录，并清除过期的记录
+func (r *relay) markOnline(deviceIDs []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), relayTimeout)
+	defer cancel()
+
+	now := time.Now()
+	pipe := r.client.Pipeline()
+	if len(deviceIDs) > 0 {
+		members := make([]redis.Z, 0, len(deviceIDs))
+		for _, deviceID := range deviceIDs {
+			members = append(members, redis.Z{Score: float64(now.Unix()), Member: deviceID})
+		}
+		pipe.ZAdd(ctx, r.onlineKey(), members...)
+	}
+	pipe.ZRemRangeByScore(ctx, r.onlineKey(), "-inf", strconv.FormatInt(now.Add(-onlineTTL).Unix(), 10))
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to refresh online devices: %v", err)
+	}
+}
+
+// online 返回指定设备中在任一实例有连接的设备
+func (r *relay) online(deviceIDs []string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), relayTimeout)
+	defer cancel()
+
+	scores, err := r.client.ZMScore(ctx, r.onlineKey(), deviceIDs...).Result()
+	if err != nil {
+		return nil, err
+	}
+	since := float64(time.Now().Add(-onlineTTL).Unix())
+	online := make([]string, 0, len(deviceIDs))
+	for i, score := range scores {
+		if score >= since {
+			online = append(online, deviceIDs[i])
+		}
+	}
+	return online, nil
+}
+
+// channel 获取消息按接收范围发布的频道
+func (r *relay) channel(entry *logEntry) string {
+	switch {
+	case len(entry.DeviceIDs) > 0:
+		return r.directChannel()
+	case entry.Topic != "":
+		return r.topicChannel(entry.Topic)
+	default:
+		return r.broadcastChannel()
+	}
+}
+
+func (r *relay) onlineKey() string {
+	return r.prefix + "online"
+}
+
+func (r *relay) broadcastChannel() string {
+	return r.prefix + "broadcast"
+}
+
+func (r *relay) directChannel() string {
+	return r.prefix + "direct"
+}
+
+func (r *relay) topicPrefix() string {
+	return r.prefix + "topic:"
+}
+
+func (r *relay) topicChannel(topic string) string {
+	return r.topicPrefix() + topic
+}
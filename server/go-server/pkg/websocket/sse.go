@@ -0,0 +1,124 @@
+// ****************************************************************************
+//
+// @file       sse.go
+// @brief      Server-Sent Events推送，与WebSocket共用hub
+//
+// @author     KBchulan
+// @date       2026/10/17
+// @history
+// ****************************************************************************
+
+package websocket
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	// 心跳注释的发送间隔，必须小于pongWait
+	sseHeartbeat = 15 * time.Second
+
+	// 断线后客户端的重连间隔，单位毫秒
+	sseRetry = 3000
+)
+
+// StreamOptions SSE连接参数
+type StreamOptions struct {
+	DeviceID    string
+	Topics      []string // 订阅的主题，需已通过ParseTopics校验
+	LastEventID string   // 客户端最后收到的事件ID，不为空时先补发事件日志中之后的消息
+}
+
+// ServeEvents 以text/event-stream推送与WebSocket相同的消息，直到客户端断开
+func (h *Hub) ServeEvents(w http.ResponseWriter, r *http.Request, opts StreamOptions) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	// 长连接不受服务器写超时限制
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	client := NewClient(h, nil)
+	client.DeviceID = opts.DeviceID
+	topics := make(map[string]bool, len(opts.Topics))
+	for _, topic := range opts.Topics {
+		h.subscribe(client, topic)
+		topics[topic] = true
+	}
+	h.Register <- client
+	defer func() {
+		h.Unregister <- client
+	}()
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", sseRetry); err != nil {
+		return
+	}
+
+	// 先注册再补发，补发期间的新消息留在发送队列中，已补发过的按事件ID跳过
+	var replayed string
+	if opts.LastEventID != "" && h.events != nil {
+		entries, err := h.events.since(r.Context(), opts.LastEventID)
+		if err != nil {
+			log.Printf("Failed to replay events for %s: %v", opts.DeviceID, err)
+		}
+		for i := range entries {
+			replayed = entries[i].ID
+			if !entries[i].visibleTo(opts.DeviceID, topics) {
+				continue
+			}
+			if err := writeEvent(w, frame{id: entries[i].ID, data: entries[i].Data}); err != nil {
+				return
+			}
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(sseHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, ok := <-client.send:
+			if !ok {
+				return
+			}
+			if replayed != "" && msg.id != "" && !eventIDAfter(msg.id, replayed) {
+				continue
+			}
+			if err := writeEvent(w, msg); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+			client.touch()
+		}
+	}
+}
+
+// writeEvent 写入一条事件，消息为单行JSON
+func writeEvent(w io.Writer, msg frame) error {
+	if msg.id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", msg.id); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "data: %s\n\n", msg.data)
+	return err
+}